@@ -0,0 +1,42 @@
+// Command formctl operates a form deployment: operator accounts, schema
+// migrations, imports, exports and signing key rotation. It reads the same
+// environment as the server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"form/config"
+	"form/handlers"
+	"form/storage"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run executes one command, stopping the embedded Postgres, if it started
+// one, before returning.
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.Load()
+
+	if cfg.EmbeddedPostgresDir != "" && cfg.DatabaseDriver == config.DriverPostgres {
+		pg, err := storage.StartEmbeddedPostgres(&cfg)
+		if err != nil {
+			return fmt.Errorf("starting embedded Postgres: %w", err)
+		}
+		defer pg.Stop()
+	}
+
+	return handlers.NewFormctlCommand(cfg).ExecuteContext(ctx)
+}
@@ -0,0 +1,507 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: form/v1/contacts.proto
+
+package formpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Contact struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Email             string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	FullName          string                 `protobuf:"bytes,3,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	Timestamp         string                 `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	TwitterProfile    string                 `protobuf:"bytes,5,opt,name=twitter_profile,json=twitterProfile,proto3" json:"twitter_profile,omitempty"`
+	LinkedinProfile   string                 `protobuf:"bytes,6,opt,name=linkedin_profile,json=linkedinProfile,proto3" json:"linkedin_profile,omitempty"`
+	Suppressed        bool                   `protobuf:"varint,7,opt,name=suppressed,proto3" json:"suppressed,omitempty"`
+	SuppressedAt      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=suppressed_at,json=suppressedAt,proto3" json:"suppressed_at,omitempty"`
+	SuppressionReason string                 `protobuf:"bytes,9,opt,name=suppression_reason,json=suppressionReason,proto3" json:"suppression_reason,omitempty"`
+	SuppressionNote   string                 `protobuf:"bytes,10,opt,name=suppression_note,json=suppressionNote,proto3" json:"suppression_note,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Contact) Reset() {
+	*x = Contact{}
+	mi := &file_form_v1_contacts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Contact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Contact) ProtoMessage() {}
+
+func (x *Contact) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Contact.ProtoReflect.Descriptor instead.
+func (*Contact) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Contact) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Contact) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Contact) GetFullName() string {
+	if x != nil {
+		return x.FullName
+	}
+	return ""
+}
+
+func (x *Contact) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+func (x *Contact) GetTwitterProfile() string {
+	if x != nil {
+		return x.TwitterProfile
+	}
+	return ""
+}
+
+func (x *Contact) GetLinkedinProfile() string {
+	if x != nil {
+		return x.LinkedinProfile
+	}
+	return ""
+}
+
+func (x *Contact) GetSuppressed() bool {
+	if x != nil {
+		return x.Suppressed
+	}
+	return false
+}
+
+func (x *Contact) GetSuppressedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SuppressedAt
+	}
+	return nil
+}
+
+func (x *Contact) GetSuppressionReason() string {
+	if x != nil {
+		return x.SuppressionReason
+	}
+	return ""
+}
+
+func (x *Contact) GetSuppressionNote() string {
+	if x != nil {
+		return x.SuppressionNote
+	}
+	return ""
+}
+
+type GetContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetContactRequest) Reset() {
+	*x = GetContactRequest{}
+	mi := &file_form_v1_contacts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetContactRequest) ProtoMessage() {}
+
+func (x *GetContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetContactRequest.ProtoReflect.Descriptor instead.
+func (*GetContactRequest) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetContactRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListContactsRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	IncludeSuppressed bool                   `protobuf:"varint,1,opt,name=include_suppressed,json=includeSuppressed,proto3" json:"include_suppressed,omitempty"`
+	// page_size defaults to 50 and is capped at 500.
+	PageSize      int32  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContactsRequest) Reset() {
+	*x = ListContactsRequest{}
+	mi := &file_form_v1_contacts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContactsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContactsRequest) ProtoMessage() {}
+
+func (x *ListContactsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContactsRequest.ProtoReflect.Descriptor instead.
+func (*ListContactsRequest) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListContactsRequest) GetIncludeSuppressed() bool {
+	if x != nil {
+		return x.IncludeSuppressed
+	}
+	return false
+}
+
+func (x *ListContactsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListContactsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListContactsResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Contacts []*Contact             `protobuf:"bytes,1,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	// next_page_token is empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContactsResponse) Reset() {
+	*x = ListContactsResponse{}
+	mi := &file_form_v1_contacts_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContactsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContactsResponse) ProtoMessage() {}
+
+func (x *ListContactsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContactsResponse.ProtoReflect.Descriptor instead.
+func (*ListContactsResponse) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListContactsResponse) GetContacts() []*Contact {
+	if x != nil {
+		return x.Contacts
+	}
+	return nil
+}
+
+func (x *ListContactsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type SuppressContactRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Email string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	// reason is user_request, admin, bounce or complaint; admin if empty.
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Note          string `protobuf:"bytes,3,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SuppressContactRequest) Reset() {
+	*x = SuppressContactRequest{}
+	mi := &file_form_v1_contacts_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SuppressContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SuppressContactRequest) ProtoMessage() {}
+
+func (x *SuppressContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SuppressContactRequest.ProtoReflect.Descriptor instead.
+func (*SuppressContactRequest) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SuppressContactRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *SuppressContactRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SuppressContactRequest) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+type SuppressContactResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Suppressed    int64                  `protobuf:"varint,1,opt,name=suppressed,proto3" json:"suppressed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SuppressContactResponse) Reset() {
+	*x = SuppressContactResponse{}
+	mi := &file_form_v1_contacts_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SuppressContactResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SuppressContactResponse) ProtoMessage() {}
+
+func (x *SuppressContactResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_form_v1_contacts_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SuppressContactResponse.ProtoReflect.Descriptor instead.
+func (*SuppressContactResponse) Descriptor() ([]byte, []int) {
+	return file_form_v1_contacts_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SuppressContactResponse) GetSuppressed() int64 {
+	if x != nil {
+		return x.Suppressed
+	}
+	return 0
+}
+
+var File_form_v1_contacts_proto protoreflect.FileDescriptor
+
+const file_form_v1_contacts_proto_rawDesc = "" +
+	"\n" +
+	"\x16form/v1/contacts.proto\x12\aform.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf9\x02\n" +
+	"\aContact\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x1b\n" +
+	"\tfull_name\x18\x03 \x01(\tR\bfullName\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\tR\ttimestamp\x12'\n" +
+	"\x0ftwitter_profile\x18\x05 \x01(\tR\x0etwitterProfile\x12)\n" +
+	"\x10linkedin_profile\x18\x06 \x01(\tR\x0flinkedinProfile\x12\x1e\n" +
+	"\n" +
+	"suppressed\x18\a \x01(\bR\n" +
+	"suppressed\x12?\n" +
+	"\rsuppressed_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\fsuppressedAt\x12-\n" +
+	"\x12suppression_reason\x18\t \x01(\tR\x11suppressionReason\x12)\n" +
+	"\x10suppression_note\x18\n" +
+	" \x01(\tR\x0fsuppressionNote\"#\n" +
+	"\x11GetContactRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"\x80\x01\n" +
+	"\x13ListContactsRequest\x12-\n" +
+	"\x12include_suppressed\x18\x01 \x01(\bR\x11includeSuppressed\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"l\n" +
+	"\x14ListContactsResponse\x12,\n" +
+	"\bcontacts\x18\x01 \x03(\v2\x10.form.v1.ContactR\bcontacts\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"Z\n" +
+	"\x16SuppressContactRequest\x12\x14\n" +
+	"\x05email\x18\x01 \x01(\tR\x05email\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x12\n" +
+	"\x04note\x18\x03 \x01(\tR\x04note\"9\n" +
+	"\x17SuppressContactResponse\x12\x1e\n" +
+	"\n" +
+	"suppressed\x18\x01 \x01(\x03R\n" +
+	"suppressed2\xef\x01\n" +
+	"\x0eContactService\x12:\n" +
+	"\n" +
+	"GetContact\x12\x1a.form.v1.GetContactRequest\x1a\x10.form.v1.Contact\x12K\n" +
+	"\fListContacts\x12\x1c.form.v1.ListContactsRequest\x1a\x1d.form.v1.ListContactsResponse\x12T\n" +
+	"\x0fSuppressContact\x12\x1f.form.v1.SuppressContactRequest\x1a .form.v1.SuppressContactResponseB\x14Z\x12form/formpb;formpbb\x06proto3"
+
+var (
+	file_form_v1_contacts_proto_rawDescOnce sync.Once
+	file_form_v1_contacts_proto_rawDescData []byte
+)
+
+func file_form_v1_contacts_proto_rawDescGZIP() []byte {
+	file_form_v1_contacts_proto_rawDescOnce.Do(func() {
+		file_form_v1_contacts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_form_v1_contacts_proto_rawDesc), len(file_form_v1_contacts_proto_rawDesc)))
+	})
+	return file_form_v1_contacts_proto_rawDescData
+}
+
+var file_form_v1_contacts_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_form_v1_contacts_proto_goTypes = []any{
+	(*Contact)(nil),                 // 0: form.v1.Contact
+	(*GetContactRequest)(nil),       // 1: form.v1.GetContactRequest
+	(*ListContactsRequest)(nil),     // 2: form.v1.ListContactsRequest
+	(*ListContactsResponse)(nil),    // 3: form.v1.ListContactsResponse
+	(*SuppressContactRequest)(nil),  // 4: form.v1.SuppressContactRequest
+	(*SuppressContactResponse)(nil), // 5: form.v1.SuppressContactResponse
+	(*timestamppb.Timestamp)(nil),   // 6: google.protobuf.Timestamp
+}
+var file_form_v1_contacts_proto_depIdxs = []int32{
+	6, // 0: form.v1.Contact.suppressed_at:type_name -> google.protobuf.Timestamp
+	0, // 1: form.v1.ListContactsResponse.contacts:type_name -> form.v1.Contact
+	1, // 2: form.v1.ContactService.GetContact:input_type -> form.v1.GetContactRequest
+	2, // 3: form.v1.ContactService.ListContacts:input_type -> form.v1.ListContactsRequest
+	4, // 4: form.v1.ContactService.SuppressContact:input_type -> form.v1.SuppressContactRequest
+	0, // 5: form.v1.ContactService.GetContact:output_type -> form.v1.Contact
+	3, // 6: form.v1.ContactService.ListContacts:output_type -> form.v1.ListContactsResponse
+	5, // 7: form.v1.ContactService.SuppressContact:output_type -> form.v1.SuppressContactResponse
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_form_v1_contacts_proto_init() }
+func file_form_v1_contacts_proto_init() {
+	if File_form_v1_contacts_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_form_v1_contacts_proto_rawDesc), len(file_form_v1_contacts_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_form_v1_contacts_proto_goTypes,
+		DependencyIndexes: file_form_v1_contacts_proto_depIdxs,
+		MessageInfos:      file_form_v1_contacts_proto_msgTypes,
+	}.Build()
+	File_form_v1_contacts_proto = out.File
+	file_form_v1_contacts_proto_goTypes = nil
+	file_form_v1_contacts_proto_depIdxs = nil
+}
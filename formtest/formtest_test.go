@@ -0,0 +1,82 @@
+package formtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"form/formtest"
+)
+
+func TestNewUser(t *testing.T) {
+	s := formtest.Start(t)
+	user := s.NewUser(t)
+
+	var me formtest.User
+	if code := s.Do(t, "GET", "/me", user.Token, nil, &me); code != http.StatusOK {
+		t.Fatalf("GET /me: status %d", code)
+	}
+	if me.ID != user.ID || me.Username != user.Username || me.Role != "" {
+		t.Errorf("GET /me = %+v, want %s without a role", me, user.Username)
+	}
+	if code := s.Do(t, "GET", "/admin/members", user.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("new user calling an admin route: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestNewIssue(t *testing.T) {
+	s := formtest.Start(t)
+	reporter, other := s.NewUser(t), s.NewUser(t)
+
+	issue := s.NewIssue(t, reporter, formtest.Issue{Title: "Export times out", Priority: 3})
+	if issue.ID == 0 || issue.Title != "Export times out" || issue.Priority != 3 {
+		t.Errorf("NewIssue = %+v", issue)
+	}
+	if issue.ReportedBy != reporter.Username || issue.State != "open" {
+		t.Errorf("NewIssue reported by %q in state %q, want %q, open", issue.ReportedBy, issue.State, reporter.Username)
+	}
+
+	var mine, theirs []formtest.Issue
+	s.Do(t, "GET", "/issues", reporter.Token, nil, &mine)
+	s.Do(t, "GET", "/issues", other.Token, nil, &theirs)
+	if len(mine) != 1 || mine[0].ID != issue.ID {
+		t.Errorf("reporter's issues = %+v, want %d", mine, issue.ID)
+	}
+	if len(theirs) != 0 {
+		t.Errorf("another member's issues = %+v, want none", theirs)
+	}
+}
+
+func TestNewProject(t *testing.T) {
+	s := formtest.Start(t)
+	project := s.NewProject(t, "WEB")
+	issue := s.NewIssue(t, s.NewUser(t), formtest.Issue{ProjectID: &project.ID})
+	if issue.Key != "WEB-1" {
+		t.Errorf("issue key = %q, want WEB-1", issue.Key)
+	}
+
+	var byKey formtest.Issue
+	if code := s.Do(t, "GET", "/issues/WEB-1", s.Admin.Token, nil, &byKey); code != http.StatusOK || byKey.ID != issue.ID {
+		t.Errorf("GET /issues/WEB-1: status %d, issue %d, want %d", code, byKey.ID, issue.ID)
+	}
+}
+
+func TestNewImport(t *testing.T) {
+	s := formtest.Start(t)
+	job := s.NewImport(t, [][]string{
+		{"Email Address", "Full Name", "Timestamp", "Twitter Profile", "LinkedIn Profile"},
+		{"ana@example.com", "Ana Lima", "2026-01-02", "", ""},
+		{"bo@example.com", "Bo Chen", "2026-01-03", "@bo", ""},
+	})
+	if job.Status != "completed" || job.Total != 2 || job.Inserted != 2 {
+		t.Fatalf("NewImport = %+v, want 2 contacts inserted", job)
+	}
+
+	var contact struct {
+		Email string `json:"email"`
+	}
+	path := fmt.Sprintf("/contacts/%d", 1)
+	if code := s.Do(t, "GET", path, s.Admin.Token, nil, &contact); code != http.StatusOK || contact.Email != "ana@example.com" {
+		t.Errorf("GET %s: status %d, email %q", path, code, contact.Email)
+	}
+}
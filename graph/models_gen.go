@@ -0,0 +1,115 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"time"
+)
+
+type Attachment struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type Comment struct {
+	ID     string `json:"id"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+	// Usernames the body mentions.
+	Mentions  []string  `json:"mentions"`
+	CreatedAt time.Time `json:"createdAt"`
+	Issue     *Issue    `json:"issue"`
+	IssueID   uint      `json:"-"`
+}
+
+type CommentConnection struct {
+	Edges    []*CommentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type Issue struct {
+	ID string `json:"id"`
+	// The project key and number, such as FORM-123, once the issue is in a project.
+	Key      *string `json:"key,omitempty"`
+	Title    string  `json:"title"`
+	Details  string  `json:"details"`
+	Priority int     `json:"priority"`
+	// open, waiting_on_reporter or closed.
+	State       string    `json:"state"`
+	Type        bool      `json:"type"`
+	ReportedBy  string    `json:"reportedBy"`
+	ReportedAt  time.Time `json:"reportedAt"`
+	Assignee    string    `json:"assignee"`
+	Component   string    `json:"component"`
+	ReopenCount int       `json:"reopenCount"`
+	// Usernames the details mention.
+	Mentions []string `json:"mentions"`
+	// Goes up with every change; updates can require it to be unchanged.
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	Reporter     *User     `json:"reporter,omitempty"`
+	AssigneeUser *User     `json:"assigneeUser,omitempty"`
+	Labels       []*Label  `json:"labels"`
+	FixVersion   *Release  `json:"fixVersion,omitempty"`
+	// A short-lived signed link to the attached image.
+	Attachment     *Attachment        `json:"attachment,omitempty"`
+	Comments       *CommentConnection `json:"comments"`
+	History        []*IssueEvent      `json:"history"`
+	FixVersionID   uint               `json:"-"`
+	ImageURL       string             `json:"-"`
+	OrganizationID uint               `json:"-"`
+}
+
+type IssueConnection struct {
+	Edges    []*IssueEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+}
+
+type IssueEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Issue `json:"node"`
+}
+
+type IssueEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	FromValue string    `json:"fromValue"`
+	ToValue   string    `json:"toValue"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Label struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name"`
+	Issues *IssueConnection `json:"issues"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+type Query struct {
+}
+
+type Release struct {
+	ID         string     `json:"id"`
+	Version    string     `json:"version"`
+	Name       string     `json:"name"`
+	ReleasedAt *time.Time `json:"releasedAt,omitempty"`
+}
+
+type User struct {
+	ID             string           `json:"id"`
+	Username       string           `json:"username"`
+	Role           string           `json:"role"`
+	ReportedIssues *IssueConnection `json:"reportedIssues"`
+	AssignedIssues *IssueConnection `json:"assignedIssues"`
+}
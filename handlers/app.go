@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"form/config"
+	"form/models"
+	"form/storage"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// App is the service layer: the handlers, background workers and formctl
+// commands are its methods, and it carries everything they share.
+type App struct {
+	cfg     config.Config
+	db      *gorm.DB
+	storage storage.Storage
+	repo    Repository
+	cache   Cache
+	scanner Scanner
+
+	settings settingsCache
+	live     liveHub
+	// sqliteNotices stands in for NOTIFY on SQLite, whose database only
+	// this process uses. Notices are sent before the transaction commits;
+	// the relay waits for it before loading anything.
+	sqliteNotices chan string
+
+	emailSenders    map[string]EmailSender
+	emailLimitersMu sync.Mutex
+	emailLimiters   map[string]*rate.Limiter
+
+	// webhookClient and connectorClient make the outbound requests tenants
+	// configure, through the egress policy.
+	webhookClient   *http.Client
+	connectorClient *http.Client
+
+	openAPISpec []byte
+
+	// ctx is cancelled when the server starts shutting down. Work started
+	// in the background from a request should run under it.
+	ctx context.Context
+	// background tracks long-running workers so shutdown can wait for them.
+	background sync.WaitGroup
+	// shuttingDown is set when the server starts draining so load
+	// balancers stop routing new requests here.
+	shuttingDown atomic.Bool
+}
+
+// New builds the service layer on c, database and store and loads the
+// admin settings. The schema must already be migrated.
+func New(c config.Config, database *gorm.DB, store storage.Storage) (*App, error) {
+	scanner, err := newScanner(c)
+	if err != nil {
+		return nil, fmt.Errorf("configuring upload scanner: %w", err)
+	}
+	cache, err := newCache(c)
+	if err != nil {
+		return nil, fmt.Errorf("configuring cache: %w", err)
+	}
+	egress := newEgressTransport(c.AllowPrivateEgress)
+	a := &App{
+		cfg:             c,
+		db:              database,
+		storage:         store,
+		cache:           cache,
+		scanner:         scanner,
+		live:            liveHub{subs: map[*liveSubscriber]struct{}{}},
+		sqliteNotices:   make(chan string, 256),
+		emailSenders:    map[string]EmailSender{},
+		emailLimiters:   map[string]*rate.Limiter{},
+		webhookClient:   &http.Client{Timeout: 10 * time.Second, Transport: egress},
+		connectorClient: &http.Client{Timeout: time.Minute, Transport: egress},
+		ctx:             context.Background(),
+	}
+	a.repo = gormRepository{db: database, app: a}
+	registerTracingCallbacks(database)
+	registerTenantCallbacks(database)
+
+	if err := a.reloadSettings(context.Background()); err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	return a, nil
+}
+
+// goBackground runs fn in a goroutine that shutdown waits for. fn must
+// return once ctx is cancelled.
+func (a *App) goBackground(ctx context.Context, fn func(ctx context.Context)) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		fn(ctx)
+	}()
+}
+
+// createAdmin creates the admin account on first start.
+func (a *App) createAdmin() error {
+	// Check if an admin user already exists
+	var admin models.User
+	if errors.Is(a.db.Where("role = ?", "admin").First(&admin).Error, gorm.ErrRecordNotFound) {
+		// Create admin user if not exists
+		admin := models.User{Username: "admin", Password: "adminpass", Role: "admin"}
+		if err := createAdminUser(a.db, &admin); err != nil {
+			return fmt.Errorf("creating admin user: %w", err)
+		}
+		log.Println("Admin user created successfully.")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"form/models"
+
+	"gorm.io/gorm"
+)
+
+// recordAudit appends an audit entry using q, which may be a transaction.
+func recordAudit(q *gorm.DB, actor, action, subjectType string, subjectID uint, details interface{}) error {
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return q.Create(&models.AuditEntry{
+		Actor:       actor,
+		Action:      action,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		Details:     string(b),
+	}).Error
+}
+
+func (a *App) listAuditHandler(w http.ResponseWriter, r *http.Request) {
+	q := a.dbCtx(r.Context()).Order("id desc")
+	if action := r.URL.Query().Get("action"); action != "" {
+		q = q.Where("action = ?", action)
+	}
+	if subjectType := r.URL.Query().Get("subjectType"); subjectType != "" {
+		q = q.Where("subject_type = ?", subjectType)
+	}
+	if subjectID := r.URL.Query().Get("subjectId"); subjectID != "" {
+		q = q.Where("subject_id = ?", subjectID)
+	}
+
+	format, ok := streamFormat(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "stream must be ndjson or array")
+		return
+	}
+	if format != "" {
+		a.streamList(w, r, format, q.Model(&models.AuditEntry{}), func() interface{} { return &models.AuditEntry{} }, nil)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var entries []models.AuditEntry
+	if err := q.Limit(limit).Find(&entries).Error; err != nil {
+		log.Println("Error loading audit log:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading audit log")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, entries)
+}
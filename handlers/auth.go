@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+type tokenClaims struct {
+	UserID uint   `json:"uid"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// signParts returns a hex HMAC of the newline-joined parts under the server
+// signing key, for links that must not be forged.
+func (a *App) signParts(parts ...string) string {
+	mac := hmac.New(sha256.New, a.cfg.SigningKey)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueToken returns a signed bearer token for the given user.
+func (a *App) issueToken(user models.User) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		UserID: user.ID,
+		Role:   user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.cfg.TokenTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.cfg.SigningKey)
+}
+
+func (a *App) parseToken(raw string) (*tokenClaims, error) {
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if len(a.cfg.PreviousSigningKeys) == 0 {
+			return a.cfg.SigningKey, nil
+		}
+		keys := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{a.cfg.SigningKey}}
+		for _, key := range a.cfg.PreviousSigningKeys {
+			keys.Keys = append(keys.Keys, []byte(key))
+		}
+		return keys, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authMiddleware resolves the bearer token, if any, to a user and stores it
+// in the request context. Requests without a token pass through anonymously;
+// requests with a bad token are rejected.
+func (a *App) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := strings.TrimPrefix(header, "Bearer ")
+		user, err := a.tokenUser(r.Context(), raw)
+		if err != nil || raw == header {
+			writeError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tokenUser returns the user a raw bearer token was issued to.
+func (a *App) tokenUser(ctx context.Context, raw string) (*models.User, error) {
+	claims, err := a.parseToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.repo.FindUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+	// Forcing a password reset revokes every token issued before it
+	if user.PasswordChangedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Unix() < user.PasswordChangedAt.Unix()) {
+		return nil, errTokenRevoked
+	}
+	return &user, nil
+}
+
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errTokenRevoked     = errors.New("token revoked")
+)
+
+// currentUser returns the authenticated user for the request.
+func currentUser(r *http.Request) (*models.User, error) {
+	user := contextUser(r.Context())
+	if user == nil {
+		return nil, errNotAuthenticated
+	}
+	return user, nil
+}
+
+// contextUser returns the authenticated user stored in ctx, or nil.
+func contextUser(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey).(*models.User)
+	return user
+}
+
+// requireAuth rejects anonymous requests before calling h.
+func requireAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := currentUser(r); err != nil {
+			writeError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireAdmin rejects requests that are not from an admin user.
+func requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := currentUser(r)
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, "Admin access required")
+			return
+		}
+		h(w, r)
+	})
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	systemActor = "system"
+
+	autoCloseBatchSize = 100
+)
+
+// autoClosePolicy is how long an issue may wait on its reporter before it is
+// closed, and how long before that the reporter is warned. A zero closeAfter
+// disables auto-close.
+type autoClosePolicy struct {
+	closeAfter time.Duration
+	warnBefore time.Duration
+}
+
+func (a *App) currentAutoClosePolicy() autoClosePolicy {
+	s := a.currentSettings()
+	return newAutoClosePolicy(s.AutoCloseAfterDays, s.AutoCloseWarningDays)
+}
+
+func newAutoClosePolicy(afterDays, warningDays int) autoClosePolicy {
+	p := autoClosePolicy{
+		closeAfter: time.Duration(afterDays) * 24 * time.Hour,
+		warnBefore: time.Duration(warningDays) * 24 * time.Hour,
+	}
+	if p.warnBefore > p.closeAfter {
+		p.warnBefore = p.closeAfter
+	}
+	return p
+}
+
+// projectAutoClosePolicy is the policy for a project's issues: its own
+// overrides, with the settings filling in any it does not set.
+func (a *App) projectAutoClosePolicy(project models.Project) autoClosePolicy {
+	s := a.currentSettings()
+	afterDays, warningDays := s.AutoCloseAfterDays, s.AutoCloseWarningDays
+	if project.AutoCloseAfterDays != nil {
+		afterDays = *project.AutoCloseAfterDays
+	}
+	if project.AutoCloseWarningDays != nil {
+		warningDays = *project.AutoCloseWarningDays
+	}
+	return newAutoClosePolicy(afterDays, warningDays)
+}
+
+// runAutoClose applies the auto-close policy on every tick until ctx is
+// cancelled.
+func (a *App) runAutoClose(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.applyAutoClose(ctx, time.Now()); err != nil {
+				log.Println("Error applying auto-close policy:", err)
+			}
+		}
+	}
+}
+
+// applyAutoClose warns reporters of issues that will soon be closed for lack
+// of a reply, then closes those whose warning period has passed. A reporter
+// who replies moves the issue back to open, which stops the clock. Projects
+// that override the policy are swept on their own; the rest follow the
+// settings.
+func (a *App) applyAutoClose(ctx context.Context, now time.Time) error {
+	// The sweep spans organizations; each issue is handled inside its own
+	ctx = allOrganizations(ctx)
+
+	var overridden []models.Project
+	if err := a.dbCtx(ctx).Where("auto_close_after_days IS NOT NULL OR auto_close_warning_days IS NOT NULL").
+		Order("id").Find(&overridden).Error; err != nil {
+		return err
+	}
+	ids := make([]uint, len(overridden))
+	for i, project := range overridden {
+		ids[i] = project.ID
+		scope := func(q *gorm.DB) *gorm.DB { return q.Where("project_id = ?", project.ID) }
+		if err := a.sweepAutoClose(ctx, now, a.projectAutoClosePolicy(project), scope); err != nil {
+			return err
+		}
+	}
+	return a.sweepAutoClose(ctx, now, a.currentAutoClosePolicy(), func(q *gorm.DB) *gorm.DB {
+		if len(ids) == 0 {
+			return q
+		}
+		return q.Where("(project_id IS NULL OR project_id NOT IN (?))", ids)
+	})
+}
+
+// sweepAutoClose applies policy to the issues scope selects.
+func (a *App) sweepAutoClose(ctx context.Context, now time.Time, policy autoClosePolicy, scope func(*gorm.DB) *gorm.DB) error {
+	if policy.closeAfter <= 0 {
+		return nil
+	}
+
+	if policy.warnBefore > 0 {
+		var due []models.Issue
+		if err := scope(a.dbCtx(ctx)).Where("state = ? AND stale_warned_at IS NULL AND waiting_since <= ?",
+			stateWaitingOnReporter, now.Add(-(policy.closeAfter - policy.warnBefore))).
+			Limit(autoCloseBatchSize).Find(&due).Error; err != nil {
+			return err
+		}
+		for _, issue := range due {
+			if err := a.warnStaleIssue(withOrganization(ctx, issue.OrganizationID), issue, now, policy); err != nil {
+				log.Printf("Error warning reporter of issue %d: %s", issue.ID, err)
+			}
+		}
+	}
+
+	// Issues are only closed once their reporter has had the full warning
+	// period, even if the policy was shortened since.
+	q := scope(a.dbCtx(ctx)).Where("state = ? AND waiting_since <= ?", stateWaitingOnReporter, now.Add(-policy.closeAfter))
+	if policy.warnBefore > 0 {
+		q = q.Where("stale_warned_at <= ?", now.Add(-policy.warnBefore))
+	}
+	var stale []models.Issue
+	if err := q.Limit(autoCloseBatchSize).Find(&stale).Error; err != nil {
+		return err
+	}
+	for _, issue := range stale {
+		if err := a.autoCloseIssue(withOrganization(ctx, issue.OrganizationID), issue, policy); err != nil {
+			log.Printf("Error auto-closing issue %d: %s", issue.ID, err)
+		}
+	}
+	return nil
+}
+
+// warnStaleIssue tells the reporter the issue will be closed. The
+// conditional update lets only one instance send the warning.
+func (a *App) warnStaleIssue(ctx context.Context, issue models.Issue, now time.Time, policy autoClosePolicy) error {
+	tx := a.dbCtx(ctx).Begin()
+	result := tx.Model(&models.Issue{}).Where("id = ? AND state = ? AND stale_warned_at IS NULL", issue.ID, stateWaitingOnReporter).
+		Updates(map[string]interface{}{"stale_warned_at": now, "version": gorm.Expr("version + 1")})
+	if result.Error != nil || result.RowsAffected == 0 {
+		tx.Rollback()
+		return result.Error
+	}
+
+	body := fmt.Sprintf("This issue is waiting on a reply from @%s and will be closed automatically in %d days if there is none.",
+		issue.ReportedBy, int(policy.warnBefore.Hours()/24))
+	if err := tx.Create(&models.Comment{IssueID: issue.ID, Author: systemActor, Body: body}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := a.recordIssueEvent(tx, issue.ID, eventStaleWarning, systemActor, "", ""); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	issue.StaleWarnedAt = &now
+	a.fireWebhooks(ctx, "issue.stale_warning", issue)
+	return nil
+}
+
+// autoCloseIssue closes an issue still waiting on its reporter and tells
+// them how to reopen it.
+func (a *App) autoCloseIssue(ctx context.Context, issue models.Issue, policy autoClosePolicy) error {
+	tx := a.dbCtx(ctx).Begin()
+	var locked models.Issue
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, issue.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if locked.State != stateWaitingOnReporter {
+		tx.Rollback()
+		return nil
+	}
+
+	if err := a.setIssueState(tx, &locked, stateClosed, systemActor); err != nil {
+		tx.Rollback()
+		return err
+	}
+	body := fmt.Sprintf("Closed automatically after %d days without a reply from @%s. Reopen the issue if it still needs attention.",
+		int(policy.closeAfter.Hours()/24), issue.ReportedBy)
+	if err := tx.Create(&models.Comment{IssueID: issue.ID, Author: systemActor, Body: body}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := a.recordIssueEvent(tx, issue.ID, eventAutoClosed, systemActor, "", ""); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	a.fireWebhooks(ctx, "issue.auto_closed", locked)
+	return nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	biDefaultLimit = 1000
+	biMaxLimit     = 10000
+
+	// biSettleTime holds back rows changed in the last moments, whose
+	// transactions may not all be visible yet, so a cursor never skips them.
+	biSettleTime = 5 * time.Second
+)
+
+// biFeed describes one entity of the incremental export in a flat,
+// analytics-friendly shape. Rows are ordered by (changedColumn, id).
+type biFeed struct {
+	table         string
+	columns       string
+	changedColumn string
+	// tenantFilter selects the rows of the organization bound to it. The
+	// query is raw SQL, so GORM's tenant scoping never sees it.
+	tenantFilter string
+}
+
+var biFeeds = map[string]biFeed{
+	"issues": {
+		table: "issues",
+		columns: `id AS issue_id, title, details, priority, status AS is_closed, state, assignee, component, reopen_count, fix_version_id, type AS type_flag,
+			image_url <> '' AS has_attachment, reported_by, reported_at, created_at, updated_at, deleted_at`,
+		changedColumn: "updated_at",
+		tenantFilter:  "organization_id = ?",
+	},
+	"events": {
+		table:         "issue_events",
+		columns:       "id AS event_id, issue_id, type AS event_type, actor, from_value, to_value, created_at AS occurred_at",
+		changedColumn: "created_at",
+		tenantFilter:  "issue_id IN (SELECT id FROM issues WHERE organization_id = ?)",
+	},
+	"comments": {
+		table:         "comments",
+		columns:       "id AS comment_id, issue_id, author, body, length(body) AS body_length, created_at, updated_at, deleted_at",
+		changedColumn: "updated_at",
+		tenantFilter:  "issue_id IN (SELECT id FROM issues WHERE organization_id = ?)",
+	},
+}
+
+// biCursor marks the last row a sync has seen.
+type biCursor struct {
+	Changed time.Time `json:"t"`
+	ID      uint      `json:"id"`
+}
+
+func (c biCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBICursor(s string) (biCursor, error) {
+	var c biCursor
+	if s == "" {
+		return c, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// biExportHandler returns rows of one entity changed after the cursor.
+// Soft-deleted rows are included with deleted_at set so warehouses can
+// apply deletes.
+func (a *App) biExportHandler(w http.ResponseWriter, r *http.Request) {
+	entity := mux.Vars(r)["entity"]
+	feed, ok := biFeeds[entity]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown entity")
+		return
+	}
+
+	cursor, err := decodeBICursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = biDefaultLimit
+	}
+	if limit > biMaxLimit {
+		limit = biMaxLimit
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s AS changed_at, id AS cursor_id FROM %s
+		WHERE %s AND (%s, id) > (?, ?) AND %s <= ?
+		ORDER BY %s, id LIMIT ?`,
+		feed.columns, feed.changedColumn, feed.table, feed.tenantFilter,
+		feed.changedColumn, feed.changedColumn, feed.changedColumn)
+	rows, err := a.dbCtx(r.Context()).Raw(query, contextOrganization(r.Context()), cursor.Changed, cursor.ID, time.Now().Add(-biSettleTime), limit+1).Rows()
+	if err != nil {
+		log.Printf("Error exporting %s: %s", entity, err)
+		writeErrorf(w, http.StatusInternalServerError, "Error exporting %s", entity)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		log.Printf("Error exporting %s: %s", entity, err)
+		writeErrorf(w, http.StatusInternalServerError, "Error exporting %s", entity)
+		return
+	}
+
+	out := []map[string]interface{}{}
+	next := cursor
+	hasMore := false
+	for rows.Next() {
+		if len(out) == limit {
+			hasMore = true
+			break
+		}
+
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			log.Printf("Error exporting %s: %s", entity, err)
+			writeErrorf(w, http.StatusInternalServerError, "Error exporting %s", entity)
+			return
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			v := values[i]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			switch col {
+			case "changed_at":
+				next.Changed, _ = v.(time.Time)
+			case "cursor_id":
+				id, _ := v.(int64)
+				next.ID = uint(id)
+			default:
+				row[col] = v
+			}
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error exporting %s: %s", entity, err)
+		writeErrorf(w, http.StatusInternalServerError, "Error exporting %s", entity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"entity":     entity,
+		"rows":       out,
+		"nextCursor": next.encode(),
+		"hasMore":    hasMore,
+	})
+}
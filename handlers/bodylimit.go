@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// bodyLimitMiddleware caps request bodies before handlers read them:
+// multipart uploads at the upload_limit_mb setting and anything else at
+// body_limit_kb. Bodies that declare a larger size are refused outright;
+// others fail once they pass the limit, so a huge upload is never read
+// into memory or spooled to disk.
+func (a *App) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := a.requestBodyLimit(r)
+		if r.ContentLength > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, "Body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestBodyLimit returns the most bytes r's body may hold. Settings that
+// are not positive fall back to the defaults.
+func (a *App) requestBodyLimit(r *http.Request) int64 {
+	s := a.currentSettings()
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if media == "multipart/form-data" {
+		if s.UploadLimitMB <= 0 {
+			s.UploadLimitMB = defaultSettings.UploadLimitMB
+		}
+		return s.UploadLimitMB << 20
+	}
+	if s.BodyLimitKB <= 0 {
+		s.BodyLimitKB = defaultSettings.BodyLimitKB
+	}
+	return s.BodyLimitKB << 10
+}
+
+// bodyTooLarge reports whether err came from reading past the body limit.
+func bodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+)
+
+// isUndeliverable reports whether mail to email should be held back,
+// because it bounced, drew a complaint or the contact opted out.
+func isUndeliverable(q *gorm.DB, email string) (bool, error) {
+	var n int64
+	if err := q.Model(&models.UndeliverableAddress{}).Where("email = lower(?)", email).Count(&n).Error; err != nil || n > 0 {
+		return n > 0, err
+	}
+	// Opting out through any organization holds mail back for all of them
+	err := acrossOrganizations(q).Model(&models.Contact{}).Where("lower(email) = lower(?) AND suppressed = ?", email, true).Count(&n).Error
+	return n > 0, err
+}
+
+// markUndeliverable records a bounce or complaint for email, suppresses
+// matching contacts and updates the message it was reported for.
+func (a *App) markUndeliverable(ctx context.Context, email, reason, note, providerMessageID string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil
+	}
+	err := a.dbCtx(ctx).Exec(`INSERT INTO undeliverable_addresses (email, reason, note, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (email) DO UPDATE SET reason = EXCLUDED.reason, note = EXCLUDED.note`, email, reason, note, time.Now()).Error
+	if err != nil {
+		return err
+	}
+	// A bounce is about the address, whichever organizations know it
+	if _, err := a.repo.SuppressContacts(allOrganizations(ctx), email, reason, note); err != nil {
+		return err
+	}
+	if providerMessageID == "" {
+		return nil
+	}
+	status := emailBounced
+	if reason == suppressedByComplaint {
+		status = emailComplained
+	}
+	return a.dbCtx(ctx).Model(&models.EmailMessage{}).Where("provider_message_id = ?", providerMessageID).
+		Updates(map[string]interface{}{"status": status, "error": note}).Error
+}
+
+// snsMessage is an Amazon SNS HTTP delivery, which is how SES reports
+// bounces and complaints.
+type snsMessage struct {
+	Type             string
+	MessageId        string
+	Token            string
+	TopicArn         string
+	Subject          string
+	Message          string
+	Timestamp        string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+	SubscribeURL     string
+}
+
+// signingString builds the canonical text SNS signs for the message type.
+func (m snsMessage) signingString() string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", m.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	if m.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", m.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return b.String()
+}
+
+var (
+	snsCertsMu sync.Mutex
+	snsCerts   = map[string]*x509.Certificate{}
+)
+
+// snsCertificate fetches and caches an SNS signing certificate, refusing
+// URLs that are not served by SNS itself.
+func snsCertificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !strings.HasPrefix(u.Host, "sns.") || !strings.HasSuffix(u.Host, ".amazonaws.com") {
+		return nil, fmt.Errorf("untrusted signing certificate URL %q", certURL)
+	}
+
+	snsCertsMu.Lock()
+	defer snsCertsMu.Unlock()
+	if cert, ok := snsCerts[certURL]; ok {
+		return cert, nil
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	snsCerts[certURL] = cert
+	return cert, nil
+}
+
+func verifySNSMessage(m snsMessage) error {
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return err
+	}
+	cert, err := snsCertificate(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate has no RSA key")
+	}
+
+	text := []byte(m.signingString())
+	switch m.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(text)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA1, sum[:], sig)
+	case "2":
+		sum := sha256.Sum256(text)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig)
+	}
+	return fmt.Errorf("unsupported signature version %q", m.SignatureVersion)
+}
+
+// sesNotification is the part of an SES bounce or complaint notification
+// used here. Event publishing sends eventType, feedback notifications send
+// notificationType.
+type sesNotification struct {
+	NotificationType string
+	EventType        string
+	Mail             struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+	Bounce struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+// snsMaxAge allows for SNS retry policies, which keep the original
+// timestamp across retries for up to an hour.
+const snsMaxAge = time.Hour
+
+var sesInbound = inboundVerifier{source: "ses", maxAge: snsMaxAge, verify: (*App).verifySESDelivery}
+
+func (a *App) verifySESDelivery(r *http.Request, body []byte) (inboundDelivery, error) {
+	var m snsMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return inboundDelivery{}, err
+	}
+	if err := verifySNSMessage(m); err != nil {
+		return inboundDelivery{}, err
+	}
+	if len(a.cfg.SESTopicARNs) > 0 && !containsString(a.cfg.SESTopicARNs, m.TopicArn) {
+		return inboundDelivery{}, fmt.Errorf("unknown topic %q", m.TopicArn)
+	}
+	sentAt, err := time.Parse(time.RFC3339, m.Timestamp)
+	if err != nil {
+		return inboundDelivery{}, err
+	}
+	return inboundDelivery{Nonce: m.MessageId, SentAt: sentAt}, nil
+}
+
+// sesWebhookHandler receives SES bounce and complaint notifications from
+// SNS. Only permanent bounces mark an address undeliverable; transient ones
+// are left to the provider's own retries.
+func (a *App) sesWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var m snsMessage
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	switch m.Type {
+	case "SubscriptionConfirmation":
+		if err := confirmSNSSubscription(m.SubscribeURL); err != nil {
+			log.Println("Error confirming SNS subscription:", err)
+			writeError(w, http.StatusBadGateway, "Failed to confirm subscription")
+			return
+		}
+		log.Println("Confirmed SNS subscription to", m.TopicArn)
+	case "Notification":
+		var n sesNotification
+		if err := json.Unmarshal([]byte(m.Message), &n); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := a.applySESNotification(r.Context(), n); err != nil {
+			log.Println("Error recording SES notification:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to record notification")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func confirmSNSSubscription(subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil || u.Scheme != "https" || !strings.HasPrefix(u.Host, "sns.") || !strings.HasSuffix(u.Host, ".amazonaws.com") {
+		return fmt.Errorf("untrusted subscribe URL %q", subscribeURL)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(subscribeURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("subscribe URL returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (a *App) applySESNotification(ctx context.Context, n sesNotification) error {
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+	switch kind {
+	case "Bounce":
+		if n.Bounce.BounceType != "Permanent" {
+			return nil
+		}
+		for _, rcpt := range n.Bounce.BouncedRecipients {
+			note := strings.TrimSpace(n.Bounce.BounceSubType + " " + rcpt.DiagnosticCode)
+			if err := a.markUndeliverable(ctx, rcpt.EmailAddress, suppressedByBounce, note, n.Mail.MessageID); err != nil {
+				return err
+			}
+		}
+	case "Complaint":
+		for _, rcpt := range n.Complaint.ComplainedRecipients {
+			if err := a.markUndeliverable(ctx, rcpt.EmailAddress, suppressedByComplaint, n.Complaint.ComplaintFeedbackType, n.Mail.MessageID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mailgunEvent is the part of a Mailgun webhook used here.
+type mailgunEvent struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData struct {
+		Event          string `json:"event"`
+		Severity       string `json:"severity"`
+		Reason         string `json:"reason"`
+		Recipient      string `json:"recipient"`
+		DeliveryStatus struct {
+			Description string `json:"description"`
+		} `json:"delivery-status"`
+		Message struct {
+			Headers struct {
+				MessageID string `json:"message-id"`
+			} `json:"headers"`
+		} `json:"message"`
+	} `json:"event-data"`
+}
+
+var mailgunInbound = inboundVerifier{source: "mailgun", verify: (*App).verifyMailgunDelivery}
+
+// verifyMailgunDelivery checks the HMAC Mailgun puts in the body over its
+// timestamp and token; the token is unique per delivery.
+func (a *App) verifyMailgunDelivery(r *http.Request, body []byte) (inboundDelivery, error) {
+	if a.cfg.MailgunWebhookKey == "" {
+		return inboundDelivery{}, errMissingSecret
+	}
+	var e mailgunEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return inboundDelivery{}, err
+	}
+	sig := e.Signature
+	ts, err := strconv.ParseInt(sig.Timestamp, 10, 64)
+	if err != nil {
+		return inboundDelivery{}, err
+	}
+	if !equalSignatures(sig.Signature, hmacHex(a.cfg.MailgunWebhookKey, []byte(sig.Timestamp+sig.Token))) {
+		return inboundDelivery{}, errInvalidSignature
+	}
+	return inboundDelivery{Nonce: sig.Token, SentAt: time.Unix(ts, 0)}, nil
+}
+
+// mailgunWebhookHandler receives Mailgun failed and complained events.
+// Temporary failures are ignored because Mailgun retries them itself.
+func (a *App) mailgunWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var e mailgunEvent
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var err error
+	ev := e.EventData
+	messageID := strings.Trim(ev.Message.Headers.MessageID, "<>")
+	switch {
+	case ev.Event == "failed" && ev.Severity == "permanent":
+		note := strings.TrimSpace(ev.Reason + " " + ev.DeliveryStatus.Description)
+		err = a.markUndeliverable(r.Context(), ev.Recipient, suppressedByBounce, note, messageID)
+	case ev.Event == "complained":
+		err = a.markUndeliverable(r.Context(), ev.Recipient, suppressedByComplaint, "", messageID)
+	}
+	if err != nil {
+		log.Println("Error recording Mailgun event:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to record event")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"form/config"
+	"form/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache holds serialized results of hot reads. Every failure is treated
+// as a miss, so the database stays the source of truth.
+type Cache interface {
+	// Get returns errCacheMiss if key is not cached.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value for ttl; 0 keeps it until it is deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+var errCacheMiss = errors.New("cache miss")
+
+// newCache builds the cache selected by CACHE_BACKEND.
+func newCache(c config.Config) (Cache, error) {
+	switch c.CacheBackend {
+	case "", "none":
+		return noopCache{}, nil
+	case "memory":
+		return newMemoryCache(), nil
+	case "redis":
+		opts, err := redis.ParseURL(c.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		return redisCache{client: redis.NewClient(opts)}, nil
+	}
+	return nil, fmt.Errorf("unknown cache backend %q", c.CacheBackend)
+}
+
+type noopCache struct{}
+
+func (noopCache) Get(context.Context, string) ([]byte, error) { return nil, errCacheMiss }
+
+func (noopCache) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (noopCache) Delete(context.Context, ...string) error { return nil }
+
+// memoryCache is a per-process cache for development and single-instance
+// deployments.
+type memoryCache struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// memorySweepInterval is how often Set drops expired entries, which would
+// otherwise linger once nothing reads their keys.
+const memorySweepInterval = time.Minute
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: map[string]memoryEntry{}, lastSweep: time.Now()}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, errCacheMiss
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, errCacheMiss
+	}
+	return e.value, nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastSweep) > memorySweepInterval {
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	c.entries[key] = e
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+type redisCache struct {
+	client *redis.Client
+}
+
+func (c redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, errCacheMiss
+	}
+	return b, err
+}
+
+func (c redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c redisCache) Delete(ctx context.Context, keys ...string) error {
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// cacheGetJSON decodes a cached value into v, reporting whether it was
+// found.
+func (a *App) cacheGetJSON(ctx context.Context, key string, v interface{}) bool {
+	b, err := a.cache.Get(ctx, key)
+	if err != nil {
+		if err != errCacheMiss {
+			log.Printf("Error reading cache key %s: %s", key, err)
+		}
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func (a *App) cacheSetJSON(ctx context.Context, key string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = a.cache.Set(ctx, key, b, a.cfg.CacheTTL)
+	}
+	if err != nil {
+		log.Printf("Error writing cache key %s: %s", key, err)
+	}
+}
+
+// issueListGenerationKey holds a token that is part of every cached issue
+// list key. Replacing it invalidates all of them at once.
+const issueListGenerationKey = "issues:generation"
+
+func issueCacheKey(id uint) string {
+	return "issue:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (a *App) issueListGeneration(ctx context.Context) string {
+	if b, err := a.cache.Get(ctx, issueListGenerationKey); err == nil {
+		return string(b)
+	}
+	return a.bumpIssueListGeneration(ctx)
+}
+
+func (a *App) bumpIssueListGeneration(ctx context.Context) string {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := a.cache.Set(ctx, issueListGenerationKey, []byte(gen), 0); err != nil {
+		log.Println("Error invalidating cached issue lists:", err)
+	}
+	return gen
+}
+
+// invalidateIssue drops the cached copy of an issue and every cached issue
+// list. notifyLive calls it when the change is made and listenLive again on
+// every instance once it commits, so a read racing the transaction cannot
+// leave a stale copy behind.
+func (a *App) invalidateIssue(ctx context.Context, id uint) {
+	if _, ok := a.cache.(noopCache); ok {
+		return
+	}
+	if err := a.cache.Delete(ctx, issueCacheKey(id)); err != nil {
+		log.Printf("Error invalidating cached issue %d: %s", id, err)
+	}
+	a.bumpIssueListGeneration(ctx)
+}
+
+// cachedIssue is findIssue through the cache. Only read-only handlers use
+// it; anything that writes loads the issue fresh. Entries are shared by
+// every organization, so hits from another one count as missing.
+func (a *App) cachedIssue(ctx context.Context, id uint) (models.Issue, error) {
+	var issue models.Issue
+	key := issueCacheKey(id)
+	if a.cacheGetJSON(ctx, key, &issue) {
+		if !sameOrganization(ctx, issue.OrganizationID) {
+			return models.Issue{}, errIssueNotFound
+		}
+		return issue, nil
+	}
+	issue, err := a.findIssue(ctx, id)
+	if err == nil {
+		a.cacheSetJSON(ctx, key, issue)
+	}
+	return issue, err
+}
+
+// cachedAccessibleIssue is accessibleIssue through the cache.
+func (a *App) cachedAccessibleIssue(ctx context.Context, user *models.User, id uint) (models.Issue, error) {
+	issue, err := a.cachedIssue(ctx, id)
+	if err == nil && !canAccessIssue(user, issue) {
+		return models.Issue{}, errIssueNotFound
+	}
+	return issue, err
+}
+
+// cachedIssueList is listIssues through the cache. An organization's admins
+// share entries; everyone else's lists are cached per user.
+func (a *App) cachedIssueList(ctx context.Context, user *models.User, f issueFilter) ([]models.Issue, error) {
+	audience := "admin"
+	if !organizationAdmin(user) {
+		audience = "user:" + user.Username
+	}
+	audience = strconv.FormatUint(uint64(contextOrganization(ctx)), 10) + ":" + audience
+	filter, _ := json.Marshal(f)
+	sum := sha256.Sum256(append([]byte(audience+"\n"), filter...))
+	key := "issues:" + a.issueListGeneration(ctx) + ":" + hex.EncodeToString(sum[:])
+
+	var issues []models.Issue
+	if a.cacheGetJSON(ctx, key, &issues) {
+		return issues, nil
+	}
+	issues, err := a.repo.ListIssues(ctx, user, f)
+	if err == nil {
+		a.cacheSetJSON(ctx, key, issues)
+	}
+	return issues, err
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"form/models"
+)
+
+// issueComments returns an issue's comments, oldest first.
+func (a *App) issueComments(ctx context.Context, issueID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	if err := a.dbCtx(ctx).Where("issue_id = ?", issueID).Order("created_at, id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, attachCommentMentions(a.dbCtx(ctx), comments)
+}
+
+// addComment posts a comment on issue and notifies the users it mentions. A
+// reply from the reporter hands an issue waiting on them back to the team.
+func (a *App) addComment(ctx context.Context, issue *models.Issue, author, body string) (models.Comment, error) {
+	comment := models.Comment{IssueID: issue.ID, Author: author, Body: body}
+	if strings.TrimSpace(body) == "" {
+		return comment, newServiceError(http.StatusBadRequest, "Comment body is required")
+	}
+
+	tx := a.dbCtx(ctx).Begin()
+	err := tx.Create(&comment).Error
+	if err == nil {
+		err = a.recordIssueEvent(tx, issue.ID, eventCommentAdded, author, "", "")
+	}
+	if err == nil {
+		err = a.notifyLive(tx, liveCommentAdded, issue.ID, comment.ID)
+	}
+	if err == nil {
+		comment.Mentions, err = recordMentions(tx, issue, &comment.ID, author, body)
+	}
+	if err == nil && issue.State == stateWaitingOnReporter && author == issue.ReportedBy {
+		err = a.setIssueState(tx, issue, stateOpen, author)
+	}
+	if err != nil {
+		tx.Rollback()
+		return comment, err
+	}
+	return comment, tx.Commit().Error
+}
+
+func (a *App) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+	user, _ := currentUser(r)
+
+	var body commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	comment, err := a.addComment(r.Context(), &issue, user.Username, body.Body)
+	if err != nil {
+		writeServiceError(w, err, "Failed to add comment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, comment)
+}
+
+func (a *App) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := a.issueComments(r.Context(), issue.ID)
+	if err != nil {
+		writeServiceError(w, err, "Error loading comments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, comments)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// SourceConnector fetches contact records from an import source through
+// client. The first record returned is the header row.
+type SourceConnector interface {
+	Fetch(ctx context.Context, client *http.Client) ([][]string, error)
+}
+
+// connectorFactory builds a connector from its JSON configuration.
+type connectorFactory func(config json.RawMessage) (SourceConnector, error)
+
+var sourceConnectors = map[string]connectorFactory{}
+
+// registerConnector makes a source available to imports under name.
+func registerConnector(name string, factory connectorFactory) {
+	sourceConnectors[name] = factory
+}
+
+// newConnector builds the named connector from its configuration.
+func newConnector(name string, config json.RawMessage) (SourceConnector, error) {
+	factory, ok := sourceConnectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown import source %q", name)
+	}
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	return factory(config)
+}
+
+func init() {
+	registerConnector("url", newURLConnector)
+	registerConnector("google_sheets", newGoogleSheetsConnector)
+	registerConnector("s3", newS3Connector)
+	registerConnector("rest", newRESTConnector)
+}
+
+// maxSourceBytes caps how much a remote source may return.
+const maxSourceBytes = 100 << 20
+
+func parseCSV(r io.Reader) ([][]string, error) {
+	records, err := csv.NewReader(io.LimitReader(r, maxSourceBytes)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV: %w", err)
+	}
+	return records, nil
+}
+
+func httpGet(ctx context.Context, client *http.Client, rawURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+// csvFileConnector reads an uploaded CSV file.
+type csvFileConnector struct {
+	content []byte
+}
+
+func (c csvFileConnector) Fetch(context.Context, *http.Client) ([][]string, error) {
+	return parseCSV(bytes.NewReader(c.content))
+}
+
+// urlConnector downloads a CSV file over HTTP(S).
+type urlConnector struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+func newURLConnector(config json.RawMessage) (SourceConnector, error) {
+	var c urlConnector
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("url must be an absolute http(s) URL")
+	}
+	return c, nil
+}
+
+func (c urlConnector) Fetch(ctx context.Context, client *http.Client) ([][]string, error) {
+	header := http.Header{}
+	for k, v := range c.Headers {
+		header.Set(k, v)
+	}
+	resp, err := httpGet(ctx, client, c.URL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return parseCSV(resp.Body)
+}
+
+// googleSheetsConnector reads a sheet shared by link through its CSV export.
+type googleSheetsConnector struct {
+	SpreadsheetID string `json:"spreadsheetId"`
+	GID           string `json:"gid"`
+}
+
+func newGoogleSheetsConnector(config json.RawMessage) (SourceConnector, error) {
+	var c googleSheetsConnector
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, err
+	}
+	if c.SpreadsheetID == "" {
+		return nil, errors.New("spreadsheetId is required")
+	}
+	return c, nil
+}
+
+func (c googleSheetsConnector) Fetch(ctx context.Context, client *http.Client) ([][]string, error) {
+	q := url.Values{"format": {"csv"}}
+	if c.GID != "" {
+		q.Set("gid", c.GID)
+	}
+	exportURL := "https://docs.google.com/spreadsheets/d/" + url.PathEscape(c.SpreadsheetID) + "/export?" + q.Encode()
+	return urlConnector{URL: exportURL}.Fetch(ctx, client)
+}
+
+// s3Connector reads a CSV object from S3 with credentials the source is
+// configured with. The deployment's own AWS credentials are never used, so
+// one organization cannot read buckets the service account can.
+type s3Connector struct {
+	Bucket          string `json:"bucket"`
+	Key             string `json:"key"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken"`
+}
+
+func newS3Connector(config json.RawMessage) (SourceConnector, error) {
+	var c s3Connector
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Bucket == "" || c.Key == "" {
+		return nil, errors.New("bucket and key are required")
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, errors.New("accessKeyId and secretAccessKey are required")
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	return c, nil
+}
+
+func (c s3Connector) Fetch(ctx context.Context, client *http.Client) ([][]string, error) {
+	s3Client := s3.New(s3.Options{
+		Region:      c.Region,
+		Credentials: credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+		HTTPClient:  client,
+	})
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: &c.Bucket, Key: &c.Key})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return parseCSV(out.Body)
+}
+
+// restConnector polls a JSON API returning an array of objects. Fields picks
+// and orders the object keys used as columns; by default every key of the
+// first object is used.
+type restConnector struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Fields  []string          `json:"fields"`
+}
+
+func newRESTConnector(config json.RawMessage) (SourceConnector, error) {
+	var c restConnector
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("url must be an absolute http(s) URL")
+	}
+	return c, nil
+}
+
+func (c restConnector) Fetch(ctx context.Context, client *http.Client) ([][]string, error) {
+	header := http.Header{"Accept": {"application/json"}}
+	for k, v := range c.Headers {
+		header.Set(k, v)
+	}
+	resp, err := httpGet(ctx, client, c.URL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var items []map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSourceBytes)).Decode(&items); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	fields := c.Fields
+	if len(fields) == 0 && len(items) > 0 {
+		for k := range items[0] {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+	}
+
+	records := [][]string{fields}
+	for _, item := range items {
+		record := make([]string, len(fields))
+		for i, f := range fields {
+			if v, ok := item[f]; ok && v != nil {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of contact link. Merging keeps every address and profile of the
+// merged contacts that differs from the survivor's as a link.
+const (
+	linkEmail    = "email"
+	linkTwitter  = "twitter"
+	linkLinkedin = "linkedin"
+)
+
+// archivedMergedContact is the archive kind for contacts merged into
+// another.
+const archivedMergedContact = "merged_contact"
+
+// defaultNameSimilarity is how alike two normalized names must be, from 0
+// to 1, for GET /contacts/duplicates to pair them.
+const defaultNameSimilarity = 0.85
+
+// contactView is a contact with its links.
+type contactView struct {
+	models.Contact
+	Links []models.ContactLink `json:"links"`
+}
+
+// duplicateGroup is a set of contacts that look like the same person, and
+// why: "email" for addresses that normalize alike, "name" for similar names.
+type duplicateGroup struct {
+	Reasons  []string         `json:"reasons"`
+	Contacts []models.Contact `json:"contacts"`
+}
+
+type mergeRequest struct {
+	// TargetID is the contact that remains; SourceIDs are merged into it
+	// and removed.
+	TargetID  uint   `json:"targetId" validate:"required"`
+	SourceIDs []uint `json:"sourceIds" validate:"required,min=1,max=100,dive,required"`
+}
+
+// normalizeEmail reduces an address to the mailbox it delivers to: case
+// and surrounding space are ignored, as are +tags, and dots for Gmail.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// normalizeName lowercases a name, drops everything but letters and sorts
+// its words, so "Smith, John" and "john smith" compare equal.
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return !unicode.IsLetter(r) })
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// nameSimilarity scores two normalized names from 0 (nothing alike) to 1
+// (equal) by edit distance.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// findDuplicateContacts groups the contacts in ctx's organization that
+// share a normalized address or have names at least threshold alike.
+// Names are only compared within the same first letter, which keeps the
+// search fast and misses few real duplicates.
+func (a *App) findDuplicateContacts(ctx context.Context, threshold float64) ([]duplicateGroup, error) {
+	var contacts []models.Contact
+	if err := a.dbCtx(ctx).Order("id").Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	return groupDuplicateContacts(contacts, threshold), nil
+}
+
+// groupDuplicateContacts is findDuplicateContacts over contacts already
+// loaded. Groups keep the order of their first contact, and contacts the
+// order they were given in.
+func groupDuplicateContacts(contacts []models.Contact, threshold float64) []duplicateGroup {
+	// Union contacts into groups, remembering why each was joined
+	parent := make([]int, len(contacts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	reasons := map[int]map[string]bool{}
+	join := func(a, b int, reason string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+			for r := range reasons[rb] {
+				reasons[ra] = setAdd(reasons[ra], r)
+			}
+			delete(reasons, rb)
+		}
+		reasons[ra] = setAdd(reasons[ra], reason)
+	}
+
+	byEmail := map[string]int{}
+	byInitial := map[rune][]int{}
+	names := make([]string, len(contacts))
+	for i, c := range contacts {
+		if email := normalizeEmail(c.Email); email != "" {
+			if first, ok := byEmail[email]; ok {
+				join(first, i, "email")
+			} else {
+				byEmail[email] = i
+			}
+		}
+		if names[i] = normalizeName(c.FullName); names[i] != "" {
+			initial := []rune(names[i])[0]
+			byInitial[initial] = append(byInitial[initial], i)
+		}
+	}
+	for _, block := range byInitial {
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				if nameSimilarity(names[block[x]], names[block[y]]) >= threshold {
+					join(block[x], block[y], "name")
+				}
+			}
+		}
+	}
+
+	members := map[int][]models.Contact{}
+	var roots []int
+	for i, c := range contacts {
+		root := find(i)
+		if _, ok := reasons[root]; !ok {
+			continue
+		}
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], c)
+	}
+	groups := make([]duplicateGroup, 0, len(roots))
+	for _, root := range roots {
+		g := duplicateGroup{Contacts: members[root]}
+		for r := range reasons[root] {
+			g.Reasons = append(g.Reasons, r)
+		}
+		sort.Strings(g.Reasons)
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func setAdd(set map[string]bool, key string) map[string]bool {
+	if set == nil {
+		set = map[string]bool{}
+	}
+	set[key] = true
+	return set
+}
+
+// contactLinks returns the links of the given contacts, oldest first.
+func contactLinks(q *gorm.DB, ids ...uint) ([]models.ContactLink, error) {
+	links := []models.ContactLink{}
+	return links, q.Where("contact_id IN (?)", ids).Order("id").Find(&links).Error
+}
+
+// mergeContacts folds the source contacts into the target. The target
+// keeps its own values and takes any it lacks from the sources; every other
+// address and profile becomes one of its links. Sources are archived with
+// the ID they were merged into, then removed. A suppressed source
+// suppresses the target, so an opt-out always survives a merge.
+func (a *App) mergeContacts(ctx context.Context, targetID uint, sourceIDs []uint, actor string) (contactView, error) {
+	var view contactView
+	ids := append([]uint{targetID}, sourceIDs...)
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return view, newServiceError(http.StatusBadRequest, "Each contact can only be named once")
+		}
+		seen[id] = true
+	}
+
+	now := time.Now()
+	tx := a.dbCtx(ctx).Begin()
+	err := func() error {
+		var locked []models.Contact
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN (?)", ids).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != len(ids) {
+			return newServiceError(http.StatusNotFound, "Contact not found")
+		}
+		var target models.Contact
+		sources := make([]models.Contact, 0, len(sourceIDs))
+		for _, c := range locked {
+			if c.ID == targetID {
+				target = c
+			} else {
+				sources = append(sources, c)
+			}
+		}
+
+		existing, err := contactLinks(tx, ids...)
+		if err != nil {
+			return err
+		}
+		has := map[string]bool{}
+		add := func(kind, url string) {
+			url = strings.TrimSpace(url)
+			key := kind + " " + strings.ToLower(url)
+			if url == "" || has[key] {
+				return
+			}
+			has[key] = true
+			view.Links = append(view.Links, models.ContactLink{ContactID: target.ID, Kind: kind, URL: url})
+		}
+
+		for _, src := range sources {
+			if strings.TrimSpace(target.Email) == "" {
+				target.Email = src.Email
+			}
+			if target.FullName == "" {
+				target.FullName = src.FullName
+			}
+			if target.Timestamp == "" {
+				target.Timestamp = src.Timestamp
+			}
+			if target.TwitterProfile == "" {
+				target.TwitterProfile = src.TwitterProfile
+			}
+			if target.LinkedinProfile == "" {
+				target.LinkedinProfile = src.LinkedinProfile
+			}
+			if src.LastActiveAt.After(target.LastActiveAt) {
+				target.LastActiveAt = src.LastActiveAt
+			}
+			if src.Suppressed && !target.Suppressed {
+				target.Suppressed, target.SuppressedAt = true, src.SuppressedAt
+				target.SuppressionReason, target.SuppressionNote = src.SuppressionReason, src.SuppressionNote
+			}
+		}
+		// The target's own values are not links
+		has[linkEmail+" "+strings.ToLower(target.Email)] = true
+		has[linkTwitter+" "+strings.ToLower(target.TwitterProfile)] = true
+		has[linkLinkedin+" "+strings.ToLower(target.LinkedinProfile)] = true
+		for _, l := range existing {
+			if l.ContactID == target.ID {
+				has[l.Kind+" "+strings.ToLower(l.URL)] = true
+			}
+		}
+		for _, src := range sources {
+			add(linkEmail, src.Email)
+			add(linkTwitter, src.TwitterProfile)
+			add(linkLinkedin, src.LinkedinProfile)
+		}
+		for _, l := range existing {
+			if l.ContactID != target.ID {
+				add(l.Kind, l.URL)
+			}
+		}
+
+		for _, src := range sources {
+			var links []models.ContactLink
+			for _, l := range existing {
+				if l.ContactID == src.ID {
+					links = append(links, l)
+				}
+			}
+			data, err := json.Marshal(map[string]interface{}{"contact": src, "links": links, "mergedInto": target.ID})
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.ArchivedRecord{Kind: archivedMergedContact, RecordID: src.ID, Data: string(data), ArchivedAt: now}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("contact_id IN (?)", sourceIDs).Delete(&models.ContactLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN (?)", sourceIDs).Delete(&models.Contact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&target).Error; err != nil {
+			return err
+		}
+		if len(view.Links) > 0 {
+			if err := tx.Create(&view.Links).Error; err != nil {
+				return err
+			}
+		}
+		view.Contact = target
+		view.Links, err = contactLinks(tx, target.ID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, "contact.merged", archivedContact, target.ID, map[string]interface{}{"merged": sourceIDs})
+	}()
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return view, err
+}
+
+// listDuplicateContactsHandler lists groups of contacts that look like the
+// same person. threshold tunes how alike names must be.
+func (a *App) listDuplicateContactsHandler(w http.ResponseWriter, r *http.Request) {
+	threshold := defaultNameSimilarity
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		t, err := strconv.ParseFloat(raw, 64)
+		if err != nil || t <= 0 || t > 1 {
+			writeError(w, http.StatusBadRequest, "threshold must be a number above 0 and at most 1")
+			return
+		}
+		threshold = t
+	}
+	groups, err := a.findDuplicateContacts(r.Context(), threshold)
+	if err != nil {
+		writeDBError(w, err, "Error finding duplicates")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, groups)
+}
+
+func (a *App) mergeContactsHandler(w http.ResponseWriter, r *http.Request) {
+	var body mergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+	view, err := a.mergeContacts(r.Context(), body.TargetID, body.SourceIDs, actorName(r))
+	if err != nil {
+		writeServiceError(w, err, "Failed to merge contacts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, view)
+}
+
+// getContactHandler returns a contact with the links merges gave it.
+func (a *App) getContactHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	contact, err := a.findContact(r.Context(), uint(id))
+	if err != nil {
+		writeServiceError(w, err, "Error loading contact")
+		return
+	}
+	links, err := contactLinks(a.dbCtx(r.Context()), contact.ID)
+	if err != nil {
+		writeDBError(w, err, "Error loading contact")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, contactView{Contact: contact, Links: links})
+}
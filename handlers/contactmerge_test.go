@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"form/models"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Jane@Example.com", "jane@example.com"},
+		{"  jane@example.com ", "jane@example.com"},
+		{"jane+news@example.com", "jane@example.com"},
+		{"j.a.n.e@example.com", "j.a.n.e@example.com"},
+		{"J.Ane+x@Gmail.com", "jane@gmail.com"},
+		{"jane@googlemail.com", "jane@gmail.com"},
+		{"not-an-address", "not-an-address"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeEmail(tt.in); got != tt.want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"John Smith", "john smith"},
+		{"Smith, John", "john smith"},
+		{"  JOHN   smith ", "john smith"},
+		{"O'Brien", "brien o"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.in); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"john smith", "john smith", 1},
+		{"john smith", "jon smith", 0.9},
+		{"abc", "xyz", 0},
+		{"", "", 0},
+		{"ann", "", 0},
+		{"josé", "jose", 0.75},
+	}
+	for _, tt := range tests {
+		got := nameSimilarity(tt.a, tt.b)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("nameSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if back := nameSimilarity(tt.b, tt.a); back != got {
+			t.Errorf("nameSimilarity(%q, %q) = %v, but %v the other way round", tt.a, tt.b, got, back)
+		}
+	}
+}
+
+func TestGroupDuplicateContacts(t *testing.T) {
+	contacts := []models.Contact{
+		{ID: 1, Email: "jane@gmail.com", FullName: "Jane Doe"},
+		{ID: 2, Email: "bob@example.com", FullName: "Bob Stone"},
+		{ID: 3, Email: "J.ane+work@gmail.com", FullName: ""},
+		{ID: 4, Email: "robert@example.com", FullName: "Stone, Bob"},
+		{ID: 5, Email: "carol@example.com", FullName: "Carol King"},
+		// Joined to 1 by name, which also pulls in 3 through its address
+		{ID: 6, Email: "", FullName: "Jane Do"},
+		// Alike names with different first letters are never compared
+		{ID: 7, Email: "", FullName: "Karol King"},
+	}
+
+	groups := groupDuplicateContacts(contacts, defaultNameSimilarity)
+	got := make([][]uint, len(groups))
+	reasons := make([][]string, len(groups))
+	for i, g := range groups {
+		for _, c := range g.Contacts {
+			got[i] = append(got[i], c.ID)
+		}
+		reasons[i] = g.Reasons
+	}
+
+	wantIDs := [][]uint{{1, 3, 6}, {2, 4}}
+	if !reflect.DeepEqual(got, wantIDs) {
+		t.Fatalf("groups = %v, want %v", got, wantIDs)
+	}
+	wantReasons := [][]string{{"email", "name"}, {"name"}}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("reasons = %v, want %v", reasons, wantReasons)
+	}
+}
+
+func TestGroupDuplicateContactsThreshold(t *testing.T) {
+	contacts := []models.Contact{
+		{ID: 1, FullName: "John Smith"},
+		{ID: 2, FullName: "Jon Smith"},
+	}
+	if groups := groupDuplicateContacts(contacts, 0.95); len(groups) != 0 {
+		t.Errorf("threshold 0.95 grouped %d, want none", len(groups))
+	}
+	if groups := groupDuplicateContacts(contacts, 0.9); len(groups) != 1 {
+		t.Errorf("threshold 0.9 grouped %d, want one", len(groups))
+	}
+	if groups := groupDuplicateContacts(nil, defaultNameSimilarity); len(groups) != 0 {
+		t.Errorf("no contacts grouped %d, want none", len(groups))
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Suppression reasons.
+const (
+	suppressedByRequest   = "user_request"
+	suppressedByAdmin     = "admin"
+	suppressedByBounce    = "bounce"
+	suppressedByComplaint = "complaint"
+)
+
+// activeContacts scopes a query to contacts that have not been suppressed.
+func (a *App) activeContacts(ctx context.Context) *gorm.DB {
+	return a.dbCtx(ctx).Model(&models.Contact{}).Where("suppressed = ?", false)
+}
+
+func (a *App) unsubscribeSignature(email string) string {
+	return a.signParts("unsubscribe", strings.ToLower(email))
+}
+
+// unsubscribeURL returns the public link a contact follows to opt out.
+func (a *App) unsubscribeURL(email string) string {
+	q := url.Values{"email": {email}, "sig": {a.unsubscribeSignature(email)}}
+	return strings.TrimRight(a.cfg.PublicURL, "/") + "/unsubscribe?" + q.Encode()
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>{{.Email}} has been unsubscribed.</p>{{else}}
+<form method="post">
+<p>Stop receiving messages at {{.Email}}?</p>
+<input type="hidden" name="email" value="{{.Email}}">
+<input type="hidden" name="sig" value="{{.Sig}}">
+<label>Reason (optional) <input type="text" name="reason" maxlength="500"></label>
+<button type="submit">Unsubscribe</button>
+</form>{{end}}
+</body></html>`))
+
+// unsubscribeHandler is the public opt-out endpoint. GET shows a
+// confirmation form so link scanners cannot unsubscribe anyone; POST
+// suppresses the contact.
+func (a *App) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	email := r.Form.Get("email")
+	sig := r.Form.Get("sig")
+	if email == "" || !hmac.Equal([]byte(sig), []byte(a.unsubscribeSignature(email))) {
+		writeError(w, http.StatusForbidden, "Invalid unsubscribe link")
+		return
+	}
+
+	page := struct {
+		Email, Sig string
+		Done       bool
+	}{Email: email, Sig: sig}
+
+	if r.Method == "POST" {
+		note := r.Form.Get("reason")
+		if len(note) > 500 {
+			note = note[:500]
+		}
+		// The link is for the address, so it opts out of every organization
+		if _, err := a.repo.SuppressContacts(allOrganizations(r.Context()), email, suppressedByRequest, note); err != nil {
+			log.Println("Error suppressing contact:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to unsubscribe")
+			return
+		}
+		// Respond the same whether or not the address was known
+		page.Done = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, page)
+}
+
+type suppressionRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Reason string `json:"reason" validate:"omitempty,oneof=user_request admin bounce complaint"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+// The functions below are the contact service shared by the REST handlers
+// and the gRPC server.
+
+// findContact loads a contact by ID.
+func (a *App) findContact(ctx context.Context, id uint) (models.Contact, error) {
+	contact, err := a.repo.FindContact(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return contact, newServiceError(http.StatusNotFound, "Contact not found")
+	}
+	return contact, err
+}
+
+// suppressByRequest validates req and suppresses every active contact with
+// its email, returning how many were suppressed.
+func (a *App) suppressByRequest(ctx context.Context, req suppressionRequest) (int64, error) {
+	if err := validateInput(&req); err != nil {
+		return 0, err
+	}
+	if req.Reason == "" {
+		req.Reason = suppressedByAdmin
+	}
+
+	n, err := a.repo.SuppressContacts(ctx, req.Email, req.Reason, req.Note)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, newServiceError(http.StatusNotFound, "No active contact with that email")
+	}
+	return n, nil
+}
+
+func (a *App) createSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	var body suppressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	n, err := a.suppressByRequest(r.Context(), body)
+	if err != nil {
+		writeServiceError(w, err, "Failed to suppress contact")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"suppressed": n})
+}
+
+func (a *App) deleteSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	result := a.dbCtx(r.Context()).Model(&models.Contact{}).Where("id = ? AND suppressed = ?", mux.Vars(r)["id"], true).Updates(map[string]interface{}{
+		"suppressed":         false,
+		"suppressed_at":      nil,
+		"suppression_reason": "",
+		"suppression_note":   "",
+	})
+	if result.Error != nil {
+		log.Println("Error lifting suppression:", result.Error)
+		writeError(w, http.StatusInternalServerError, "Failed to lift suppression")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusNotFound, "Suppressed contact not found")
+		return
+	}
+
+	// Let email reach the address again even if it once bounced
+	var contact models.Contact
+	if err := a.dbCtx(r.Context()).First(&contact, mux.Vars(r)["id"]).Error; err == nil {
+		a.dbCtx(r.Context()).Where("email = lower(?)", contact.Email).Delete(&models.UndeliverableAddress{})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// suppressionReportHandler lists suppressed contacts with totals per reason.
+func (a *App) suppressionReportHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	var byReason []struct {
+		Reason string `json:"reason"`
+		Count  int    `json:"count"`
+	}
+	if err := a.dbCtx(r.Context()).Model(&models.Contact{}).Where("suppressed = ?", true).
+		Select("suppression_reason as reason, count(*) as count").Group("suppression_reason").
+		Scan(&byReason).Error; err != nil {
+		log.Println("Error building suppression report:", err)
+		writeError(w, http.StatusInternalServerError, "Error building suppression report")
+		return
+	}
+
+	var contacts []models.Contact
+	q := a.dbCtx(r.Context()).Where("suppressed = ?", true)
+	if reason := r.URL.Query().Get("reason"); reason != "" {
+		q = q.Where("suppression_reason = ?", reason)
+	}
+	if err := q.Order("suppressed_at desc").Limit(limit).Offset(offset).Find(&contacts).Error; err != nil {
+		log.Println("Error building suppression report:", err)
+		writeError(w, http.StatusInternalServerError, "Error building suppression report")
+		return
+	}
+
+	total := 0
+	for _, c := range byReason {
+		total += c.Count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"total":    total,
+		"byReason": byReason,
+		"contacts": contacts,
+	})
+}
+
+// contactUnsubscribeLinkHandler returns the signed opt-out link for a
+// contact, for inclusion in messages sent outside this service.
+func (a *App) contactUnsubscribeLinkHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	contact, err := a.findContact(r.Context(), uint(id))
+	if err != nil {
+		writeServiceError(w, err, "Error loading contact")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]string{"url": a.unsubscribeURL(contact.Email)})
+}
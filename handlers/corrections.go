@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm/clause"
+)
+
+// Correction request statuses.
+const (
+	correctionPending  = "pending"
+	correctionApplied  = "applied"
+	correctionRejected = "rejected"
+)
+
+// correctionSubject describes a record type that can be corrected, the
+// fields its owner may ask to change keyed by JSON name, and the validation
+// rules new values must pass.
+type correctionSubject struct {
+	model  func() interface{}
+	fields map[string]string
+	rules  map[string]string
+}
+
+var correctionSubjects = map[string]correctionSubject{
+	"contact": {
+		model: func() interface{} { return &models.Contact{} },
+		fields: map[string]string{
+			"email":           "email",
+			"fullName":        "full_name",
+			"twitterProfile":  "twitter_profile",
+			"linkedinProfile": "linkedin_profile",
+		},
+		rules: map[string]string{
+			"email":           "required,email,max=255",
+			"fullName":        "max=255",
+			"twitterProfile":  "max=255",
+			"linkedinProfile": "max=255",
+		},
+	},
+	"user": {
+		model:  func() interface{} { return &models.User{} },
+		fields: map[string]string{"username": "username", "displayName": "display_name", "email": "email"},
+		rules:  map[string]string{"username": "required,min=3,max=64", "displayName": "max=255", "email": "omitempty,email,max=255"},
+	},
+}
+
+func (a *App) correctionSignature(subjectType, subjectID string, expires int64) string {
+	return a.signParts("correction", subjectType, subjectID, strconv.FormatInt(expires, 10))
+}
+
+// correctionURL returns the signed link a subject follows to review and
+// correct their data.
+func (a *App) correctionURL(subjectType string, subjectID uint, expires time.Time) string {
+	id := strconv.FormatUint(uint64(subjectID), 10)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", a.correctionSignature(subjectType, id, expires.Unix()))
+	return strings.TrimRight(a.cfg.PublicURL, "/") + "/corrections/" + subjectType + "/" + id + "?" + q.Encode()
+}
+
+// correctableValues returns the current values of the subject's correctable
+// fields.
+func correctableValues(subject correctionSubject, record interface{}) map[string]interface{} {
+	b, _ := json.Marshal(record)
+	all := map[string]interface{}{}
+	json.Unmarshal(b, &all)
+
+	values := map[string]interface{}{}
+	for field := range subject.fields {
+		values[field] = all[field]
+	}
+	return values
+}
+
+// loadCorrectionSubject verifies the signed link and loads the record it
+// points at, writing an error response on failure.
+func (a *App) loadCorrectionSubject(w http.ResponseWriter, r *http.Request) (string, correctionSubject, interface{}, bool) {
+	vars := mux.Vars(r)
+	subjectType, id := vars["type"], vars["id"]
+	subject, ok := correctionSubjects[subjectType]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return "", subject, nil, false
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	sig := r.URL.Query().Get("sig")
+	if err != nil || !hmac.Equal([]byte(sig), []byte(a.correctionSignature(subjectType, id, expires))) {
+		writeError(w, http.StatusForbidden, "Invalid correction link")
+		return "", subject, nil, false
+	}
+	if time.Now().Unix() > expires {
+		writeError(w, http.StatusForbidden, "Link expired")
+		return "", subject, nil, false
+	}
+
+	// The signed link names the record, whichever organization holds it
+	record := subject.model()
+	if err := a.dbCtx(allOrganizations(r.Context())).First(record, id).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return "", subject, nil, false
+	}
+	return subjectType, subject, record, true
+}
+
+// getCorrectionSubjectHandler shows a subject the data stored about them.
+func (a *App) getCorrectionSubjectHandler(w http.ResponseWriter, r *http.Request) {
+	_, subject, record, ok := a.loadCorrectionSubject(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, correctableValues(subject, record))
+}
+
+// submitCorrectionHandler records a subject's correction request for review.
+func (a *App) submitCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	subjectType, subject, record, ok := a.loadCorrectionSubject(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Changes map[string]string `json:"changes"`
+		Reason  string            `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if len(body.Changes) == 0 {
+		writeError(w, http.StatusBadRequest, "No changes requested")
+		return
+	}
+	var invalid []fieldError
+	for field, value := range body.Changes {
+		if _, ok := subject.fields[field]; !ok {
+			writeErrorf(w, http.StatusBadRequest, "Field %s cannot be corrected", field)
+			return
+		}
+		if fe := validateValue("changes."+field, value, subject.rules[field]); fe != nil {
+			invalid = append(invalid, *fe)
+		}
+	}
+	if len(invalid) > 0 {
+		writeValidationErrors(w, invalid)
+		return
+	}
+
+	subjectID, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	changes, _ := json.Marshal(body.Changes)
+	req := models.CorrectionRequest{
+		SubjectType: subjectType,
+		SubjectID:   uint(subjectID),
+		Changes:     string(changes),
+		Reason:      body.Reason,
+		Status:      correctionPending,
+	}
+
+	tx := a.dbCtx(withOrganization(r.Context(), recordOrganization(record))).Begin()
+	if err := tx.Create(&req).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error creating correction request:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to submit request")
+		return
+	}
+	details := map[string]interface{}{"requestId": req.ID, "changes": body.Changes, "current": correctableValues(subject, record)}
+	if err := recordAudit(tx, subjectType+":"+mux.Vars(r)["id"], "correction.requested", subjectType, req.SubjectID, details); err != nil {
+		tx.Rollback()
+		log.Println("Error recording audit entry:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to submit request")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error creating correction request:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to submit request")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, map[string]interface{}{"id": req.ID, "status": req.Status})
+}
+
+func (a *App) correctionLinkHandler(w http.ResponseWriter, r *http.Request) {
+	subjectType := r.URL.Query().Get("subjectType")
+	subject, ok := correctionSubjects[subjectType]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Unknown subject type")
+		return
+	}
+	subjectID, err := strconv.ParseUint(r.URL.Query().Get("subjectId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid subject ID")
+		return
+	}
+	if err := a.dbCtx(r.Context()).First(subject.model(), uint(subjectID)).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+
+	expires := time.Now().Add(a.cfg.CorrectionLinkTTL)
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"url":       a.correctionURL(subjectType, uint(subjectID), expires),
+		"expiresAt": expires,
+	})
+}
+
+func (a *App) listCorrectionsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = correctionPending
+	}
+
+	var requests []models.CorrectionRequest
+	if err := a.dbCtx(r.Context()).Where("status = ?", status).Order("created_at").Limit(500).Find(&requests).Error; err != nil {
+		log.Println("Error loading correction requests:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading correction requests")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, requests)
+}
+
+// reviewCorrectionHandler applies or rejects a pending correction request,
+// depending on the action in the URL.
+func (a *App) reviewCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	action := mux.Vars(r)["action"]
+
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	var req models.CorrectionRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&req, mux.Vars(r)["id"]).Error; err != nil {
+		tx.Rollback()
+		writeError(w, http.StatusNotFound, "Correction request not found")
+		return
+	}
+	if req.Status != correctionPending {
+		tx.Rollback()
+		writeErrorf(w, http.StatusConflict, "Correction request already %s", req.Status)
+		return
+	}
+
+	details := map[string]interface{}{"requestId": req.ID, "note": body.Note}
+	now := time.Now()
+	req.ReviewedBy = user.Username
+	req.ReviewNote = body.Note
+	req.ReviewedAt = &now
+
+	if action == "apply" {
+		subject := correctionSubjects[req.SubjectType]
+		record := subject.model()
+		if err := tx.First(record, req.SubjectID).Error; err != nil {
+			tx.Rollback()
+			writeError(w, http.StatusConflict, "Record no longer exists")
+			return
+		}
+
+		var changes map[string]string
+		json.Unmarshal([]byte(req.Changes), &changes)
+		updates := map[string]interface{}{}
+		for field, value := range changes {
+			updates[subject.fields[field]] = value
+		}
+
+		previous := correctableValues(subject, record)
+		if err := tx.Model(record).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			log.Println("Error applying correction:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to apply correction")
+			return
+		}
+
+		prev, _ := json.Marshal(previous)
+		req.Previous = string(prev)
+		req.Status = correctionApplied
+		details["previous"] = previous
+		details["changes"] = changes
+	} else {
+		req.Status = correctionRejected
+	}
+
+	if err := tx.Save(&req).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error saving correction request:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to review request")
+		return
+	}
+	if err := recordAudit(tx, user.Username, "correction."+req.Status, req.SubjectType, req.SubjectID, details); err != nil {
+		tx.Rollback()
+		log.Println("Error recording audit entry:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to review request")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error saving correction request:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to review request")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, req)
+}
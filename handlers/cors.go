@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsMiddleware lets browser clients on the configured origins call the
+// API. It wraps the whole router rather than being added with r.Use, because
+// preflight OPTIONS requests match no route and would otherwise get a 405.
+func (a *App) corsMiddleware(next http.Handler) http.Handler {
+	methods := strings.Join(a.cfg.CORSAllowedMethods, ", ")
+	headers := strings.Join(a.cfg.CORSAllowedHeaders, ", ")
+	exposed := strings.Join(a.cfg.CORSExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(a.cfg.CORSMaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		// Unknown origins get no CORS headers, so the browser blocks them
+		if !a.corsOriginAllowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A wildcard may not be combined with credentials, so echo the origin
+		if a.cfg.CORSAllowCredentials || !a.corsAllowsAnyOrigin() {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if a.cfg.CORSAllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) corsAllowsAnyOrigin() bool {
+	for _, allowed := range a.cfg.CORSAllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// corsOriginAllowed matches origin against the allowed list. Entries are
+// exact origins, "*", or a scheme with a wildcard subdomain such as
+// "https://*.example.com".
+func (a *App) corsOriginAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range a.cfg.CORSAllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == "*", allowed == origin:
+			return true
+		case strings.Contains(allowed, "://*."):
+			i := strings.Index(allowed, "*")
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
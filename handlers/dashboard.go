@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+)
+
+const dashboardListSize = 10
+
+// openIssues scopes a query to issues that have not been closed. An issue's
+// Status is set once it is closed.
+func (a *App) openIssues(ctx context.Context) *gorm.DB {
+	return a.dbCtx(ctx).Model(&models.Issue{}).Where("status = ?", false)
+}
+
+type issueSummary struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Priority  int       `json:"priority"`
+	Status    bool      `json:"status"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type priorityCount struct {
+	Priority int `json:"priority"`
+	Count    int `json:"count"`
+}
+
+type dashboard struct {
+	MyOpenIssues struct {
+		Count  int64          `json:"count"`
+		Issues []issueSummary `json:"issues"`
+	} `json:"myOpenIssues"`
+	Queue struct {
+		Open       int64           `json:"open"`
+		ByPriority []priorityCount `json:"byPriority"`
+	} `json:"queue"`
+	RecentActivity []issueSummary `json:"recentActivity"`
+	// Overdue counts open issues past their due date, as overdue=true
+	// lists them.
+	Overdue struct {
+		Count int64 `json:"count"`
+		Mine  int64 `json:"mine"`
+	} `json:"overdue"`
+}
+
+// dashboardHandler assembles everything the home screen needs in one
+// response, running the independent queries concurrently.
+func (a *App) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	now := time.Now()
+
+	var d dashboard
+
+	// Non-admins only see activity on issues they may open
+	visible := func() *gorm.DB {
+		return visibleIssues(a.dbCtx(r.Context()).Model(&models.Issue{}), user)
+	}
+
+	queries := []func() error{
+		func() error {
+			mine := a.openIssues(r.Context()).Where("reported_by = ?", user.Username).Session(&gorm.Session{})
+			if err := mine.Count(&d.MyOpenIssues.Count).Error; err != nil {
+				return err
+			}
+			return mine.Order("priority desc, updated_at desc").Limit(dashboardListSize).Scan(&d.MyOpenIssues.Issues).Error
+		},
+		func() error {
+			return a.openIssues(r.Context()).Count(&d.Queue.Open).Error
+		},
+		func() error {
+			return a.openIssues(r.Context()).Select("priority, count(*) as count").Group("priority").Order("priority desc").Scan(&d.Queue.ByPriority).Error
+		},
+		func() error {
+			return visible().Order("updated_at desc").Limit(dashboardListSize).Scan(&d.RecentActivity).Error
+		},
+		func() error {
+			return a.openIssues(r.Context()).Where("due_at < ?", now).Count(&d.Overdue.Count).Error
+		},
+		func() error {
+			return a.openIssues(r.Context()).Where("due_at < ? AND reported_by = ?", now, user.Username).Count(&d.Overdue.Mine).Error
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(queries))
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q func() error) {
+			defer wg.Done()
+			errs[i] = q()
+		}(i, q)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			log.Println("Error building dashboard:", err)
+			writeError(w, http.StatusInternalServerError, "Error building dashboard")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, d)
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"form/config"
+	"form/models"
+	"form/storage"
+
+	"gorm.io/gorm"
+)
+
+// secretConfigSuffixes mark Config fields whose values are never shown.
+var secretConfigSuffixes = []string{"Key", "Keys", "Password", "Secret", "Token"}
+
+type migrationStatus struct {
+	Version uint   `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	Latest  uint   `json:"latest"`
+	Error   string `json:"error,omitempty"`
+}
+
+// tableDrift lists differences between a model and its table.
+type tableDrift struct {
+	Table string `json:"table"`
+	// Missing is true when the table does not exist at all.
+	Missing bool `json:"missing,omitempty"`
+	// MissingColumns are model fields with no column in the table.
+	MissingColumns []string `json:"missingColumns,omitempty"`
+	// ExtraColumns are table columns no model field maps to.
+	ExtraColumns []string `json:"extraColumns,omitempty"`
+}
+
+type dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+type debugConfigResponse struct {
+	Version      string                 `json:"version"`
+	GitSHA       string                 `json:"gitSHA"`
+	GoVersion    string                 `json:"goVersion"`
+	Postgres     string                 `json:"postgres"`
+	Config       map[string]interface{} `json:"config"`
+	Settings     Settings               `json:"settings"`
+	Migrations   migrationStatus        `json:"migrations"`
+	SchemaDrift  []tableDrift           `json:"schemaDrift"`
+	Dependencies []dependency           `json:"dependencies"`
+}
+
+// debugConfigHandler shows what this instance is actually running with, for
+// supporting self-hosted installs: effective configuration with secrets
+// masked, schema version and drift, and dependency versions.
+func (a *App) debugConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	resp := debugConfigResponse{
+		Version:      version,
+		GitSHA:       buildSHA(),
+		GoVersion:    runtime.Version(),
+		Config:       maskedConfig(a.cfg),
+		Settings:     a.currentSettings(),
+		Migrations:   a.currentMigrationStatus(ctx),
+		Dependencies: dependencies(),
+	}
+	versionQuery := "SELECT version()"
+	if storage.UsingSQLite(a.dbCtx(ctx)) {
+		versionQuery = "SELECT 'SQLite ' || sqlite_version()"
+	}
+	if err := a.dbCtx(ctx).Raw(versionQuery).Row().Scan(&resp.Postgres); err != nil {
+		resp.Postgres = "unavailable: " + err.Error()
+	}
+
+	drift, err := a.schemaDrift(ctx)
+	if err != nil {
+		writeDBError(w, err, "Error checking schema")
+		return
+	}
+	resp.SchemaDrift = drift
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, resp)
+}
+
+// maskedConfig returns the configuration keyed by field name, with secrets
+// replaced and credentials removed from the database URL.
+func maskedConfig(c config.Config) map[string]interface{} {
+	out := map[string]interface{}{}
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		value := v.Field(i).Interface()
+		switch {
+		case isSecretConfigField(name):
+			if v.Field(i).Len() > 0 {
+				value = "********"
+			} else {
+				value = ""
+			}
+		case name == "DatabaseURL" || name == "RedisURL":
+			value = redactURL(value.(string))
+		default:
+			if d, ok := value.(time.Duration); ok {
+				value = d.String()
+			}
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func isSecretConfigField(name string) bool {
+	for _, suffix := range secretConfigSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "********"
+	}
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+func (a *App) currentMigrationStatus(ctx context.Context) migrationStatus {
+	var s migrationStatus
+	latest, err := storage.LatestMigration()
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	s.Latest = latest
+	if storage.UsingSQLite(a.dbCtx(ctx)) {
+		s.Error = "SQLite schema is created from the models"
+		return s
+	}
+	row := a.dbCtx(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Row()
+	if err := row.Scan(&s.Version, &s.Dirty); err != nil {
+		s.Error = "no migrations applied"
+	}
+	return s
+}
+
+// schemaDrift compares each model's columns with the table in the
+// database, returning only tables that differ.
+func (a *App) schemaDrift(ctx context.Context) ([]tableDrift, error) {
+	query := `SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema()`
+	if storage.UsingSQLite(a.dbCtx(ctx)) {
+		query = `SELECT m.name, p.name FROM sqlite_master AS m JOIN pragma_table_info(m.name) AS p
+			WHERE m.type = 'table'`
+	}
+	rows, err := a.dbCtx(ctx).Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := map[string]map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if columns[table] == nil {
+			columns[table] = map[string]bool{}
+		}
+		columns[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	drift := []tableDrift{}
+	for _, model := range models.All {
+		stmt := &gorm.Statement{DB: a.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		d := tableDrift{Table: stmt.Table}
+		actual, ok := columns[d.Table]
+		if !ok {
+			d.Missing = true
+			drift = append(drift, d)
+			continue
+		}
+
+		expected := map[string]bool{}
+		for _, column := range stmt.Schema.DBNames {
+			expected[column] = true
+			if !actual[column] {
+				d.MissingColumns = append(d.MissingColumns, column)
+			}
+		}
+		for column := range actual {
+			if !expected[column] {
+				d.ExtraColumns = append(d.ExtraColumns, column)
+			}
+		}
+		if len(d.MissingColumns) > 0 || len(d.ExtraColumns) > 0 {
+			sort.Strings(d.MissingColumns)
+			sort.Strings(d.ExtraColumns)
+			drift = append(drift, d)
+		}
+	}
+	return drift, nil
+}
+
+func dependencies() []dependency {
+	deps := []dependency{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return deps
+	}
+	for _, m := range info.Deps {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		deps = append(deps, dependency{Path: m.Path, Version: m.Version})
+	}
+	return deps
+}
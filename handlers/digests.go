@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Digest channels and frequencies.
+const (
+	digestEmail = "email"
+	digestSlack = "slack"
+
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+const (
+	digestBatchSize = 100
+	// digestTopIssues is how many of the highest priority open issues a
+	// digest lists.
+	digestTopIssues = 5
+)
+
+type digestRequest struct {
+	Channel   string `json:"channel" validate:"required,oneof=email slack"`
+	Target    string `json:"target" validate:"max=2048"`
+	Frequency string `json:"frequency" validate:"required,oneof=daily weekly"`
+}
+
+type digestUpdate struct {
+	Frequency string `json:"frequency" validate:"required,oneof=daily weekly"`
+}
+
+// digestReport is what a digest tells its recipient about one period, and
+// the locale it is written to them in.
+type digestReport struct {
+	Locale       string         `json:"locale"`
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	NewIssues    int64          `json:"newIssues"`
+	ClosedIssues int64          `json:"closedIssues"`
+	TopOpen      []issueSummary `json:"topOpen"`
+	Imports      struct {
+		Jobs     int64 `json:"jobs"`
+		Inserted int64 `json:"inserted"`
+	} `json:"imports"`
+}
+
+var errDigestNotFound = newServiceError(http.StatusNotFound, "Digest subscription not found")
+
+// digestPeriod is how much time one digest covers.
+func digestPeriod(frequency string) time.Duration {
+	if frequency == digestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// nextDigestRun returns when a digest sent after the given time is next
+// due: at the configured hour (UTC) every day, or every Monday for weekly
+// digests.
+func (a *App) nextDigestRun(frequency string, after time.Time) time.Time {
+	hour := a.currentSettings().DigestHourUTC
+	if hour < 0 || hour > 23 {
+		hour = defaultSettings.DigestHourUTC
+	}
+	after = after.UTC()
+	next := time.Date(after.Year(), after.Month(), after.Day(), hour, 0, 0, 0, time.UTC)
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	for frequency == digestWeekly && next.Weekday() != time.Monday {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// digestStart is where the period of a digest sent now begins: when the
+// last one was sent, but never more than one period back.
+func digestStart(sub models.DigestSubscription, now time.Time) time.Time {
+	from := now.Add(-digestPeriod(sub.Frequency))
+	if sub.LastSentAt != nil && sub.LastSentAt.After(from) {
+		from = *sub.LastSentAt
+	}
+	return from
+}
+
+// buildDigest summarises ctx's organization between from and to for the
+// subscription's owner. The counts cover the whole organization; the open
+// issues listed are only ones the owner may see, and none once they have
+// left it.
+func (a *App) buildDigest(ctx context.Context, sub models.DigestSubscription, from, to time.Time) (digestReport, error) {
+	report := digestReport{From: from, To: to, TopOpen: []issueSummary{}}
+	viewer, err := a.organizationMember(ctx, sub.Owner)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		viewer, err = nil, nil
+	}
+	if err != nil {
+		return report, err
+	}
+	recipient := models.User{}
+	if viewer != nil {
+		recipient = *viewer
+	}
+	report.Locale = recipientLocale(a.dbCtx(ctx), recipient, contextOrganization(ctx))
+
+	err = a.dbCtx(ctx).Model(&models.Issue{}).Where("created_at >= ? AND created_at < ?", from, to).Count(&report.NewIssues).Error
+	if err == nil {
+		err = a.dbCtx(ctx).Raw(`SELECT count(DISTINCT issue_events.issue_id) FROM issue_events
+			JOIN issues ON issues.id = issue_events.issue_id
+			WHERE issues.organization_id = ? AND issues.deleted_at IS NULL
+				AND issue_events.type = ? AND issue_events.to_value = ?
+				AND issue_events.created_at >= ? AND issue_events.created_at < ?`,
+			contextOrganization(ctx), eventStateChanged, stateClosed, from, to).Row().Scan(&report.ClosedIssues)
+	}
+	if err == nil && viewer != nil {
+		err = visibleIssues(a.openIssues(ctx), viewer).Order("priority desc, created_at").Limit(digestTopIssues).Scan(&report.TopOpen).Error
+	}
+	if err == nil {
+		err = a.dbCtx(ctx).Model(&models.ImportJob{}).Select("count(*) AS jobs, COALESCE(sum(inserted), 0) AS inserted").
+			Where("status = ? AND finished_at >= ? AND finished_at < ?", importCompleted, from, to).Scan(&report.Imports).Error
+	}
+	return report, err
+}
+
+// digestSubject is the email subject line for a digest.
+func digestSubject(frequency string, report digestReport) string {
+	format := "Daily digest: %d new, %d closed"
+	if frequency == digestWeekly {
+		format = "Weekly digest: %d new, %d closed"
+	}
+	return fmt.Sprintf(translate(report.Locale, format), report.NewIssues, report.ClosedIssues)
+}
+
+// renderDigest formats a digest as plain text, for email and Slack alike.
+func renderDigest(report digestReport) string {
+	locale := report.Locale
+	stamp := func(t time.Time) string { return formatDate(locale, t.UTC()) + " " + t.UTC().Format("15:04") }
+	var b strings.Builder
+	fmt.Fprintf(&b, translate(locale, "Activity from %s to %s (UTC)")+"\n\n", stamp(report.From), stamp(report.To))
+	b.WriteString(pluralf(locale, int(report.NewIssues), "%d new issue", "%d new issues") + "\n")
+	b.WriteString(pluralf(locale, int(report.ClosedIssues), "%d issue closed", "%d issues closed") + "\n")
+	b.WriteString(pluralf(locale, int(report.Imports.Inserted), "%d contact imported", "%d contacts imported") + "\n")
+	b.WriteString(pluralf(locale, int(report.Imports.Jobs), "%d import completed", "%d imports completed") + "\n")
+	if len(report.TopOpen) > 0 {
+		b.WriteString("\n" + translate(locale, "Top open issues:") + "\n")
+		for _, issue := range report.TopOpen {
+			fmt.Fprintf(&b, translate(locale, "- #%d %s (priority %d)")+"\n", issue.ID, issue.Title, issue.Priority)
+		}
+	}
+	return b.String()
+}
+
+// runDigests sends the digests that are due on every tick until ctx is
+// cancelled.
+func (a *App) runDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.sendDueDigests(ctx, time.Now()); err != nil {
+				log.Println("Error sending digests:", err)
+			}
+		}
+	}
+}
+
+// sendDueDigests sends every digest due by now, each inside its own
+// organization.
+func (a *App) sendDueDigests(ctx context.Context, now time.Time) error {
+	var due []models.DigestSubscription
+	if err := a.dbCtx(allOrganizations(ctx)).Where("next_run_at <= ?", now).Order("next_run_at").
+		Limit(digestBatchSize).Find(&due).Error; err != nil {
+		return err
+	}
+	for _, sub := range due {
+		if err := a.sendDigest(withOrganization(ctx, sub.OrganizationID), sub, now); err != nil {
+			log.Printf("Error sending digest %d: %s", sub.ID, err)
+		}
+	}
+	return nil
+}
+
+// sendDigest queues one digest and schedules the next. The conditional
+// update lets only one instance send it.
+func (a *App) sendDigest(ctx context.Context, sub models.DigestSubscription, now time.Time) error {
+	report, err := a.buildDigest(ctx, sub, digestStart(sub, now), now)
+	if err != nil {
+		return err
+	}
+
+	tx := a.dbCtx(ctx).Begin()
+	err = func() error {
+		result := tx.Model(&models.DigestSubscription{}).Where("id = ? AND next_run_at = ?", sub.ID, sub.NextRunAt).
+			Updates(map[string]interface{}{"next_run_at": a.nextDigestRun(sub.Frequency, now), "last_sent_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		text := renderDigest(report)
+		if sub.Channel == digestSlack {
+			_, err := enqueueJob(tx, jobPostDigest, slackDigest{SubscriptionID: sub.ID, Text: text}, now)
+			return err
+		}
+		_, err := a.queueEmail(tx, sub.Target, digestSubject(sub.Frequency, report), text, systemActor)
+		return err
+	}()
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return err
+}
+
+// jobPostDigest is the job kind for posting a digest to Slack.
+const jobPostDigest = "digest.slack"
+
+func init() {
+	registerJobHandler(jobPostDigest, (*App).postDigestJob)
+}
+
+// slackDigest is the payload of a Slack digest job. The webhook URL is
+// read from the subscription so it stays out of the job list.
+type slackDigest struct {
+	SubscriptionID uint   `json:"subscriptionId"`
+	Text           string `json:"text"`
+}
+
+func (a *App) postDigestJob(ctx context.Context, job *models.Job) error {
+	var d slackDigest
+	if err := json.Unmarshal([]byte(job.Payload), &d); err != nil {
+		return err
+	}
+	var sub models.DigestSubscription
+	err := a.dbCtx(ctx).First(&sub, d.SubscriptionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && sub.Channel != digestSlack) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(map[string]string{"text": d.Text})
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack rejected digest %d: %s", sub.ID, resp.Status)
+	}
+	return nil
+}
+
+// digestTarget works out where a new subscription sends to. Users may only
+// have digests emailed to their own address; admins may also send them to
+// other addresses and to Slack.
+func digestTarget(r *http.Request, body digestRequest) (string, error) {
+	user, _ := currentUser(r)
+	account, _ := currentAccount(r)
+	admin := user.Role == "admin"
+	target := strings.TrimSpace(body.Target)
+
+	if body.Channel == digestSlack {
+		if !admin {
+			return "", newServiceError(http.StatusForbidden, "Only admins can send digests to Slack")
+		}
+		if u, err := url.Parse(target); err != nil || u.Scheme != "https" || u.Host == "" {
+			return "", newServiceError(http.StatusBadRequest, "target must be an https webhook URL")
+		}
+		return target, nil
+	}
+
+	if target == "" {
+		target = account.Email
+	}
+	if target == "" {
+		return "", newServiceError(http.StatusBadRequest, "Set an email address on your profile first")
+	}
+	if !admin && !strings.EqualFold(target, account.Email) {
+		return "", newServiceError(http.StatusForbidden, "Digests can only be sent to your own email address")
+	}
+	if err := validate.Var(target, "email"); err != nil {
+		return "", newServiceError(http.StatusBadRequest, "target must be an email address")
+	}
+	return target, nil
+}
+
+// loadDigest loads the subscription named in the URL if the caller may
+// manage it: their own, or any in the organization for admins.
+func (a *App) loadDigest(w http.ResponseWriter, r *http.Request) (models.DigestSubscription, bool) {
+	user, _ := currentUser(r)
+	var sub models.DigestSubscription
+	err := a.dbCtx(r.Context()).First(&sub, mux.Vars(r)["id"]).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && user.Role != "admin" && sub.Owner != user.Username) {
+		err = errDigestNotFound
+	}
+	if err != nil {
+		writeServiceError(w, err, "Error loading digest subscription")
+		return sub, false
+	}
+	return sub, true
+}
+
+// listDigestsHandler lists the caller's digest subscriptions, or every one
+// in the organization for admins.
+func (a *App) listDigestsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	q := a.dbCtx(r.Context()).Order("id")
+	if user.Role != "admin" {
+		q = q.Where("owner = ?", user.Username)
+	}
+	subs := []models.DigestSubscription{}
+	if err := q.Find(&subs).Error; err != nil {
+		writeDBError(w, err, "Error loading digest subscriptions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, subs)
+}
+
+func (a *App) createDigestHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var body digestRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+	target, err := digestTarget(r, body)
+	if err != nil {
+		writeServiceError(w, err, "Invalid digest subscription")
+		return
+	}
+
+	sub := models.DigestSubscription{
+		Owner:     user.Username,
+		Channel:   body.Channel,
+		Target:    target,
+		Frequency: body.Frequency,
+		NextRunAt: a.nextDigestRun(body.Frequency, time.Now()),
+	}
+	if err := a.dbCtx(r.Context()).Create(&sub).Error; err != nil {
+		writeDBError(w, err, "Failed to save digest subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, sub)
+}
+
+// updateDigestHandler changes how often a digest is sent, starting from the
+// next scheduled run.
+func (a *App) updateDigestHandler(w http.ResponseWriter, r *http.Request) {
+	var body digestUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+	sub, ok := a.loadDigest(w, r)
+	if !ok {
+		return
+	}
+
+	sub.Frequency = body.Frequency
+	sub.NextRunAt = a.nextDigestRun(body.Frequency, time.Now())
+	if err := a.dbCtx(r.Context()).Model(&sub).Updates(map[string]interface{}{"frequency": sub.Frequency, "next_run_at": sub.NextRunAt}).Error; err != nil {
+		writeDBError(w, err, "Failed to save digest subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, sub)
+}
+
+func (a *App) deleteDigestHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := a.loadDigest(w, r)
+	if !ok {
+		return
+	}
+	if err := a.dbCtx(r.Context()).Delete(&sub).Error; err != nil {
+		writeDBError(w, err, "Failed to delete digest subscription")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// previewDigestHandler returns the digest the subscription would send now,
+// without sending it.
+func (a *App) previewDigestHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := a.loadDigest(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	report, err := a.buildDigest(r.Context(), sub, digestStart(sub, now), now)
+	if err != nil {
+		writeDBError(w, err, "Error building digest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"subject": digestSubject(sub.Frequency, report),
+		"text":    renderDigest(report),
+		"report":  report,
+	})
+}
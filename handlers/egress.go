@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// Outbound requests to URLs tenants configure, such as webhooks and import
+// sources, go through newEgressTransport so an org admin cannot point one
+// at the deployment's own network: loopback, private, link-local and other
+// non-public addresses are refused once the name is resolved, which also
+// covers names that resolve differently on a second lookup. It does not use
+// HTTP_PROXY, since the proxy would make the dial instead. allowPrivate
+// lifts the check, for ALLOW_PRIVATE_EGRESS in development.
+func newEgressTransport(allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   egressControl,
+	}
+	if allowPrivate {
+		dialer.Control = nil
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// egressControl refuses connections to addresses that are not public.
+func egressControl(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("connection to %s refused: not a public address", ap.Addr())
+	}
+	return nil
+}
+
+// carrierNAT is the shared address space of RFC 6598, which is not routed
+// on the internet.
+var carrierNAT = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether addr is a unicast address reachable on the
+// internet.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !carrierNAT.Contains(addr)
+}
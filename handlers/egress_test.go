@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestEgressControl(t *testing.T) {
+	if err := egressControl("tcp4", "169.254.169.254:80", nil); err == nil {
+		t.Error("dial to the metadata address was allowed")
+	}
+	if err := egressControl("tcp6", "[::1]:443", nil); err == nil {
+		t.Error("dial to loopback was allowed")
+	}
+	if err := egressControl("tcp4", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("dial to a public address was refused: %s", err)
+	}
+}
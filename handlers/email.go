@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+const jobSendEmail = "email.send"
+
+// Email message statuses.
+const (
+	emailQueued     = "queued"
+	emailSent       = "sent"
+	emailFailed     = "failed"
+	emailSuppressed = "suppressed"
+	emailBounced    = "bounced"
+	emailComplained = "complained"
+)
+
+// EmailSender delivers a message through one provider and returns the
+// provider's message ID, which bounce notifications refer to. unsubscribe
+// is the recipient's opt-out link, for the footer and List-Unsubscribe.
+type EmailSender interface {
+	Send(ctx context.Context, msg models.EmailMessage, unsubscribe string) (string, error)
+}
+
+// defaultEmailRates are sends per second per provider, matching their
+// usual account limits. EMAIL_RATE_LIMITS overrides them.
+var defaultEmailRates = map[string]float64{"log": 100, "smtp": 5, "ses": 14, "mailgun": 10}
+
+func init() {
+	registerJobHandler(jobSendEmail, (*App).sendEmailJob)
+}
+
+// setupEmail creates the configured provider's sender.
+func (a *App) setupEmail(ctx context.Context) error {
+	c := a.cfg
+	switch c.EmailProvider {
+	case "log":
+		a.emailSenders["log"] = logSender{}
+	case "smtp":
+		a.emailSenders["smtp"] = smtpSender{addr: c.SMTPAddress, username: c.SMTPUsername, password: c.SMTPPassword, from: c.EmailFrom}
+	case "mailgun":
+		if c.MailgunDomain == "" || c.MailgunAPIKey == "" {
+			return errors.New("mailgun needs MAILGUN_DOMAIN and MAILGUN_API_KEY")
+		}
+		a.emailSenders["mailgun"] = mailgunSender{apiBase: c.MailgunAPIBase, domain: c.MailgunDomain, apiKey: c.MailgunAPIKey, from: c.EmailFrom}
+	case "ses":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return err
+		}
+		a.emailSenders["ses"] = sesSender{client: sesv2.NewFromConfig(awsCfg), from: c.EmailFrom}
+	default:
+		return fmt.Errorf("unknown email provider %q", c.EmailProvider)
+	}
+	return nil
+}
+
+// emailLimiter returns the shared rate limiter for a provider.
+func (a *App) emailLimiter(provider string) *rate.Limiter {
+	a.emailLimitersMu.Lock()
+	defer a.emailLimitersMu.Unlock()
+	if l, ok := a.emailLimiters[provider]; ok {
+		return l
+	}
+	perSecond, ok := a.cfg.EmailRateLimits[provider]
+	if !ok {
+		perSecond = defaultEmailRates[provider]
+	}
+	if perSecond <= 0 {
+		perSecond = 1
+	}
+	l := rate.NewLimiter(rate.Limit(perSecond), 1)
+	a.emailLimiters[provider] = l
+	return l
+}
+
+// queueEmail records a message and queues it for sending using q, which may
+// be a transaction. Messages to undeliverable addresses are kept as
+// suppressed and never sent.
+func (a *App) queueEmail(q *gorm.DB, to, subject, body, createdBy string) (models.EmailMessage, error) {
+	msg := models.EmailMessage{
+		To:        strings.TrimSpace(to),
+		Subject:   subject,
+		Body:      body,
+		Provider:  a.cfg.EmailProvider,
+		Status:    emailQueued,
+		CreatedBy: createdBy,
+	}
+	undeliverable, err := isUndeliverable(q, msg.To)
+	if err != nil {
+		return msg, err
+	}
+	if undeliverable {
+		msg.Status = emailSuppressed
+	}
+	if err := q.Create(&msg).Error; err != nil {
+		return msg, err
+	}
+	if msg.Status == emailQueued {
+		_, err = enqueueJob(q, jobSendEmail, map[string]uint{"messageId": msg.ID}, time.Now())
+	}
+	return msg, err
+}
+
+// sendEmailJob sends one queued message, waiting for the provider's rate
+// limit and re-checking the address first so bounces received since it was
+// queued pause the send.
+func (a *App) sendEmailJob(ctx context.Context, job *models.Job) error {
+	var payload struct {
+		MessageID uint `json:"messageId"`
+	}
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return err
+	}
+
+	var msg models.EmailMessage
+	if err := a.dbCtx(ctx).First(&msg, payload.MessageID).Error; err != nil {
+		return err
+	}
+	// Failed messages come back here when their job is retried by hand
+	if msg.Status != emailQueued && msg.Status != emailFailed {
+		return nil
+	}
+
+	undeliverable, err := isUndeliverable(a.dbCtx(ctx), msg.To)
+	if err != nil {
+		return err
+	}
+	if undeliverable {
+		return a.dbCtx(ctx).Model(&msg).Update("status", emailSuppressed).Error
+	}
+
+	sender, ok := a.emailSenders[msg.Provider]
+	if !ok {
+		return fmt.Errorf("email provider %q is not configured", msg.Provider)
+	}
+	// Hold back rather than block the worker when the provider is saturated
+	limiter := a.emailLimiter(msg.Provider)
+	if r := limiter.Reserve(); r.Delay() > 0 {
+		delay := r.Delay()
+		r.Cancel()
+		return retryAfter(delay)
+	}
+
+	providerID, sendErr := sender.Send(ctx, msg, a.unsubscribeURL(msg.To))
+	if sendErr != nil {
+		var wait retryAfter
+		updates := map[string]interface{}{"error": sendErr.Error()}
+		if job.Attempts >= job.MaxAttempts && !errors.As(sendErr, &wait) {
+			updates["status"] = emailFailed
+		}
+		a.dbCtx(ctx).Model(&msg).Updates(updates)
+		return sendErr
+	}
+
+	now := time.Now()
+	return a.dbCtx(ctx).Model(&msg).Updates(map[string]interface{}{
+		"status":              emailSent,
+		"provider_message_id": providerID,
+		"error":               "",
+		"sent_at":             now,
+	}).Error
+}
+
+// emailText appends the unsubscribe footer to a message body.
+func emailText(msg models.EmailMessage, unsubscribe string) string {
+	return msg.Body + "\n\n--\nTo stop receiving these emails: " + unsubscribe + "\n"
+}
+
+// newMessageID returns an RFC 5322 Message-ID in the sending domain.
+func newMessageID(from string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = strings.Trim(from[i+1:], "> ")
+	}
+	return hex.EncodeToString(b) + "@" + domain
+}
+
+// logSender writes messages to the log instead of sending them, for
+// development.
+type logSender struct{}
+
+func (logSender) Send(ctx context.Context, msg models.EmailMessage, unsubscribe string) (string, error) {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, emailText(msg, unsubscribe))
+	return "log-" + strconv.Itoa(int(msg.ID)), nil
+}
+
+type smtpSender struct {
+	addr, username, password, from string
+}
+
+func (s smtpSender) Send(ctx context.Context, msg models.EmailMessage, unsubscribe string) (string, error) {
+	id := newMessageID(s.from)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s>\r\n", id)
+	fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", unsubscribe)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(emailText(msg, unsubscribe), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.username != "" {
+		host := s.addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+	return id, smtp.SendMail(s.addr, auth, s.from, []string{msg.To}, b.Bytes())
+}
+
+type mailgunSender struct {
+	apiBase, domain, apiKey, from string
+}
+
+func (s mailgunSender) Send(ctx context.Context, msg models.EmailMessage, unsubscribe string) (string, error) {
+	form := url.Values{
+		"from":               {s.from},
+		"to":                 {msg.To},
+		"subject":            {msg.Subject},
+		"text":               {emailText(msg, unsubscribe)},
+		"h:List-Unsubscribe": {"<" + unsubscribe + ">"},
+	}
+	endpoint := strings.TrimRight(s.apiBase, "/") + "/" + s.domain + "/messages"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("api", s.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", retryAfter(time.Minute)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("mailgun returned %s", resp.Status)
+	}
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return strings.Trim(out.ID, "<>"), nil
+}
+
+type sesSender struct {
+	client *sesv2.Client
+	from   string
+}
+
+func (s sesSender) Send(ctx context.Context, msg models.EmailMessage, unsubscribe string) (string, error) {
+	out, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: &s.from,
+		Destination:      &sestypes.Destination{ToAddresses: []string{msg.To}},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: &msg.Subject},
+			Body:    &sestypes.Body{Text: &sestypes.Content{Data: strPtr(emailText(msg, unsubscribe))}},
+			Headers: []sestypes.MessageHeader{{Name: strPtr("List-Unsubscribe"), Value: strPtr("<" + unsubscribe + ">")}},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return *out.MessageId, nil
+}
+
+func strPtr(s string) *string { return &s }
+
+type sendEmailRequest struct {
+	To      string `json:"to" validate:"required,email"`
+	Subject string `json:"subject" validate:"required,max=255"`
+	Body    string `json:"body" validate:"required"`
+}
+
+func (a *App) sendEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var body sendEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	msg, err := a.queueEmail(tx, body.To, body.Subject, body.Body, actorName(r))
+	if err != nil {
+		tx.Rollback()
+		writeDBError(w, err, "Failed to queue email")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		writeDBError(w, err, "Failed to queue email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	a.encodeJSON(w, r, msg)
+}
+
+func (a *App) listEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	q := a.dbCtx(r.Context()).Order("id desc").Limit(100)
+	if status := r.URL.Query().Get("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		q = q.Where("lower(\"to\") = lower(?)", to)
+	}
+
+	var messages []models.EmailMessage
+	if err := q.Find(&messages).Error; err != nil {
+		writeDBError(w, err, "Error loading emails")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, messages)
+}
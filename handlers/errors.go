@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// apiError is the body of every error response.
+type apiError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// errorCodes maps statuses to the machine-readable codes clients switch on.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "validation_failed",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusNotImplemented:        "not_implemented",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "error"
+}
+
+// writeError sends an error response in the standard envelope.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorDetails(w, status, message, nil)
+}
+
+// writeErrorf is writeError for a message naming a value, such as a field.
+// format is what gets translated.
+func writeErrorf(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	sendError(w, status, localizef(w, format, args...), nil)
+}
+
+// writeErrorDetails sends an error response with extra detail for the
+// client, such as the fields that failed validation.
+func writeErrorDetails(w http.ResponseWriter, status int, message string, details interface{}) {
+	sendError(w, status, localize(w, message), details)
+}
+
+// sendError writes the envelope for a message already in the response
+// language.
+func sendError(w http.ResponseWriter, status int, message string, details interface{}) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{
+		Code:      errorCode(status),
+		Message:   message,
+		Details:   details,
+		RequestID: h.Get(requestIDHeader),
+	})
+}
+
+// writeBodyError reports a request body that could not be decoded.
+func writeBodyError(w http.ResponseWriter, err error) {
+	if bodyTooLarge(err) {
+		writeError(w, http.StatusRequestEntityTooLarge, "Body too large")
+		return
+	}
+	writeErrorDetails(w, http.StatusBadRequest, "Invalid request body", err.Error())
+}
+
+// writeDBError maps a database error to a response: missing records are
+// 404, constraint violations 409, and anything else a logged 500 whose
+// details stay out of the response. message describes the failed action.
+func writeDBError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		writeError(w, http.StatusConflict, "Already exists")
+		return
+	}
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		writeError(w, http.StatusConflict, "Refers to a record that does not exist or is still in use")
+		return
+	}
+	log.Printf("%s (request %s): %s", message, w.Header().Get(requestIDHeader), err)
+	writeError(w, http.StatusInternalServerError, message)
+}
+
+// serviceError is a failure from the shared service layer that the caller
+// should see, such as a missing record or invalid input. The REST handlers
+// and the gRPC server both map it from its HTTP status.
+type serviceError struct {
+	status  int
+	message string
+	fields  []fieldError
+}
+
+func (e *serviceError) Error() string { return e.message }
+
+func newServiceError(status int, message string) error {
+	return &serviceError{status: status, message: message}
+}
+
+// writeServiceError reports an error from the service layer, falling back
+// to writeDBError for anything that is not a serviceError.
+func writeServiceError(w http.ResponseWriter, err error, message string) {
+	var se *serviceError
+	if !errors.As(err, &se) {
+		writeDBError(w, err, message)
+		return
+	}
+	if len(se.fields) > 0 {
+		writeValidationErrors(w, se.fields)
+		return
+	}
+	writeError(w, se.status, se.message)
+}
+
+// requestIDMiddleware tags each request with an ID, reusing a valid one
+// from the client or proxy, and echoes it in the response so error reports
+// can be matched to logs.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 12)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+)
+
+const redactedValue = "REDACTED"
+
+// exportDataset describes the columns of an exportable table and how to
+// turn one of its rows into CSV values.
+type exportDataset struct {
+	columns []string
+	rows    func(a *App, ctx context.Context, emit func([]string) error) error
+}
+
+var exportDatasets = map[string]exportDataset{
+	"contacts": {
+		columns: []string{"id", "email", "full_name", "timestamp", "twitter_profile", "linkedin_profile"},
+		rows: func(a *App, ctx context.Context, emit func([]string) error) error {
+			rows, err := a.activeContacts(ctx).Order("id").Rows()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var c models.Contact
+				if err := a.db.ScanRows(rows, &c); err != nil {
+					return err
+				}
+				if err := emit([]string{strconv.Itoa(int(c.ID)), c.Email, c.FullName, c.Timestamp, c.TwitterProfile, c.LinkedinProfile}); err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		},
+	},
+	"issues": {
+		columns: []string{"id", "title", "details", "priority", "status", "state", "type", "image_url", "reported_by", "reported_at", "created_at", "updated_at"},
+		rows: func(a *App, ctx context.Context, emit func([]string) error) error {
+			rows, err := a.dbCtx(ctx).Model(&models.Issue{}).Order("id").Rows()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var i models.Issue
+				if err := a.db.ScanRows(rows, &i); err != nil {
+					return err
+				}
+				if err := emit([]string{
+					strconv.Itoa(int(i.ID)), i.Title, i.Details, strconv.Itoa(i.Priority),
+					strconv.FormatBool(i.Status), i.State, strconv.FormatBool(i.Type), i.ImageURL, i.ReportedBy,
+					i.ReportedAt.Format(time.RFC3339), i.CreatedAt.Format(time.RFC3339), i.UpdatedAt.Format(time.RFC3339),
+				}); err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		},
+	},
+}
+
+// exportHandler streams a dataset as CSV with the chosen redaction profile
+// applied, and records the export.
+func (a *App) exportHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	name := mux.Vars(r)["dataset"]
+	dataset, ok := exportDatasets[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown dataset")
+		return
+	}
+
+	profileName := r.URL.Query().Get("profile")
+	redacted, ok := a.currentSettings().ExportRedactionProfiles[profileName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Unknown or missing redaction profile")
+		return
+	}
+
+	redact, redactedColumns := a.exportRedactions(viewerRole(user), name, dataset, redacted)
+	job := models.ExportJob{Dataset: name, Profile: profileName, RedactedFields: strings.Join(redactedColumns, ","), RequestedBy: user.Username}
+	if err := a.dbCtx(r.Context()).Create(&job).Error; err != nil {
+		log.Println("Error recording export:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%d.csv", name, job.ID)))
+	a.writeExport(r.Context(), &job, dataset, redact, w)
+}
+
+// exportRedactions works out which of a dataset's columns the profile or
+// the role's field visibility blanks out.
+func (a *App) exportRedactions(role, name string, dataset exportDataset, profile []string) ([]bool, []string) {
+	hidden := append(a.hiddenColumns(role, name, dataset.columns), profile...)
+	redact := make([]bool, len(dataset.columns))
+	var redactedColumns []string
+	for i, col := range dataset.columns {
+		if containsString(hidden, col) {
+			redact[i] = true
+			redactedColumns = append(redactedColumns, col)
+		}
+	}
+	return redact, redactedColumns
+}
+
+// writeExport writes a dataset to out as CSV, blanking the redact columns,
+// and records the row count and outcome on job.
+func (a *App) writeExport(ctx context.Context, job *models.ExportJob, dataset exportDataset, redact []bool, out io.Writer) error {
+	cw := csv.NewWriter(out)
+	cw.Write(dataset.columns)
+
+	err := dataset.rows(a, ctx, func(values []string) error {
+		for i := range values {
+			if redact[i] && values[i] != "" {
+				values[i] = redactedValue
+			}
+		}
+		job.Rows++
+		return cw.Write(values)
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	if err != nil {
+		log.Printf("Error exporting %s: %s", job.Dataset, err)
+		job.Error = err.Error()
+	}
+	if err := a.dbCtx(ctx).Save(job).Error; err != nil {
+		log.Println("Error recording export:", err)
+	}
+	return err
+}
+
+func (a *App) listExportsHandler(w http.ResponseWriter, r *http.Request) {
+	var jobs []models.ExportJob
+	if err := a.dbCtx(r.Context()).Order("id desc").Limit(100).Find(&jobs).Error; err != nil {
+		log.Println("Error loading exports:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading exports")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, jobs)
+}
+
+func (a *App) listRedactionProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, a.currentSettings().ExportRedactionProfiles)
+}
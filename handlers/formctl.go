@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"form/config"
+	"form/models"
+	"form/storage"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// NewFormctlCommand builds the formctl command tree. Commands run the same
+// service code as the HTTP handlers, against the database c names.
+func NewFormctlCommand(c config.Config) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "formctl",
+		Short:         "Operate a form deployment",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	admin := &cobra.Command{Use: "admin", Short: "Manage operator accounts"}
+	admin.AddCommand(newAdminCreateCommand(c), newAdminResetCommand(c))
+	root.AddCommand(
+		admin,
+		&cobra.Command{
+			Use:   "migrate up|down [n]|version|force VERSION|goto VERSION",
+			Short: "Manage the database schema",
+			Args:  cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return storage.RunMigrateCommand(c, args)
+			},
+		},
+		newImportCommand(c),
+		newExportCommand(c),
+		newRotateSigningKeyCommand(c),
+	)
+	return root
+}
+
+// ctlDatabase connects to the database c names and builds the service
+// layer on it. The returned function closes the connection.
+func ctlDatabase(ctx context.Context, c config.Config) (*App, func(), error) {
+	database, err := storage.Connect(ctx, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	sqlDB, _ := database.DB()
+	a, err := New(c, database, storage.DiskStorage(c.UploadDir))
+	if err != nil {
+		sqlDB.Close()
+		return nil, nil, err
+	}
+	return a, func() { sqlDB.Close() }, nil
+}
+
+// ctlOrganization returns ctx acting in the organization with the given
+// slug, or the default organization.
+func (a *App) ctlOrganization(ctx context.Context, slug string) (context.Context, error) {
+	ctx, _, err := a.resolveOrganization(ctx, nil, slug)
+	return ctx, err
+}
+
+func newAdminCreateCommand(c config.Config) *cobra.Command {
+	var password string
+	cmd := &cobra.Command{
+		Use:   "create USERNAME",
+		Short: "Create an operator account",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, closeDB, err := ctlDatabase(cmd.Context(), c)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			user := models.User{Username: args[0], Password: password, Role: accountRoleAdmin}
+			if err := validateInput(&user); err != nil {
+				return err
+			}
+			tx := a.dbCtx(cmd.Context()).Begin()
+			err = createAdminUser(tx, &user)
+			if err == nil {
+				err = recordAudit(tx, "formctl", "user.created", "user", user.ID, map[string]string{"role": accountRoleAdmin})
+			}
+			if err == nil {
+				err = tx.Commit().Error
+			} else {
+				tx.Rollback()
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created operator %s (id %d)\n", user.Username, user.ID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&password, "password", "", "password for the new account")
+	cmd.MarkFlagRequired("password")
+	return cmd
+}
+
+func newAdminResetCommand(c config.Config) *cobra.Command {
+	var password string
+	cmd := &cobra.Command{
+		Use:   "reset USERNAME",
+		Short: "Set an account's password, re-enable it and make it an operator",
+		Long: "Sets the password, clears any disabled flag or pending reset and gives the account the\n" +
+			"admin role. Tokens issued before the reset stop working.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateInput(&passwordResetRequest{Password: password}); err != nil {
+				return err
+			}
+			a, closeDB, err := ctlDatabase(cmd.Context(), c)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			var user models.User
+			err = a.dbCtx(cmd.Context()).Where("username = ?", args[0]).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no user named %q", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			tx := a.dbCtx(cmd.Context()).Begin()
+			err = tx.Model(&user).Updates(map[string]interface{}{
+				"password":                password,
+				"role":                    accountRoleAdmin,
+				"disabled_at":             nil,
+				"password_reset_required": false,
+				"password_changed_at":     time.Now(),
+			}).Error
+			if err == nil {
+				err = recordAudit(tx, "formctl", "user.password_reset", "user", user.ID, nil)
+			}
+			if err == nil {
+				err = tx.Commit().Error
+			} else {
+				tx.Rollback()
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Reset %s\n", user.Username)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&password, "password", "", "the new password")
+	cmd.MarkFlagRequired("password")
+	return cmd
+}
+
+func newImportCommand(c config.Config) *cobra.Command {
+	var org, mapping string
+	cmd := &cobra.Command{
+		Use:   "import FILE.csv",
+		Short: "Import contacts from a CSV file on disk",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			columns := map[string]string{}
+			if mapping != "" {
+				if err := json.Unmarshal([]byte(mapping), &columns); err != nil {
+					return fmt.Errorf("invalid --mapping: %w", err)
+				}
+			}
+			a, closeDB, err := ctlDatabase(cmd.Context(), c)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+			ctx, err := a.ctlOrganization(cmd.Context(), org)
+			if err != nil {
+				return err
+			}
+
+			job := models.ImportJob{Source: "csv_file", Status: importQueued, RequestedBy: "formctl"}
+			if err := a.dbCtx(ctx).Create(&job).Error; err != nil {
+				return err
+			}
+			if err := a.runImportJob(ctx, &job, csvFileConnector{content: content}, columns); err != nil {
+				return fmt.Errorf("import job %d: %w", job.ID, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Import job %d: %d rows, %d inserted, %d skipped, %d invalid\n", job.ID, job.Total, job.Inserted, job.Skipped, job.Invalid)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "slug of the organization to import into (default organization if empty)")
+	cmd.Flags().StringVar(&mapping, "mapping", "", `JSON object mapping contact fields to CSV headers, e.g. {"email":"E-mail"}`)
+	return cmd
+}
+
+func newExportCommand(c config.Config) *cobra.Command {
+	var org, profile, output string
+	cmd := &cobra.Command{
+		Use:   "export DATASET",
+		Short: "Export issues or contacts as CSV",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			dataset, ok := exportDatasets[name]
+			if !ok {
+				return fmt.Errorf("unknown dataset %q", name)
+			}
+			a, closeDB, err := ctlDatabase(cmd.Context(), c)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+			ctx, err := a.ctlOrganization(cmd.Context(), org)
+			if err != nil {
+				return err
+			}
+			redacted, ok := a.currentSettings().ExportRedactionProfiles[profile]
+			if !ok {
+				return fmt.Errorf("unknown redaction profile %q", profile)
+			}
+
+			var out io.Writer = cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+
+			// Operators see every field
+			redact, redactedColumns := a.exportRedactions(accountRoleAdmin, name, dataset, redacted)
+			job := models.ExportJob{Dataset: name, Profile: profile, RedactedFields: strings.Join(redactedColumns, ","), RequestedBy: "formctl"}
+			if err := a.dbCtx(ctx).Create(&job).Error; err != nil {
+				return err
+			}
+			if err := a.writeExport(ctx, &job, dataset, redact, out); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d rows (export %d)\n", job.Rows, job.ID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "slug of the organization to export (default organization if empty)")
+	cmd.Flags().StringVar(&profile, "profile", "compliance", "redaction profile to apply")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write, or - for stdout")
+	return cmd
+}
+
+// newRotateSigningKeyCommand generates a new token signing key. Keys come
+// from the environment, so the command prints the values to deploy rather
+// than changing anything itself.
+func newRotateSigningKeyCommand(c config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate-signing-key",
+		Short: "Generate a new login token signing key",
+		Long: "Prints a new SIGNING_KEY, and PREVIOUS_SIGNING_KEYS holding the current one so tokens it\n" +
+			"signed keep working until they expire. Drop the old key after TOKEN_TTL. Signed links\n" +
+			"(unsubscribe, password reset, corrections, uploads) only check the current key and must be\n" +
+			"sent again.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			previous := c.PreviousSigningKeys
+			if current := os.Getenv("SIGNING_KEY"); current != "" {
+				previous = append([]string{current}, previous...)
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "SIGNING_KEY=%s\n", base64.RawURLEncoding.EncodeToString(key))
+			fmt.Fprintf(out, "PREVIOUS_SIGNING_KEYS=%s\n", strings.Join(previous, ","))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/graph"
+	"form/models"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"gorm.io/gorm"
+)
+
+const (
+	graphQLPageSize = 50
+	graphQLMaxPage  = 100
+	// graphQLComplexityLimit bounds how much nesting one query may fan out
+	// into, since every connection field is a database query.
+	graphQLComplexityLimit = 500
+)
+
+// newGraphQLHandler serves the GraphQL schema in graph/schema.graphqls.
+// Resolvers use the same service functions as the REST and gRPC handlers,
+// so access rules match across all three.
+func (a *App) newGraphQLHandler() http.Handler {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: graphResolver{a}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(graphQLComplexityLimit))
+	srv.SetErrorPresenter(graphQLError)
+	return srv
+}
+
+// graphQLError shows service errors to the client and hides anything else
+// behind a generic message, as writeDBError does.
+func graphQLError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var se *serviceError
+	if errors.As(err, &se) {
+		gqlErr.Message = se.message
+		gqlErr.Extensions = map[string]interface{}{"code": errorCode(se.status)}
+		if len(se.fields) > 0 {
+			gqlErr.Extensions["fields"] = se.fields
+		}
+		return gqlErr
+	}
+	var parseErr *gqlerror.Error
+	if errors.As(err, &parseErr) && parseErr.Err == nil {
+		return gqlErr
+	}
+	log.Printf("GraphQL error at %s: %s", gqlErr.Path, err)
+	gqlErr.Message = "Internal error"
+	gqlErr.Extensions = map[string]interface{}{"code": errorCode(http.StatusInternalServerError)}
+	return gqlErr
+}
+
+// Cursors are opaque to clients but encode the ID of the last row returned.
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("id:" + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor *string) (uint, error) {
+	if cursor == nil || *cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(*cursor)
+	if err == nil && strings.HasPrefix(string(b), "id:") {
+		id, perr := strconv.ParseUint(strings.TrimPrefix(string(b), "id:"), 10, 64)
+		if perr == nil {
+			return uint(id), nil
+		}
+	}
+	return 0, newServiceError(http.StatusBadRequest, "Invalid cursor")
+}
+
+func pageSize(first *int) int {
+	if first == nil || *first <= 0 {
+		return graphQLPageSize
+	}
+	if *first > graphQLMaxPage {
+		return graphQLMaxPage
+	}
+	return *first
+}
+
+func parseGraphQLID(id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, newServiceError(http.StatusBadRequest, "Invalid ID")
+	}
+	return uint(n), nil
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (a *App) issueNode(ctx context.Context, i models.Issue) *graph.Issue {
+	i = a.visibleTo(viewerRole(contextUser(ctx)), i).(models.Issue)
+	n := &graph.Issue{
+		ID:          formatID(i.ID),
+		Title:       i.Title,
+		Details:     i.Details,
+		Priority:    i.Priority,
+		State:       i.State,
+		Type:        i.Type,
+		ReportedBy:  i.ReportedBy,
+		ReportedAt:  i.ReportedAt,
+		Assignee:    i.Assignee,
+		Component:   i.Component,
+		ReopenCount: i.ReopenCount,
+		Mentions:    i.Mentions,
+		Version:     i.Version,
+		CreatedAt:   i.CreatedAt,
+		UpdatedAt:   i.UpdatedAt,
+		ImageURL:    i.ImageURL,
+
+		OrganizationID: i.OrganizationID,
+	}
+	if n.Mentions == nil {
+		n.Mentions = []string{}
+	}
+	if i.FixVersionID != nil {
+		n.FixVersionID = *i.FixVersionID
+	}
+	if i.Key != "" {
+		n.Key = &i.Key
+	}
+	return n
+}
+
+func userNode(u models.User) *graph.User {
+	return &graph.User{ID: formatID(u.ID), Username: u.Username, Role: u.Role}
+}
+
+// issueConnection runs listIssues for one page, fetching an extra row to
+// tell whether another page follows.
+func (a *App) issueConnection(ctx context.Context, f issueFilter, first *int, after *string) (*graph.IssueConnection, error) {
+	before, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	limit := pageSize(first)
+	f.BeforeID, f.Limit = before, limit+1
+	issues, err := a.repo.ListIssues(ctx, contextUser(ctx), f)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &graph.IssueConnection{PageInfo: &graph.PageInfo{}, Edges: []*graph.IssueEdge{}}
+	if len(issues) > limit {
+		issues = issues[:limit]
+		conn.PageInfo.HasNextPage = true
+	}
+	for _, issue := range issues {
+		conn.Edges = append(conn.Edges, &graph.IssueEdge{Cursor: encodeCursor(issue.ID), Node: a.issueNode(ctx, issue)})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+// findUser loads a member of the organization by name, returning nil if
+// there is none.
+func (a *App) findUser(ctx context.Context, username string) (*graph.User, error) {
+	if username == "" {
+		return nil, nil
+	}
+	var user models.User
+	err := a.organizationMembers(ctx).Where("users.username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userNode(user), nil
+}
+
+type graphResolver struct {
+	app *App
+}
+
+func (r graphResolver) Query() graph.QueryResolver     { return queryResolver{r.app} }
+func (r graphResolver) Issue() graph.IssueResolver     { return issueResolver{r.app} }
+func (r graphResolver) Comment() graph.CommentResolver { return commentResolver{r.app} }
+func (r graphResolver) Label() graph.LabelResolver     { return labelResolver{r.app} }
+func (r graphResolver) User() graph.UserResolver       { return userResolver{r.app} }
+
+type queryResolver struct {
+	app *App
+}
+
+func (r queryResolver) Me(ctx context.Context) (*graph.User, error) {
+	return userNode(*contextUser(ctx)), nil
+}
+
+func (r queryResolver) Issue(ctx context.Context, id string) (*graph.Issue, error) {
+	issueID, err := parseGraphQLID(id)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := r.app.accessibleIssue(ctx, contextUser(ctx), issueID)
+	if errors.Is(err, errIssueNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.app.issueNode(ctx, issue), nil
+}
+
+func (r queryResolver) Issues(ctx context.Context, first *int, after *string, state *string, assignee *string, label *string) (*graph.IssueConnection, error) {
+	var f issueFilter
+	if state != nil {
+		f.State = *state
+	}
+	if assignee != nil {
+		f.Assignee = *assignee
+	}
+	if label != nil {
+		f.Label = *label
+	}
+	return r.app.issueConnection(ctx, f, first, after)
+}
+
+func (r queryResolver) Labels(ctx context.Context) ([]*graph.Label, error) {
+	var labels []models.Label
+	if err := r.app.dbCtx(ctx).Order("name").Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	nodes := make([]*graph.Label, 0, len(labels))
+	for _, l := range labels {
+		nodes = append(nodes, &graph.Label{ID: formatID(l.ID), Name: l.Name})
+	}
+	return nodes, nil
+}
+
+func (r queryResolver) User(ctx context.Context, username string) (*graph.User, error) {
+	if viewer := contextUser(ctx); viewer.Role != "admin" && viewer.Username != username {
+		return nil, nil
+	}
+	return r.app.findUser(ctx, username)
+}
+
+type issueResolver struct {
+	app *App
+}
+
+func (r issueResolver) Reporter(ctx context.Context, obj *graph.Issue) (*graph.User, error) {
+	return r.app.findUser(ctx, obj.ReportedBy)
+}
+
+func (r issueResolver) AssigneeUser(ctx context.Context, obj *graph.Issue) (*graph.User, error) {
+	return r.app.findUser(ctx, obj.Assignee)
+}
+
+func (r issueResolver) Labels(ctx context.Context, obj *graph.Issue) ([]*graph.Label, error) {
+	var labels []models.Label
+	err := r.app.dbCtx(ctx).Joins("JOIN issue_labels ON issue_labels.label_id = labels.id").
+		Where("issue_labels.issue_id = ?", obj.ID).Order("labels.name").Find(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*graph.Label, 0, len(labels))
+	for _, l := range labels {
+		nodes = append(nodes, &graph.Label{ID: formatID(l.ID), Name: l.Name})
+	}
+	return nodes, nil
+}
+
+func (r issueResolver) FixVersion(ctx context.Context, obj *graph.Issue) (*graph.Release, error) {
+	if obj.FixVersionID == 0 {
+		return nil, nil
+	}
+	var release models.Release
+	if err := r.app.dbCtx(ctx).First(&release, obj.FixVersionID).Error; err != nil {
+		return nil, err
+	}
+	return &graph.Release{ID: formatID(release.ID), Version: release.Version, Name: release.Name, ReleasedAt: release.ReleasedAt}, nil
+}
+
+func (r issueResolver) Attachment(ctx context.Context, obj *graph.Issue) (*graph.Attachment, error) {
+	name := issueUpload(models.Issue{ImageURL: obj.ImageURL, OrganizationID: obj.OrganizationID})
+	if name == "" {
+		return nil, nil
+	}
+	expires := time.Now().Add(r.app.cfg.UploadURLTTL)
+	return &graph.Attachment{URL: r.app.signedUploadURL(name, expires), ExpiresAt: expires}, nil
+}
+
+func (r issueResolver) Comments(ctx context.Context, obj *graph.Issue, first *int, after *string) (*graph.CommentConnection, error) {
+	afterID, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	limit := pageSize(first)
+
+	// Oldest first, so threads read top to bottom
+	var comments []models.Comment
+	q := r.app.dbCtx(ctx).Where("issue_id = ?", obj.ID).Order("id").Limit(limit + 1)
+	if afterID > 0 {
+		q = q.Where("id > ?", afterID)
+	}
+	if err := q.Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	if err := attachCommentMentions(r.app.dbCtx(ctx), comments); err != nil {
+		return nil, err
+	}
+
+	conn := &graph.CommentConnection{PageInfo: &graph.PageInfo{}, Edges: []*graph.CommentEdge{}}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		conn.PageInfo.HasNextPage = true
+	}
+	comments = r.app.visibleTo(viewerRole(contextUser(ctx)), comments).([]models.Comment)
+	for _, c := range comments {
+		conn.Edges = append(conn.Edges, &graph.CommentEdge{
+			Cursor: encodeCursor(c.ID),
+			Node: &graph.Comment{
+				ID:        formatID(c.ID),
+				Author:    c.Author,
+				Body:      c.Body,
+				Mentions:  c.Mentions,
+				CreatedAt: c.CreatedAt,
+				IssueID:   c.IssueID,
+			},
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+func (r issueResolver) History(ctx context.Context, obj *graph.Issue) ([]*graph.IssueEvent, error) {
+	var events []models.IssueEvent
+	if err := r.app.dbCtx(ctx).Where("issue_id = ?", obj.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	nodes := make([]*graph.IssueEvent, 0, len(events))
+	for _, e := range events {
+		nodes = append(nodes, &graph.IssueEvent{
+			ID:        formatID(e.ID),
+			Type:      e.Type,
+			Actor:     e.Actor,
+			FromValue: e.FromValue,
+			ToValue:   e.ToValue,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return nodes, nil
+}
+
+type commentResolver struct {
+	app *App
+}
+
+func (r commentResolver) Issue(ctx context.Context, obj *graph.Comment) (*graph.Issue, error) {
+	issue, err := r.app.accessibleIssue(ctx, contextUser(ctx), obj.IssueID)
+	if err != nil {
+		return nil, err
+	}
+	return r.app.issueNode(ctx, issue), nil
+}
+
+type labelResolver struct {
+	app *App
+}
+
+func (r labelResolver) Issues(ctx context.Context, obj *graph.Label, first *int, after *string) (*graph.IssueConnection, error) {
+	return r.app.issueConnection(ctx, issueFilter{Label: obj.Name}, first, after)
+}
+
+type userResolver struct {
+	app *App
+}
+
+func (r userResolver) ReportedIssues(ctx context.Context, obj *graph.User, first *int, after *string) (*graph.IssueConnection, error) {
+	return r.app.issueConnection(ctx, issueFilter{ReportedBy: obj.Username}, first, after)
+}
+
+func (r userResolver) AssignedIssues(ctx context.Context, obj *graph.User, first *int, after *string) (*graph.IssueConnection, error) {
+	return r.app.issueConnection(ctx, issueFilter{Assignee: obj.Username}, first, after)
+}
@@ -0,0 +1,361 @@
+package handlers
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=form --go-grpc_out=.. --go-grpc_opt=module=form form/v1/issues.proto form/v1/contacts.proto
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/formpb"
+	"form/models"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// grpcCodes maps service error statuses to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+}
+
+// GRPCServer serves the issue and contact services over gRPC. Callers
+// authenticate with the same bearer tokens as the REST API, sent in the
+// authorization metadata.
+func (a *App) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(a.grpcTimeoutInterceptor, a.grpcAuthInterceptor),
+	)
+	formpb.RegisterIssueServiceServer(srv, issueServer{app: a})
+	formpb.RegisterContactServiceServer(srv, contactServer{app: a})
+	reflection.Register(srv)
+	return srv
+}
+
+// ServeGRPC listens on cfg.GRPCPort until the server is stopped.
+func (a *App) ServeGRPC(srv *grpc.Server) error {
+	lis, err := net.Listen("tcp", a.cfg.GRPCPort)
+	if err != nil {
+		return err
+	}
+	log.Printf("gRPC server running on port %s", a.cfg.GRPCPort)
+	return srv.Serve(lis)
+}
+
+// grpcTimeoutInterceptor bounds each call by cfg.RequestTimeout, as
+// timeoutMiddleware does for HTTP. A shorter client deadline still wins.
+func (a *App) grpcTimeoutInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if a.cfg.RequestTimeout <= 0 {
+		return handler(ctx, req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.RequestTimeout)
+	defer cancel()
+	return handler(ctx, req)
+}
+
+// grpcAuthInterceptor resolves the bearer token to a user and the
+// x-organization metadata to an organization, as the HTTP middleware does.
+// Every method needs a token, and the contact service needs an admin.
+func (a *App) grpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, "/grpc.reflection.") {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Authentication required")
+	}
+	raw := strings.TrimPrefix(values[0], "Bearer ")
+	user, err := a.tokenUser(ctx, raw)
+	if err != nil || raw == values[0] {
+		return nil, status.Error(codes.Unauthenticated, "Invalid token")
+	}
+
+	var slug string
+	if values := md.Get("x-organization"); len(values) > 0 {
+		slug = values[0]
+	}
+	ctx, user, err = a.resolveOrganization(ctx, user, slug)
+	if err != nil {
+		return nil, grpcError(err, "Error resolving organization")
+	}
+
+	if strings.HasPrefix(info.FullMethod, "/form.v1.ContactService/") && user.Role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "Admin access required")
+	}
+	return handler(ctx, req)
+}
+
+// grpcError converts a service or database error to a gRPC status, logging
+// and hiding unexpected errors as writeDBError does.
+func grpcError(err error, message string) error {
+	var se *serviceError
+	if errors.As(err, &se) {
+		code, ok := grpcCodes[se.status]
+		if !ok {
+			code = codes.Internal
+		}
+		msg := se.message
+		for _, f := range se.fields {
+			msg += "; " + f.Field + " " + f.Reason
+		}
+		return status.Error(code, msg)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, "Not found")
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return status.Error(codes.AlreadyExists, "Already exists")
+	}
+	log.Printf("%s (gRPC): %s", message, err)
+	return status.Error(codes.Internal, message)
+}
+
+// grpcPage reads a page size and an ID cursor token.
+func grpcPage(size int32, token string) (int, uint, error) {
+	limit := int(size)
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	if token == "" {
+		return limit, 0, nil
+	}
+	before, err := strconv.ParseUint(token, 10, 64)
+	if err != nil {
+		return 0, 0, status.Error(codes.InvalidArgument, "Invalid page token")
+	}
+	return limit, uint(before), nil
+}
+
+func nextPageToken(count, limit int, lastID uint) string {
+	if count < limit {
+		return ""
+	}
+	return strconv.FormatUint(uint64(lastID), 10)
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+type issueServer struct {
+	app *App
+	formpb.UnimplementedIssueServiceServer
+}
+
+func (a *App) issueProto(ctx context.Context, i models.Issue) *formpb.Issue {
+	i = a.visibleTo(viewerRole(contextUser(ctx)), i).(models.Issue)
+	p := &formpb.Issue{
+		Id:          uint64(i.ID),
+		Title:       i.Title,
+		Details:     i.Details,
+		Priority:    int32(i.Priority),
+		State:       i.State,
+		Type:        i.Type,
+		ImageUrl:    i.ImageURL,
+		ReportedBy:  i.ReportedBy,
+		ReportedAt:  timestamppb.New(i.ReportedAt),
+		Assignee:    i.Assignee,
+		Component:   i.Component,
+		ReopenCount: int32(i.ReopenCount),
+		Mentions:    i.Mentions,
+		CreatedAt:   timestamppb.New(i.CreatedAt),
+		UpdatedAt:   timestamppb.New(i.UpdatedAt),
+	}
+	if i.FixVersionID != nil {
+		p.FixVersionId = uint64(*i.FixVersionID)
+	}
+	return p
+}
+
+func (a *App) commentProto(ctx context.Context, c models.Comment) *formpb.Comment {
+	c = a.visibleTo(viewerRole(contextUser(ctx)), c).(models.Comment)
+	return &formpb.Comment{
+		Id:        uint64(c.ID),
+		IssueId:   uint64(c.IssueID),
+		Author:    c.Author,
+		Body:      c.Body,
+		Mentions:  c.Mentions,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
+
+func (s issueServer) GetIssue(ctx context.Context, req *formpb.GetIssueRequest) (*formpb.Issue, error) {
+	issue, err := s.app.accessibleIssue(ctx, contextUser(ctx), uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err, "Error loading issue")
+	}
+	return s.app.issueProto(ctx, issue), nil
+}
+
+func (s issueServer) ListIssues(ctx context.Context, req *formpb.ListIssuesRequest) (*formpb.ListIssuesResponse, error) {
+	limit, before, err := grpcPage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := s.app.repo.ListIssues(ctx, contextUser(ctx), issueFilter{State: req.State, Assignee: req.Assignee, BeforeID: before, Limit: limit})
+	if err != nil {
+		return nil, grpcError(err, "Error loading issues")
+	}
+
+	resp := &formpb.ListIssuesResponse{}
+	for _, issue := range issues {
+		resp.Issues = append(resp.Issues, s.app.issueProto(ctx, issue))
+	}
+	if len(issues) > 0 {
+		resp.NextPageToken = nextPageToken(len(issues), limit, issues[len(issues)-1].ID)
+	}
+	return resp, nil
+}
+
+func (s issueServer) CreateIssue(ctx context.Context, req *formpb.CreateIssueRequest) (*formpb.Issue, error) {
+	user := contextUser(ctx)
+	issue, err := s.app.createIssue(ctx, user, models.Issue{
+		Title:      req.Title,
+		Details:    req.Details,
+		Priority:   int(req.Priority),
+		Type:       req.Type,
+		ReportedBy: user.Username,
+		ReportedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, grpcError(err, "Failed to create issue")
+	}
+	return s.app.issueProto(ctx, issue), nil
+}
+
+func (s issueServer) UpdateIssue(ctx context.Context, req *formpb.UpdateIssueRequest) (*formpb.Issue, error) {
+	user := contextUser(ctx)
+	issue, err := s.app.accessibleIssue(ctx, user, uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err, "Error loading issue")
+	}
+
+	u := issueUpdate{
+		Title:     req.Title,
+		Details:   req.Details,
+		State:     req.State,
+		Assignee:  req.Assignee,
+		Component: req.Component,
+	}
+	if req.Priority != nil {
+		priority := int(*req.Priority)
+		u.Priority = &priority
+	}
+	if err := s.app.updateIssue(ctx, &issue, u, user.Username); err != nil {
+		return nil, grpcError(err, "Failed to update issue")
+	}
+	return s.app.issueProto(ctx, issue), nil
+}
+
+func (s issueServer) ListComments(ctx context.Context, req *formpb.ListCommentsRequest) (*formpb.ListCommentsResponse, error) {
+	issue, err := s.app.accessibleIssue(ctx, contextUser(ctx), uint(req.IssueId))
+	if err != nil {
+		return nil, grpcError(err, "Error loading issue")
+	}
+	comments, err := s.app.issueComments(ctx, issue.ID)
+	if err != nil {
+		return nil, grpcError(err, "Error loading comments")
+	}
+
+	resp := &formpb.ListCommentsResponse{}
+	for _, c := range comments {
+		resp.Comments = append(resp.Comments, s.app.commentProto(ctx, c))
+	}
+	return resp, nil
+}
+
+func (s issueServer) AddComment(ctx context.Context, req *formpb.AddCommentRequest) (*formpb.Comment, error) {
+	user := contextUser(ctx)
+	issue, err := s.app.accessibleIssue(ctx, user, uint(req.IssueId))
+	if err != nil {
+		return nil, grpcError(err, "Error loading issue")
+	}
+	comment, err := s.app.addComment(ctx, &issue, user.Username, req.Body)
+	if err != nil {
+		return nil, grpcError(err, "Failed to add comment")
+	}
+	return s.app.commentProto(ctx, comment), nil
+}
+
+type contactServer struct {
+	app *App
+	formpb.UnimplementedContactServiceServer
+}
+
+func (a *App) contactProto(ctx context.Context, c models.Contact) *formpb.Contact {
+	c = a.visibleTo(viewerRole(contextUser(ctx)), c).(models.Contact)
+	return &formpb.Contact{
+		Id:                uint64(c.ID),
+		Email:             c.Email,
+		FullName:          c.FullName,
+		Timestamp:         c.Timestamp,
+		TwitterProfile:    c.TwitterProfile,
+		LinkedinProfile:   c.LinkedinProfile,
+		Suppressed:        c.Suppressed,
+		SuppressedAt:      timestampOrNil(c.SuppressedAt),
+		SuppressionReason: c.SuppressionReason,
+		SuppressionNote:   c.SuppressionNote,
+	}
+}
+
+func (s contactServer) GetContact(ctx context.Context, req *formpb.GetContactRequest) (*formpb.Contact, error) {
+	contact, err := s.app.findContact(ctx, uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err, "Error loading contact")
+	}
+	return s.app.contactProto(ctx, contact), nil
+}
+
+func (s contactServer) ListContacts(ctx context.Context, req *formpb.ListContactsRequest) (*formpb.ListContactsResponse, error) {
+	limit, before, err := grpcPage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := s.app.repo.ListContacts(ctx, req.IncludeSuppressed, before, limit)
+	if err != nil {
+		return nil, grpcError(err, "Error loading contacts")
+	}
+
+	resp := &formpb.ListContactsResponse{}
+	for _, c := range contacts {
+		resp.Contacts = append(resp.Contacts, s.app.contactProto(ctx, c))
+	}
+	if len(contacts) > 0 {
+		resp.NextPageToken = nextPageToken(len(contacts), limit, contacts[len(contacts)-1].ID)
+	}
+	return resp, nil
+}
+
+func (s contactServer) SuppressContact(ctx context.Context, req *formpb.SuppressContactRequest) (*formpb.SuppressContactResponse, error) {
+	n, err := s.app.suppressByRequest(ctx, suppressionRequest{Email: req.Email, Reason: req.Reason, Note: req.Note})
+	if err != nil {
+		return nil, grpcError(err, "Failed to suppress contact")
+	}
+	return &formpb.SuppressContactResponse{Suppressed: n}, nil
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const csvUploadRoute = "/upload-csv"
+
+// createAdminUser creates an operator account and makes it an admin of the
+// default organization.
+func createAdminUser(q *gorm.DB, admin *models.User) error {
+	if err := q.Create(admin).Error; err != nil {
+		return err
+	}
+	if _, err := addMember(q, models.DefaultOrganizationID, admin.ID, orgRoleAdmin); err != nil {
+		return fmt.Errorf("adding to the default organization: %w", err)
+	}
+	return nil
+}
+
+func (a *App) registerHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.currentSettings().RegistrationOpen {
+		writeError(w, http.StatusForbidden, "Registration is closed")
+		return
+	}
+
+	var newUser models.User
+	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &newUser) {
+		return
+	}
+	// Self-registered accounts never get a role or choose their ID
+	newUser.ID, newUser.Role = 0, ""
+
+	// Check if the username is already taken
+	if _, err := a.repo.FindUserByUsername(r.Context(), newUser.Username); err == nil {
+		writeError(w, http.StatusConflict, "Username already taken")
+		return
+	}
+
+	// Create the new user. Self-registered accounts join the default
+	// organization; other organizations add their members themselves.
+	if err := a.repo.CreateUser(r.Context(), &newUser, models.DefaultOrganizationID, orgRoleMember); err != nil {
+		writeDBError(w, err, "Failed to create user")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, map[string]string{"message": localize(w, "User created successfully")})
+}
+
+func (a *App) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var loginDetails models.User
+	if err := json.NewDecoder(r.Body).Decode(&loginDetails); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	// Check if the user exists
+	user, err := a.repo.FindUserByUsername(r.Context(), loginDetails.Username)
+	if err != nil || user.Password != loginDetails.Password {
+		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if user.DisabledAt != nil {
+		writeServiceError(w, errAccountDisabled, "Login failed")
+		return
+	}
+	if user.PasswordResetRequired {
+		writeServiceError(w, errResetRequired, "Login failed")
+		return
+	}
+
+	// Issue a bearer token for authenticated endpoints
+	token, err := a.issueToken(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	a.encodeJSON(w, r, map[string]interface{}{"message": localize(w, "Login successful"), "user": newProfile(user), "token": token})
+}
+
+func (a *App) uploadCSVHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(a.currentSettings().UploadLimitMB << 20)
+	if bodyTooLarge(err) {
+		writeError(w, http.StatusRequestEntityTooLarge, "Body too large")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Unable to parse form")
+		return
+	}
+
+	file, header, err := r.FormFile("csvFile")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Error retrieving file")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Error reading CSV file")
+		return
+	}
+	if err := a.scanUpload(r.Context(), header.Filename, content); err != nil {
+		if errors.Is(err, errUploadRejected) {
+			writeError(w, http.StatusUnprocessableEntity, "File rejected by upload scanner")
+			return
+		}
+		log.Println("Error scanning upload:", err)
+		writeError(w, http.StatusServiceUnavailable, "Upload scanning unavailable")
+		return
+	}
+
+	// Run the upload through the import pipeline while the client waits
+	user := "anonymous"
+	if u, err := currentUser(r); err == nil {
+		user = u.Username
+	}
+	job := models.ImportJob{Source: "csv_file", Status: importQueued, RequestedBy: user}
+	if err := a.dbCtx(r.Context()).Create(&job).Error; err != nil {
+		log.Println("Error creating import job:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+	if err := a.runImportJob(r.Context(), &job, csvFileConnector{content: content}, nil); err != nil {
+		if errors.Is(err, errMissingColumns) {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if errors.Is(err, errImportAborted) {
+			writeError(w, http.StatusConflict, "Import aborted")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Error importing CSV file")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(localize(w, "CSV file uploaded and data saved to database")))
+}
+
+func (a *App) loginByEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var loginDetails struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&loginDetails); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &loginDetails) {
+		return
+	}
+
+	// Check if the email exists in the 'emails' table
+	var existingEmail string
+	err := a.dbCtx(r.Context()).Table("emails").Where("email = ?", loginDetails.Email).Select("email").Row().Scan(&existingEmail)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	a.encodeJSON(w, r, map[string]interface{}{"message": localize(w, "Login successful"), "email": existingEmail})
+}
+
+func (a *App) reportIssueHandler(w http.ResponseWriter, r *http.Request) {
+	var newIssue models.Issue
+
+	// Parse the JSON request body
+	err := json.NewDecoder(r.Body).Decode(&newIssue)
+	if err != nil {
+		log.Println("Error decoding JSON:", err)
+		writeBodyError(w, err)
+		return
+	}
+
+	user, _ := currentUser(r)
+	issue, err := a.createIssue(r.Context(), user, newIssue)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create issue")
+		return
+	}
+
+	// Respond with a success message
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"message": localize(w, "Issue reported successfully"), "id": issue.ID})
+}
+
+func (a *App) getIssueByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the ID from the URL parameters
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+
+	// Check if ID is empty or invalid
+	if !ok || id == "" {
+		log.Println("Empty or invalid issue ID")
+		writeError(w, http.StatusBadRequest, "Invalid issue ID")
+		return
+	}
+
+	// Parse the ID into uint
+	issueID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		log.Println("Error parsing ID:", err)
+		writeError(w, http.StatusBadRequest, "Invalid issue ID")
+		return
+	}
+
+	// Query the database for the issue with the specified ID
+	// Issues the user cannot see are treated as missing rather than forbidden
+	user, _ := currentUser(r)
+	foundIssue, err := a.cachedAccessibleIssue(r.Context(), user, uint(issueID))
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving issue")
+		return
+	}
+
+	// Respond with the found issue
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", issueETag(foundIssue))
+	a.encodeJSON(w, r, foundIssue)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"form/storage"
+)
+
+// Build information, set with
+// -ldflags "-X main.version=1.2.3 -X main.gitSHA=$(git rev-parse HEAD)".
+var (
+	version = "dev"
+	gitSHA  = ""
+)
+
+var startedAt = time.Now()
+
+func buildSHA() string {
+	if gitSHA != "" {
+		return gitSHA
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// healthzHandler reports that the process is up.
+func (a *App) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"status":  "ok",
+		"version": version,
+		"gitSHA":  buildSHA(),
+		"uptime":  time.Since(startedAt).Round(time.Second).String(),
+	})
+}
+
+// readyzHandler reports whether this instance can serve traffic: the
+// database answers, the upload directory is writable and the schema is
+// up to date.
+func (a *App) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := map[string]string{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	check("database", a.pingDB(ctx))
+	check("storage", a.storage.Check(ctx))
+	check("migrations", a.checkMigrations(ctx))
+	if a.shuttingDown.Load() {
+		checks["server"] = "shutting down"
+		ready = false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	a.encodeJSON(w, r, map[string]interface{}{
+		"ready":   ready,
+		"checks":  checks,
+		"version": version,
+		"gitSHA":  buildSHA(),
+	})
+}
+
+func (a *App) pingDB(ctx context.Context) error {
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkMigrations reports an error unless the database schema is at the
+// latest embedded version and not left dirty by a failed migration.
+func (a *App) checkMigrations(ctx context.Context) error {
+	if storage.UsingSQLite(a.dbCtx(ctx)) {
+		return nil
+	}
+	latest, err := storage.LatestMigration()
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+	row := a.dbCtx(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Row()
+	if err := row.Scan(&version, &dirty); err != nil {
+		return errors.New("pending")
+	}
+	if dirty {
+		return fmt.Errorf("version %d is dirty", version)
+	}
+	if version < latest {
+		return fmt.Errorf("pending: at %d, latest %d", version, latest)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Issue event types recorded in an issue's history.
+const (
+	eventCreated         = "created"
+	eventCommentAdded    = "comment_added"
+	eventAttachmentAdded = "attachment_added"
+	eventLabelsChanged   = "labels_changed"
+	eventLabelsMerged    = "labels_merged"
+	eventStateChanged    = "state_changed"
+	eventReopened        = "reopened"
+	eventAssigned        = "assigned"
+	eventReporterChanged = "reporter_changed"
+	eventComponentSet    = "component_changed"
+	eventFixVersionSet   = "fix_version_changed"
+	eventProjectChanged  = "project_changed"
+	eventPriorityChanged = "priority_changed"
+	eventDueDateChanged  = "due_date_changed"
+	eventStaleWarning    = "stale_warning"
+	eventAutoClosed      = "auto_closed"
+)
+
+// recordIssueEvent appends an event to an issue's history using q, which may
+// be a transaction, and tells live subscribers the issue changed. New
+// comments are announced by addComment, which knows the comment.
+func (a *App) recordIssueEvent(q *gorm.DB, issueID uint, eventType, actor, from, to string) error {
+	if err := q.Create(&models.IssueEvent{IssueID: issueID, Type: eventType, Actor: actor, FromValue: from, ToValue: to}).Error; err != nil {
+		return err
+	}
+	switch eventType {
+	case eventCreated:
+		return a.notifyLive(q, liveIssueCreated, issueID, 0)
+	case eventCommentAdded:
+		return nil
+	}
+	return a.notifyLive(q, liveIssueUpdated, issueID, 0)
+}
+
+// actorName returns the username behind a request, or "anonymous".
+func actorName(r *http.Request) string {
+	if user, err := currentUser(r); err == nil {
+		return user.Username
+	}
+	return "anonymous"
+}
+
+// loadAccessibleIssue fetches the issue named in the URL if the current user
+// may see it, writing an error response otherwise.
+func (a *App) loadAccessibleIssue(w http.ResponseWriter, r *http.Request) (models.Issue, bool) {
+	var issue models.Issue
+	user, _ := currentUser(r)
+
+	issueID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid issue ID")
+		return issue, false
+	}
+	if issue, err = a.accessibleIssue(r.Context(), user, uint(issueID)); err != nil {
+		writeServiceError(w, err, "Error loading issue")
+		return issue, false
+	}
+	return issue, true
+}
+
+func (a *App) issueHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	var events []models.IssueEvent
+	if err := a.dbCtx(r.Context()).Where("issue_id = ?", issue.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		log.Println("Error loading issue history:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading issue history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, events)
+}
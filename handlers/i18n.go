@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// Message catalogs map the English text of a message to its translation.
+// English needs no catalog; messages missing from one stay in English.
+// Messages that vary with a count are keyed by their English plural and
+// the CLDR plural form, e.g. "%d new issues|one"; see translatePlural.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+const (
+	defaultLocale         = "en"
+	contentLanguageHeader = "Content-Language"
+)
+
+var (
+	catalogs      = loadCatalogs()
+	localeTags    = []language.Tag{language.English, language.Spanish, language.Hindi}
+	localeMatcher = language.NewMatcher(localeTags)
+)
+
+func loadCatalogs() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := map[string]map[string]string{}
+	for _, f := range files {
+		b, err := localeFiles.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(b, &catalog); err != nil {
+			panic(fmt.Sprintf("locales/%s: %s", f.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = catalog
+	}
+	return catalogs
+}
+
+// negotiateLocale picks the supported locale that best fits an
+// Accept-Language header.
+func negotiateLocale(acceptLanguage string) string {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, i, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return defaultLocale
+	}
+	base, _ := localeTags[i].Base()
+	return base.String()
+}
+
+// localeMiddleware negotiates the response language and announces it in
+// Content-Language, which is also where writeError looks it up.
+func localeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set(contentLanguageHeader, negotiateLocale(r.Header.Get("Accept-Language")))
+		h.Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseLocale returns the locale negotiated for a response.
+func responseLocale(w http.ResponseWriter) string {
+	if locale := w.Header().Get(contentLanguageHeader); locale != "" {
+		return locale
+	}
+	return defaultLocale
+}
+
+// translate returns message in locale, or unchanged if it has no
+// translation.
+func translate(locale, message string) string {
+	if t, ok := catalogs[locale][message]; ok {
+		return t
+	}
+	return message
+}
+
+// localize translates a message for the response being written.
+func localize(w http.ResponseWriter, message string) string {
+	return translate(responseLocale(w), message)
+}
+
+// localizef translates format for the response being written and fills it
+// in. Arguments are identifiers and values, so they are left as they are.
+func localizef(w http.ResponseWriter, format string, args ...interface{}) string {
+	return fmt.Sprintf(localize(w, format), args...)
+}
+
+// formatDate writes a date the way locale writes it in prose. The catalog
+// entry for the English layout orders day, month name and year.
+func formatDate(locale string, t time.Time) string {
+	return fmt.Sprintf(translate(locale, "%[2]s %[1]d, %[3]d"), t.Day(), translate(locale, t.Month().String()), t.Year())
+}
+
+// supportedLocales lists the locales there are messages in.
+func supportedLocales() []string {
+	locales := make([]string, len(localeTags))
+	for i, tag := range localeTags {
+		base, _ := tag.Base()
+		locales[i] = base.String()
+	}
+	return locales
+}
+
+func supportedLocale(locale string) bool {
+	for _, l := range supportedLocales() {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// recipientLocale picks the language to write to user in: their own
+// preference, else the default of the organization orgID, else English.
+func recipientLocale(q *gorm.DB, user models.User, orgID uint) string {
+	if supportedLocale(user.Language) {
+		return user.Language
+	}
+	var org models.Organization
+	if q.Select("default_language").First(&org, orgID).Error == nil && supportedLocale(org.DefaultLanguage) {
+		return org.DefaultLanguage
+	}
+	return defaultLocale
+}
+
+var pluralForms = map[plural.Form]string{
+	plural.Other: "other", plural.Zero: "zero", plural.One: "one",
+	plural.Two: "two", plural.Few: "few", plural.Many: "many",
+}
+
+// translatePlural returns the form of a counted message that fits n in
+// locale, given its English singular and plural. Forms a catalog leaves
+// out fall back to its "other" form, then to English.
+func translatePlural(locale string, n int, one, other string) string {
+	tag := language.Make(locale)
+	form := plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0)
+	if locale == defaultLocale {
+		if form == plural.One {
+			return one
+		}
+		return other
+	}
+	if t, ok := catalogs[locale][other+"|"+pluralForms[form]]; ok {
+		return t
+	}
+	if t, ok := catalogs[locale][other+"|other"]; ok {
+		return t
+	}
+	if n == 1 {
+		return one
+	}
+	return other
+}
+
+// pluralf fills in the form of a counted message that fits n. The count is
+// the first argument.
+func pluralf(locale string, n int, one, other string, args ...interface{}) string {
+	return fmt.Sprintf(translatePlural(locale, n, one, other), append([]interface{}{n}, args...)...)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"form/models"
+)
+
+func TestPluralf(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en", 0, "0 new issues"},
+		{"en", 1, "1 new issue"},
+		{"en", 2, "2 new issues"},
+		{"es", 1, "1 incidencia nueva"},
+		{"es", 0, "0 incidencias nuevas"},
+		{"es", 5, "5 incidencias nuevas"},
+		// Hindi treats zero like one
+		{"hi", 0, "0 नई समस्या"},
+		{"hi", 1, "1 नई समस्या"},
+		{"hi", 2, "2 नई समस्याएँ"},
+	}
+	for _, tt := range tests {
+		if got := pluralf(tt.locale, tt.n, "%d new issue", "%d new issues"); got != tt.want {
+			t.Errorf("pluralf(%s, %d) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPluralfArguments(t *testing.T) {
+	got := pluralf("es", 3, "Issue #%[2]d is due in %[1]d hour", "Issue #%[2]d is due in %[1]d hours", 42)
+	if want := "La incidencia #42 vence en 3 horas"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// Messages without a translation stay in English
+	got = pluralf("es", 1, "%d untranslated thing", "%d untranslated things")
+	if want := "1 untranslated thing"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCatalogPluralsHaveOther(t *testing.T) {
+	forms := map[string]bool{}
+	for _, name := range pluralForms {
+		forms[name] = true
+	}
+	for locale, catalog := range catalogs {
+		for key := range catalog {
+			base, form, ok := strings.Cut(key, "|")
+			if !ok {
+				continue
+			}
+			if !forms[form] {
+				t.Errorf("%s: %q has unknown plural form %q", locale, key, form)
+			}
+			if _, ok := catalog[base+"|other"]; !ok {
+				t.Errorf("%s: %q has no other form", locale, base)
+			}
+		}
+	}
+}
+
+func TestRenderDigestLocale(t *testing.T) {
+	report := digestReport{
+		Locale:       "es",
+		From:         time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC),
+		To:           time.Date(2026, time.March, 3, 9, 0, 0, 0, time.UTC),
+		NewIssues:    1,
+		ClosedIssues: 4,
+		TopOpen:      []issueSummary{{ID: 7, Title: "Login broken", Priority: 3}},
+	}
+	text := renderDigest(report)
+	for _, want := range []string{
+		"Actividad del 2 de marzo de 2026 09:00 al 3 de marzo de 2026 09:00 (UTC)",
+		"1 incidencia nueva\n",
+		"4 incidencias cerradas\n",
+		"0 contactos importados\n",
+		"- #7 Login broken (prioridad 3)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest is missing %q:\n%s", want, text)
+		}
+	}
+	if got, want := digestSubject(digestWeekly, report), "Resumen semanal: 1 nuevas, 4 cerradas"; got != want {
+		t.Errorf("subject = %q, want %q", got, want)
+	}
+
+	report.Locale = defaultLocale
+	if text := renderDigest(report); !strings.Contains(text, "1 new issue\n") || !strings.Contains(text, "4 issues closed\n") {
+		t.Errorf("English digest is not pluralized:\n%s", text)
+	}
+}
+
+func TestNotificationMessage(t *testing.T) {
+	n := models.Notification{Kind: notificationStateChanged, Actor: "ana", Title: "Login broken", Detail: stateClosed}
+	if got, want := notificationMessage("es", n), "ana movió Login broken a Cerrada"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := notificationMessage(defaultLocale, n), "ana moved Login broken to Closed"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	n = models.Notification{Kind: notificationImportFinished, Actor: systemActor, Detail: importFailed}
+	if got, want := notificationMessage("hi", n), "आपका संपर्क आयात विफल रहा"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Import job statuses.
+const (
+	importQueued    = "queued"
+	importRunning   = "running"
+	importCompleted = "completed"
+	importFailed    = "failed"
+	importAborted   = "aborted"
+)
+
+// importAbortCheckInterval is how often a running import checks whether it
+// was aborted through another instance.
+const importAbortCheckInterval = 5 * time.Second
+
+var errImportAborted = errors.New("import aborted")
+
+// defaultColumnMapping maps contact fields to the headers of the exported
+// form CSVs. Imports may override any of them.
+var defaultColumnMapping = map[string]string{
+	"email":            "Email Address",
+	"full_name":        "Full Name",
+	"timestamp":        "Timestamp",
+	"twitter_profile":  "Twitter Profile",
+	"linkedin_profile": "LinkedIn Profile",
+}
+
+var errMissingColumns = errors.New("required columns not found")
+
+type importStats struct {
+	Total    int
+	Inserted int
+	Skipped  int
+	Invalid  int
+}
+
+// columnMapping merges overrides into the default mapping.
+func columnMapping(overrides map[string]string) map[string]string {
+	m := map[string]string{}
+	for field, header := range defaultColumnMapping {
+		m[field] = header
+	}
+	for field, header := range overrides {
+		if _, ok := m[field]; ok && header != "" {
+			m[field] = header
+		}
+	}
+	return m
+}
+
+// importBatchSize is how many contacts each lookup and insert of an import
+// covers.
+const importBatchSize = 500
+
+// importRecords maps, validates and de-duplicates records, then inserts the
+// new contacts in a single transaction. The first record is the header row.
+func (a *App) importRecords(ctx context.Context, records [][]string, mapping map[string]string) (importStats, error) {
+	var stats importStats
+	mapping = columnMapping(mapping)
+
+	// Find the indices of the columns
+	index := map[string]int{}
+	if len(records) > 0 {
+		for i, header := range records[0] {
+			for field, want := range mapping {
+				if header == want {
+					index[field] = i
+				}
+			}
+		}
+	}
+
+	// If any required column not found, there is nothing to import
+	var missing []string
+	for field, header := range mapping {
+		if _, ok := index[field]; !ok {
+			missing = append(missing, header)
+		}
+	}
+	if len(missing) > 0 {
+		return stats, fmt.Errorf("%w: %s", errMissingColumns, strings.Join(missing, ", "))
+	}
+
+	now := time.Now()
+	var candidates []models.Contact
+	seen := map[string]bool{}
+	for _, record := range records[1:] { // Skip the header row
+		stats.Total++
+
+		value := func(field string) string {
+			if i := index[field]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		email := value("email")
+		if !strings.Contains(email, "@") {
+			stats.Invalid++
+			continue
+		}
+		if seen[email] {
+			stats.Skipped++
+			continue
+		}
+		seen[email] = true
+		candidates = append(candidates, models.Contact{
+			Email:           email,
+			FullName:        value("full_name"),
+			Timestamp:       value("timestamp"),
+			TwitterProfile:  value("twitter_profile"),
+			LinkedinProfile: value("linkedin_profile"),
+			LastActiveAt:    now,
+		})
+	}
+
+	tx := a.dbCtx(ctx).Begin()
+	err := func() error {
+		var fresh []models.Contact
+		for start := 0; start < len(candidates); start += importBatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			batch := candidates[start:min(start+importBatchSize, len(candidates))]
+			kept, err := newContacts(tx, batch, now)
+			if err != nil {
+				return err
+			}
+			stats.Skipped += len(batch) - len(kept)
+			fresh = append(fresh, kept...)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(fresh, importBatchSize).Error; err != nil {
+			return fmt.Errorf("inserting contacts: %w", err)
+		}
+		stats.Inserted = len(fresh)
+		return nil
+	}()
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return stats, err
+}
+
+// newContacts returns the contacts in batch whose address is not on file,
+// leaving out addresses that opted out under any casing so they never come
+// back. Contacts already on file count as active again. Addresses a merge
+// folded into another contact are on file under any casing too.
+func newContacts(tx *gorm.DB, batch []models.Contact, now time.Time) ([]models.Contact, error) {
+	emails := make([]string, len(batch))
+	lowered := make([]string, len(batch))
+	for i, c := range batch {
+		emails[i] = c.Email
+		lowered[i] = strings.ToLower(c.Email)
+	}
+
+	// Seeing a contact again keeps it from being archived as inactive
+	if err := tx.Model(&models.Contact{}).Where("lower(email) IN (?)", lowered).UpdateColumn("last_active_at", now).Error; err != nil {
+		return nil, fmt.Errorf("updating last activity: %w", err)
+	}
+	var existing []string
+	if err := tx.Model(&models.Contact{}).Where("email IN (?)", emails).Pluck("email", &existing).Error; err != nil {
+		return nil, fmt.Errorf("checking existing emails: %w", err)
+	}
+	var merged []string
+	if err := tx.Model(&models.ContactLink{}).Where("kind = ? AND lower(url) IN (?)", linkEmail, lowered).Pluck("lower(url)", &merged).Error; err != nil {
+		return nil, fmt.Errorf("checking merged emails: %w", err)
+	}
+	var suppressed []string
+	if err := tx.Model(&models.Contact{}).Where("lower(email) IN (?) AND suppressed = ?", lowered, true).Pluck("lower(email)", &suppressed).Error; err != nil {
+		return nil, fmt.Errorf("checking suppressions: %w", err)
+	}
+
+	onFile := map[string]bool{}
+	for _, email := range existing {
+		onFile[email] = true
+	}
+	for _, email := range merged {
+		onFile[email] = true
+	}
+	optedOut := map[string]bool{}
+	for _, email := range suppressed {
+		optedOut[email] = true
+	}
+	var kept []models.Contact
+	for _, c := range batch {
+		if !onFile[c.Email] && !onFile[strings.ToLower(c.Email)] && !optedOut[strings.ToLower(c.Email)] {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// runImportJob fetches records from conn and feeds them through the import
+// pipeline, recording progress on job. Contacts go to the job's
+// organization.
+func (a *App) runImportJob(ctx context.Context, job *models.ImportJob, conn SourceConnector, mapping map[string]string) error {
+	ctx = withOrganization(ctx, job.OrganizationID)
+	ctx, span := tracer.Start(ctx, "import.run", trace.WithAttributes(
+		attribute.Int("import.job_id", int(job.ID)),
+		attribute.String("import.source", job.Source),
+	))
+	defer span.End()
+
+	// Only a queued job starts, so one aborted while waiting stays aborted
+	now := time.Now()
+	started := a.dbCtx(ctx).Model(job).Where("status = ?", importQueued).Updates(map[string]interface{}{"status": importRunning, "started_at": now})
+	if started.Error != nil {
+		return started.Error
+	}
+	if started.RowsAffected == 0 {
+		return errImportAborted
+	}
+	job.Status, job.StartedAt = importRunning, &now
+
+	// Aborting cancels ctx, which rolls back the import transaction
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go a.watchImportAbort(ctx, job.ID, cancel)
+
+	records, err := conn.Fetch(ctx, a.connectorClient)
+	var stats importStats
+	if err == nil {
+		stats, err = a.importRecords(ctx, records, mapping)
+	}
+	if err != nil && errors.Is(context.Cause(ctx), errImportAborted) {
+		err = errImportAborted
+	}
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Total, job.Inserted, job.Skipped, job.Invalid = stats.Total, stats.Inserted, stats.Skipped, stats.Invalid
+	job.Status = importCompleted
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		job.Status = importFailed
+		if errors.Is(err, errImportAborted) {
+			job.Status = importAborted
+		}
+		job.Error = err.Error()
+		// A rolled back transaction inserted nothing
+		job.Inserted = 0
+	}
+	// Record the outcome even though ctx may be cancelled by now
+	if saveErr := a.dbCtx(context.WithoutCancel(ctx)).Save(job).Error; saveErr != nil {
+		log.Printf("Error saving import job %d: %s", job.ID, saveErr)
+	}
+	if notifyErr := notifyImportFinished(a.dbCtx(context.WithoutCancel(ctx)), job); notifyErr != nil {
+		log.Printf("Error notifying about import job %d: %s", job.ID, notifyErr)
+	}
+
+	span.SetAttributes(attribute.Int("import.rows", job.Total), attribute.Int("import.inserted", job.Inserted))
+	log.Printf("Import job %d %s: %d rows, %d inserted, %d skipped, %d invalid", job.ID, job.Status, job.Total, job.Inserted, job.Skipped, job.Invalid)
+	return err
+}
+
+// watchImportAbort cancels a running import with errImportAborted once its
+// job is marked aborted, until ctx is done.
+func (a *App) watchImportAbort(ctx context.Context, id uint, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(importAbortCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var status []string
+			if err := a.dbCtx(ctx).Model(&models.ImportJob{}).Where("id = ?", id).Pluck("status", &status).Error; err != nil {
+				continue
+			}
+			if len(status) == 1 && status[0] == importAborted {
+				cancel(errImportAborted)
+				return
+			}
+		}
+	}
+}
+
+// startImport queues an import job in ctx's organization and runs it in the
+// background.
+func (a *App) startImport(ctx context.Context, job *models.ImportJob, conn SourceConnector, mapping map[string]string) error {
+	job.Status = importQueued
+	if err := a.dbCtx(ctx).Create(job).Error; err != nil {
+		return err
+	}
+	// The worker gets its own copy so the caller can keep reading job
+	running := *job
+	a.goBackground(a.ctx, func(ctx context.Context) {
+		a.runImportJob(ctx, &running, conn, mapping)
+	})
+	return nil
+}
+
+func parseMapping(raw string) map[string]string {
+	m := map[string]string{}
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &m)
+	}
+	return m
+}
+
+// importRequest names the connector to import from, its settings, and an
+// optional CSV header to contact field mapping.
+type importRequest struct {
+	Source  string            `json:"source"`
+	Config  json.RawMessage   `json:"config"`
+	Mapping map[string]string `json:"mapping"`
+}
+
+// createImportHandler starts a one-off import from any registered source.
+func (a *App) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	var body importRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	conn, err := newConnector(body.Source, body.Config)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := models.ImportJob{Source: body.Source, RequestedBy: user.Username}
+	if err := a.startImport(r.Context(), &job, conn, body.Mapping); err != nil {
+		log.Println("Error creating import job:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	a.encodeJSON(w, r, job)
+}
+
+func (a *App) listImportsHandler(w http.ResponseWriter, r *http.Request) {
+	var jobs []models.ImportJob
+	if err := a.dbCtx(r.Context()).Order("id desc").Limit(100).Find(&jobs).Error; err != nil {
+		log.Println("Error loading import jobs:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading import jobs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, jobs)
+}
+
+func (a *App) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	var job models.ImportJob
+	if err := a.dbCtx(r.Context()).First(&job, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Import job not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, job)
+}
+
+// abortImportHandler stops a queued or running import. The instance running
+// it notices within importAbortCheckInterval and rolls back what it had
+// imported.
+func (a *App) abortImportHandler(w http.ResponseWriter, r *http.Request) {
+	var job models.ImportJob
+	if err := a.dbCtx(r.Context()).First(&job, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Import job not found")
+		return
+	}
+
+	now := time.Now()
+	result := a.dbCtx(r.Context()).Model(&job).Where("status IN (?)", []string{importQueued, importRunning}).
+		Updates(map[string]interface{}{"status": importAborted, "finished_at": now})
+	if result.Error != nil {
+		writeDBError(w, result.Error, "Failed to abort import")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusConflict, "Import job is not running")
+		return
+	}
+	job.Status, job.FinishedAt = importAborted, &now
+	log.Printf("Import job %d aborted by %s", job.ID, actorName(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, job)
+}
+
+func (a *App) createImportSourceHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	var body struct {
+		Name        string            `json:"name"`
+		Connector   string            `json:"connector"`
+		Config      json.RawMessage   `json:"config"`
+		Mapping     map[string]string `json:"mapping"`
+		PollMinutes int               `json:"pollMinutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if _, err := newConnector(body.Connector, body.Config); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.PollMinutes < 0 {
+		writeError(w, http.StatusBadRequest, "pollMinutes must not be negative")
+		return
+	}
+
+	mapping, _ := json.Marshal(body.Mapping)
+	source := models.ImportSource{
+		Name:        body.Name,
+		Connector:   body.Connector,
+		Config:      string(body.Config),
+		Mapping:     string(mapping),
+		PollMinutes: body.PollMinutes,
+		CreatedBy:   user.Username,
+	}
+	if err := a.dbCtx(r.Context()).Create(&source).Error; err != nil {
+		log.Println("Error creating import source:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create import source")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, source)
+}
+
+func (a *App) listImportSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	var sources []models.ImportSource
+	if err := a.dbCtx(r.Context()).Order("id").Find(&sources).Error; err != nil {
+		log.Println("Error loading import sources:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading import sources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, sources)
+}
+
+func (a *App) deleteImportSourceHandler(w http.ResponseWriter, r *http.Request) {
+	result := a.dbCtx(r.Context()).Where("id = ?", mux.Vars(r)["id"]).Delete(&models.ImportSource{})
+	if result.Error != nil {
+		log.Println("Error deleting import source:", result.Error)
+		writeError(w, http.StatusInternalServerError, "Failed to delete import source")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusNotFound, "Import source not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) runImportSourceHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	var source models.ImportSource
+	if err := a.dbCtx(r.Context()).First(&source, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Import source not found")
+		return
+	}
+
+	job, err := a.runImportSource(r.Context(), source, user.Username)
+	if err != nil {
+		log.Println("Error starting import:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	a.encodeJSON(w, r, job)
+}
+
+func (a *App) runImportSource(ctx context.Context, source models.ImportSource, requestedBy string) (*models.ImportJob, error) {
+	conn, err := newConnector(source.Connector, json.RawMessage(source.Config))
+	if err != nil {
+		return nil, err
+	}
+	job := &models.ImportJob{Source: source.Connector, SourceID: &source.ID, RequestedBy: requestedBy}
+	if err := a.startImport(withOrganization(ctx, source.OrganizationID), job, conn, parseMapping(source.Mapping)); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// pollImportSources starts imports for sources whose poll interval has
+// elapsed. Each run is claimed by moving last_run_at forward conditionally,
+// so only one instance starts it.
+func (a *App) pollImportSources(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Sources from every organization are polled; each runs in its own
+		all := allOrganizations(ctx)
+		var sources []models.ImportSource
+		if err := a.dbCtx(all).Where("poll_minutes > 0").Find(&sources).Error; err != nil {
+			log.Println("Error loading import sources:", err)
+			continue
+		}
+
+		now := time.Now()
+		for _, source := range sources {
+			if source.LastRunAt != nil && now.Sub(*source.LastRunAt) < time.Duration(source.PollMinutes)*time.Minute {
+				continue
+			}
+
+			claim := a.dbCtx(all).Model(&models.ImportSource{}).Where("id = ?", source.ID)
+			if source.LastRunAt == nil {
+				claim = claim.Where("last_run_at IS NULL")
+			} else {
+				claim = claim.Where("last_run_at = ?", *source.LastRunAt)
+			}
+			result := claim.Update("last_run_at", now)
+			if result.Error != nil || result.RowsAffected == 0 {
+				continue
+			}
+
+			if _, err := a.runImportSource(ctx, source, "scheduler"); err != nil {
+				log.Printf("Error starting import for source %d: %s", source.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"form/models"
+)
+
+// maxInboundWebhookBody bounds the body read by inbound webhook receivers.
+const maxInboundWebhookBody = 1 << 20
+
+// inboundDelivery is what a verifier learned from a signed delivery.
+type inboundDelivery struct {
+	// Nonce identifies the delivery. Providers resend the same value on
+	// retries, so it is only kept once the delivery has been handled.
+	Nonce string
+	// SentAt is when the provider signed the delivery; zero if it does not
+	// say.
+	SentAt time.Time
+}
+
+// inboundVerifier checks a provider's signature on a delivery.
+type inboundVerifier struct {
+	source string
+	// maxAge overrides cfg.InboundWebhookTolerance for providers whose
+	// retries keep the original timestamp.
+	maxAge time.Duration
+	verify func(a *App, r *http.Request, body []byte) (inboundDelivery, error)
+}
+
+var (
+	errInvalidSignature = errors.New("invalid signature")
+	errMissingSecret    = errors.New("receiver secret is not configured")
+)
+
+// inboundWebhook wraps a receiver for a third-party webhook with signature
+// verification, a timestamp tolerance check and nonce-based replay
+// protection. The receiver reads the already verified body from r.Body.
+func (a *App) inboundWebhook(v inboundVerifier, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxInboundWebhookBody+1))
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if len(body) > maxInboundWebhookBody {
+			writeError(w, http.StatusRequestEntityTooLarge, "Body too large")
+			return
+		}
+
+		d, err := v.verify(a, r, body)
+		if err != nil {
+			log.Printf("Rejected %s webhook: %s", v.source, err)
+			writeError(w, http.StatusUnauthorized, "Invalid signature")
+			return
+		}
+
+		if !d.SentAt.IsZero() {
+			maxAge := v.maxAge
+			if maxAge == 0 {
+				maxAge = a.cfg.InboundWebhookTolerance
+			}
+			if age := time.Since(d.SentAt); age > maxAge || age < -a.cfg.InboundWebhookTolerance {
+				log.Printf("Rejected %s webhook: timestamp %s outside the allowed window", v.source, d.SentAt)
+				writeError(w, http.StatusUnauthorized, "Stale or future timestamp")
+				return
+			}
+		}
+
+		if d.Nonce == "" {
+			log.Printf("Rejected %s webhook: no delivery ID", v.source)
+			writeError(w, http.StatusBadRequest, "Missing delivery ID")
+			return
+		}
+		fresh, err := a.claimInboundNonce(r.Context(), v.source, d.Nonce)
+		if err != nil {
+			writeDBError(w, err, "Failed to record delivery")
+			return
+		}
+		if !fresh {
+			writeError(w, http.StatusConflict, "Delivery already received")
+			return
+		}
+
+		// Let the provider retry deliveries the receiver failed to handle
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(rec, r)
+		if rec.status >= 500 {
+			a.releaseInboundNonce(r.Context(), v.source, d.Nonce)
+		}
+	}
+}
+
+// claimInboundNonce records a nonce, reporting false if it was already
+// recorded.
+func (a *App) claimInboundNonce(ctx context.Context, source, nonce string) (bool, error) {
+	result := a.dbCtx(ctx).Exec(`INSERT INTO inbound_nonces (source, nonce, received_at) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, source, nonce, time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (a *App) releaseInboundNonce(ctx context.Context, source, nonce string) {
+	if err := a.dbCtx(ctx).Where("source = ? AND nonce = ?", source, nonce).Delete(&models.InboundNonce{}).Error; err != nil {
+		log.Printf("Error releasing %s nonce: %s", source, err)
+	}
+}
+
+// pruneInboundNonces forgets nonces older than the retention period, beyond
+// which providers no longer retry.
+func (a *App) pruneInboundNonces(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-a.cfg.InboundNonceRetention)
+			if err := a.dbCtx(ctx).Where("received_at < ?", cutoff).Delete(&models.InboundNonce{}).Error; err != nil {
+				log.Println("Error pruning inbound nonces:", err)
+			}
+		}
+	}
+}
+
+// statusRecorder remembers the status a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// hmacHex returns the hex HMAC-SHA256 of message under secret.
+func hmacHex(secret string, message []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func equalSignatures(got, want string) bool {
+	return got != "" && hmac.Equal([]byte(got), []byte(want))
+}
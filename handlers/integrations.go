@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+)
+
+const (
+	githubActor = "github"
+	sentryActor = "sentry"
+)
+
+var githubInbound = inboundVerifier{source: "github", verify: (*App).verifyGitHubDelivery}
+
+// verifyGitHubDelivery checks X-Hub-Signature-256. GitHub sends no
+// timestamp, so replays are caught by the delivery ID alone.
+func (a *App) verifyGitHubDelivery(r *http.Request, body []byte) (inboundDelivery, error) {
+	if a.cfg.GitHubWebhookSecret == "" {
+		return inboundDelivery{}, errMissingSecret
+	}
+	if !equalSignatures(r.Header.Get("X-Hub-Signature-256"), "sha256="+hmacHex(a.cfg.GitHubWebhookSecret, body)) {
+		return inboundDelivery{}, errInvalidSignature
+	}
+	return inboundDelivery{Nonce: r.Header.Get("X-GitHub-Delivery")}, nil
+}
+
+// githubFixesPattern finds references such as "Fixes form#123" in pull
+// requests.
+var githubFixesPattern = regexp.MustCompile(`(?i)\b(?:fix(?:es|ed)?|close[sd]?|resolve[sd]?)\s+form#(\d+)`)
+
+// githubWebhookHandler closes the issues a merged pull request says it
+// fixes.
+func (a *App) githubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var event struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			Title   string `json:"title"`
+			Body    string `json:"body"`
+			Merged  bool   `json:"merged"`
+			HTMLURL string `json:"html_url"`
+		} `json:"pull_request"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	pr := event.PullRequest
+	if event.Action != "closed" || !pr.Merged {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	for _, m := range githubFixesPattern.FindAllStringSubmatch(pr.Title+"\n"+pr.Body, -1) {
+		id, _ := strconv.ParseUint(m[1], 10, 64)
+		issue, err := a.findIssue(r.Context(), uint(id))
+		if err == errIssueNotFound {
+			continue
+		}
+		if err == nil {
+			tx := a.dbCtx(r.Context()).Begin()
+			if err = a.setIssueState(tx, &issue, stateClosed, githubActor); err == nil {
+				err = tx.Commit().Error
+			} else {
+				tx.Rollback()
+			}
+		}
+		if err == nil {
+			_, err = a.addComment(r.Context(), &issue, githubActor, "Fixed by "+pr.HTMLURL)
+		}
+		if err != nil {
+			log.Println("Error closing issue from GitHub:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to close issue")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var sentryInbound = inboundVerifier{source: "sentry", verify: (*App).verifySentryDelivery}
+
+// verifySentryDelivery checks Sentry-Hook-Signature, an HMAC of the body
+// under the integration's client secret.
+func (a *App) verifySentryDelivery(r *http.Request, body []byte) (inboundDelivery, error) {
+	if a.cfg.SentryWebhookSecret == "" {
+		return inboundDelivery{}, errMissingSecret
+	}
+	if !equalSignatures(r.Header.Get("Sentry-Hook-Signature"), hmacHex(a.cfg.SentryWebhookSecret, body)) {
+		return inboundDelivery{}, errInvalidSignature
+	}
+	ts, err := strconv.ParseInt(r.Header.Get("Sentry-Hook-Timestamp"), 10, 64)
+	if err != nil {
+		return inboundDelivery{}, err
+	}
+	return inboundDelivery{Nonce: r.Header.Get("Request-ID"), SentAt: time.Unix(ts, 0)}, nil
+}
+
+// sentryWebhookHandler files a bug report for each new Sentry issue.
+func (a *App) sentryWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var event struct {
+		Action string `json:"action"`
+		Data   struct {
+			Issue struct {
+				Title     string `json:"title"`
+				Culprit   string `json:"culprit"`
+				Permalink string `json:"permalink"`
+			} `json:"issue"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if r.Header.Get("Sentry-Hook-Resource") != "issue" || event.Action != "created" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	si := event.Data.Issue
+	_, err := a.createIssue(r.Context(), &models.User{Username: sentryActor}, models.Issue{
+		Title:      truncateRunes(si.Title, 255),
+		Details:    truncateRunes(strings.TrimSpace(si.Culprit+" "+si.Permalink), 255),
+		Type:       true,
+		ReportedBy: sentryActor,
+		ReportedAt: time.Now(),
+	})
+	if err != nil {
+		writeServiceError(w, err, "Failed to create issue")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// truncateRunes shortens s to at most n characters.
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
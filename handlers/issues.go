@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Issue states. Status stays true exactly when the state is closed.
+const (
+	stateOpen              = "open"
+	stateWaitingOnReporter = "waiting_on_reporter"
+	stateClosed            = "closed"
+)
+
+// setIssueState moves an issue to state using q, which may be a
+// transaction, and records the change in its history. Entering
+// waiting_on_reporter starts the auto-close clock; any other state stops it.
+func (a *App) setIssueState(q *gorm.DB, issue *models.Issue, state, actor string) error {
+	if issue.State == state {
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"state":           state,
+		"status":          state == stateClosed,
+		"waiting_since":   nil,
+		"stale_warned_at": nil,
+		"version":         gorm.Expr("version + 1"),
+	}
+	if state == stateWaitingOnReporter {
+		updates["waiting_since"] = time.Now()
+	}
+	// Leaving closed is tracked as a reopen so flappy issues stand out
+	eventType := eventStateChanged
+	from := issue.State
+	if from == stateClosed {
+		eventType = eventReopened
+		updates["reopen_count"] = gorm.Expr("reopen_count + 1")
+	}
+	if err := q.Model(issue).Updates(updates).Error; err != nil {
+		return err
+	}
+	issue.Version++
+	if eventType == eventReopened {
+		issue.ReopenCount++
+	}
+	if err := a.recordIssueEvent(q, issue.ID, eventType, actor, from, state); err != nil {
+		return err
+	}
+	return notifyStateChange(q, issue, state, actor)
+}
+
+// issueUpdate is a partial issue update; nil fields are left unchanged.
+// Fields that are set follow the same rules as on create.
+type issueUpdate struct {
+	Title     *string `json:"title" validate:"omitnil,notblank,max=255"`
+	Details   *string `json:"details" validate:"omitnil,max=255"`
+	Priority  *int    `json:"priority" validate:"omitnil,min=0"`
+	State     *string `json:"state" validate:"omitempty,oneof=open waiting_on_reporter closed"`
+	Assignee  *string `json:"assignee"`
+	Component *string `json:"component"`
+	// ProjectID moves the issue to another project, renumbering it; 0
+	// takes it out of its project.
+	ProjectID *uint `json:"projectId"`
+	// DueAt sets the due date as RFC 3339; an empty string clears it.
+	DueAt *string `json:"dueAt" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// Version, if set, must be the issue's current version; the update is
+	// refused otherwise so it cannot overwrite a change the client missed.
+	Version *int `json:"version"`
+}
+
+var (
+	errIssueNotFound = newServiceError(http.StatusNotFound, "Issue not found")
+	errIssueChanged  = newServiceError(http.StatusConflict, "Issue was changed by someone else; reload it and try again")
+	errBadIfMatch    = newServiceError(http.StatusBadRequest, "If-Match must be an issue ETag")
+)
+
+// issueETag is the entity tag for an issue's current version.
+func issueETag(issue models.Issue) string {
+	return `"` + strconv.Itoa(issue.Version) + `"`
+}
+
+// ifMatchVersion returns the issue version an If-Match header requires, or
+// nil if there is none. "*" matches any version.
+func ifMatchVersion(r *http.Request) (*int, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return nil, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(tag, `"`))
+	if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return nil, errBadIfMatch
+	}
+	return &version, nil
+}
+
+// The functions below are the issue service shared by the REST handlers and
+// the gRPC server.
+
+// findIssue loads an issue by ID.
+func (a *App) findIssue(ctx context.Context, id uint) (models.Issue, error) {
+	issue, err := a.repo.FindIssue(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return issue, errIssueNotFound
+	}
+	return issue, err
+}
+
+// accessibleIssue loads an issue the user may see. Issues they cannot see
+// are reported as missing rather than forbidden.
+func (a *App) accessibleIssue(ctx context.Context, user *models.User, id uint) (models.Issue, error) {
+	issue, err := a.findIssue(ctx, id)
+	if err == nil && !canAccessIssue(user, issue) {
+		return models.Issue{}, errIssueNotFound
+	}
+	return issue, err
+}
+
+// issueFilter narrows ListIssues; BeforeID pages backwards through IDs.
+type issueFilter struct {
+	State      string
+	Assignee   string
+	ReportedBy string
+	Label      string
+	ProjectID  uint
+	// Overdue selects open issues past their due date.
+	Overdue  bool
+	BeforeID uint
+	Limit    int
+}
+
+// issueQuery selects the issues ListIssues returns using q. A zero Limit
+// selects them all.
+func issueQuery(q *gorm.DB, user *models.User, f issueFilter) *gorm.DB {
+	q = q.Model(&models.Issue{}).Order("id desc")
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+	q = visibleIssues(q, user)
+	if f.State != "" {
+		q = q.Where("state = ?", f.State)
+	}
+	if f.Assignee != "" {
+		q = q.Where("assignee = ?", f.Assignee)
+	}
+	if f.ReportedBy != "" {
+		q = q.Where("reported_by = ?", f.ReportedBy)
+	}
+	if f.Label != "" {
+		q = q.Where("id IN (SELECT issue_labels.issue_id FROM issue_labels JOIN labels ON labels.id = issue_labels.label_id WHERE lower(labels.name) = lower(?))", f.Label)
+	}
+	if f.ProjectID > 0 {
+		q = q.Where("project_id = ?", f.ProjectID)
+	}
+	if f.Overdue {
+		q = q.Where("status = ? AND due_at < ?", false, time.Now())
+	}
+	if f.BeforeID > 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	return q
+}
+
+// createIssue validates and stores a new issue with its first history
+// entry. user is nil for anonymous reports, which are only accepted while
+// public reporting is on.
+func (a *App) createIssue(ctx context.Context, user *models.User, issue models.Issue) (models.Issue, error) {
+	settings := a.currentSettings()
+	actor := "anonymous"
+	if user != nil {
+		actor = user.Username
+	} else if !settings.PublicReporting {
+		return issue, newServiceError(http.StatusUnauthorized, "Authentication required")
+	}
+	if err := validateInput(&issue); err != nil {
+		return issue, err
+	}
+
+	issue.Model, issue.OrganizationID = gorm.Model{}, 0
+	issue.Number, issue.Key = 0, ""
+	// Attachments are only set by uploading them
+	issue.ImageURL = ""
+	if issue.Priority == 0 {
+		issue.Priority = settings.DefaultPriority
+	}
+	issue.State = stateOpen
+	issue.WaitingSince, issue.StaleWarnedAt = nil, nil
+	issue.ReopenCount, issue.FixVersionID = 0, nil
+	if issue.Status {
+		issue.State = stateClosed
+	}
+
+	if err := a.repo.CreateIssue(ctx, &issue, actor); err != nil {
+		return issue, err
+	}
+
+	log.Printf("BugReport created: %+v", issue)
+	a.fireWebhooks(ctx, "issue.created", issue)
+	return issue, nil
+}
+
+// updateIssue applies a partial update to issue, recording each change in
+// its history. Only the fields set in u change.
+func (a *App) updateIssue(ctx context.Context, issue *models.Issue, u issueUpdate, actor string) error {
+	if err := validateInput(&u); err != nil {
+		return err
+	}
+	if u.Assignee != nil && *u.Assignee != "" {
+		// Only members of the organization can be assigned
+		var assignee models.User
+		err := a.organizationMembers(ctx).Where("users.username = ?", *u.Assignee).First(&assignee).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newServiceError(http.StatusBadRequest, "Unknown assignee")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var dueAt *time.Time
+	if u.DueAt != nil && *u.DueAt != "" {
+		t, err := time.Parse(time.RFC3339, *u.DueAt)
+		if err != nil {
+			return newServiceError(http.StatusBadRequest, "dueAt must be an RFC 3339 time")
+		}
+		dueAt = &t
+	}
+
+	return a.repo.UpdateIssue(ctx, issue, u, dueAt, actor)
+}
+
+// listIssuesHandler returns a page of the issues the user may see, newest
+// first; pass the last ID as before for the next page. It also serves
+// /projects/{project}/issues. With stream=ndjson or
+// stream=array every matching issue is streamed instead.
+func (a *App) listIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	query := r.URL.Query()
+	f := issueFilter{
+		State:      query.Get("state"),
+		Assignee:   query.Get("assignee"),
+		ReportedBy: query.Get("reportedBy"),
+		Label:      query.Get("label"),
+		Overdue:    query.Get("overdue") == "true",
+	}
+	before, _ := strconv.ParseUint(query.Get("before"), 10, 64)
+	f.BeforeID = uint(before)
+	project := query.Get("project")
+	if ref, ok := mux.Vars(r)["project"]; ok {
+		project = ref
+	}
+	if project != "" {
+		p, err := a.findProject(r.Context(), project)
+		if err != nil {
+			writeServiceError(w, err, "Error loading project")
+			return
+		}
+		f.ProjectID = p.ID
+	}
+
+	format, ok := streamFormat(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "stream must be ndjson or array")
+		return
+	}
+	if format != "" {
+		a.streamList(w, r, format, issueQuery(a.dbCtx(r.Context()), user, f), func() interface{} { return &models.Issue{} }, attachStreamedMentions)
+		return
+	}
+
+	f.Limit, _ = strconv.Atoi(query.Get("limit"))
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 50
+	}
+	issues, err := a.cachedIssueList(r.Context(), user, f)
+	if err != nil {
+		writeDBError(w, err, "Error loading issues")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, issues)
+}
+
+// updateIssueHandler applies a partial update to an issue. Only the fields
+// present in the body change.
+func (a *App) updateIssueHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	var body issueUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if body.Version == nil {
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeServiceError(w, err, "Failed to update issue")
+			return
+		}
+		body.Version = version
+	}
+	if err := a.updateIssue(r.Context(), &issue, body, actorName(r)); err != nil {
+		writeServiceError(w, err, "Failed to update issue")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", issueETag(issue))
+	a.encodeJSON(w, r, issue)
+}
+
+// reopenIssueHandler reopens a closed issue, for example one the auto-close
+// policy closed while the reporter was away.
+func (a *App) reopenIssueHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+	if issue.State != stateClosed {
+		writeError(w, http.StatusConflict, "Issue is not closed")
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	if err := a.setIssueState(tx, &issue, stateOpen, actorName(r)); err != nil {
+		tx.Rollback()
+		log.Println("Error reopening issue:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to reopen issue")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error reopening issue:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to reopen issue")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", issueETag(issue))
+	a.encodeJSON(w, r, issue)
+}
+
+// sameProject reports whether an issue in current is already where a
+// projectId of to would put it.
+func sameProject(current *uint, to uint) bool {
+	if current == nil {
+		return to == 0
+	}
+	return *current == to
+}
+
+// sameTime reports whether two optional times are both unset or equal.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// formatDueAt renders a due date for the issue history.
+func formatDueAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
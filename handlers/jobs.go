@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"form/models"
+	"form/storage"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Job statuses. Failed jobs are the dead-letter queue: they ran out of
+// attempts and stay put, with their last error, until an admin retries them.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+const (
+	defaultJobAttempts = 5
+	// jobTimeout bounds a single run of a job.
+	jobTimeout = 5 * time.Minute
+	// jobLockTimeout is how long a job may stay running before it is
+	// assumed abandoned by a crashed worker and queued again.
+	jobLockTimeout = 2 * jobTimeout
+)
+
+// jobHandler runs one job. Returning an error retries the job with backoff
+// until it runs out of attempts.
+type jobHandler func(a *App, ctx context.Context, job *models.Job) error
+
+var jobHandlers = map[string]jobHandler{}
+
+func registerJobHandler(kind string, h jobHandler) {
+	jobHandlers[kind] = h
+}
+
+// retryAfter asks for a job to run again later without it counting as a
+// failed attempt, for example when a rate limit is reached.
+type retryAfter time.Duration
+
+func (d retryAfter) Error() string {
+	return fmt.Sprintf("retry after %s", time.Duration(d))
+}
+
+// enqueueJob adds a job using q, which may be a transaction so the job is
+// only queued if the surrounding work commits.
+func enqueueJob(q *gorm.DB, kind string, payload interface{}, runAt time.Time) (models.Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job := models.Job{
+		Kind:        kind,
+		Payload:     string(b),
+		Status:      jobQueued,
+		MaxAttempts: defaultJobAttempts,
+		RunAt:       runAt,
+	}
+	return job, q.Create(&job).Error
+}
+
+// claimJob takes the next due job of one of the given kinds, or returns
+// nil if there is none.
+func (a *App) claimJob(ctx context.Context, kinds []string) (*models.Job, error) {
+	q := a.dbCtx(ctx)
+	lock := "FOR UPDATE SKIP LOCKED"
+	if storage.UsingSQLite(q) {
+		lock = ""
+	}
+	now := time.Now()
+	var jobs []models.Job
+	err := q.Raw(`UPDATE jobs SET status = ?, locked_at = ?, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM jobs WHERE status = ? AND kind IN (?) AND run_at <= ?
+			ORDER BY run_at, id LIMIT 1 `+lock+`
+		) RETURNING *`, jobRunning, now, jobQueued, kinds, now).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// finishJob records the outcome of a run: done, queued again with
+// exponential backoff, or failed once attempts are exhausted.
+func (a *App) finishJob(ctx context.Context, job *models.Job, runErr error) error {
+	now := time.Now()
+	updates := map[string]interface{}{"locked_at": nil}
+
+	var wait retryAfter
+	switch {
+	case runErr == nil:
+		updates["status"] = jobDone
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case errors.As(runErr, &wait):
+		updates["status"] = jobQueued
+		updates["run_at"] = now.Add(time.Duration(wait))
+		updates["attempts"] = gorm.Expr("attempts - 1")
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed after %d attempts, moving it to the dead-letter queue", job.ID, job.Kind, job.Attempts)
+		updates["status"] = jobFailed
+		updates["finished_at"] = now
+		updates["last_error"] = runErr.Error()
+	default:
+		backoff := time.Duration(1<<uint(job.Attempts)) * 30 * time.Second
+		updates["status"] = jobQueued
+		updates["run_at"] = now.Add(backoff)
+		updates["last_error"] = runErr.Error()
+	}
+	return a.dbCtx(ctx).Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error
+}
+
+// requeueStaleJobs returns jobs abandoned by crashed workers to the queue.
+func (a *App) requeueStaleJobs(ctx context.Context) error {
+	return a.dbCtx(ctx).Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", jobRunning, time.Now().Add(-jobLockTimeout)).
+		Updates(map[string]interface{}{"status": jobQueued, "locked_at": nil}).Error
+}
+
+// runJobWorkers runs a pool of n workers for every registered job kind
+// until ctx is cancelled.
+func (a *App) runJobWorkers(ctx context.Context, n int, interval time.Duration) {
+	// Jobs are queued from every organization; handlers load what they
+	// need by ID
+	ctx = allOrganizations(ctx)
+	kinds := make([]string, 0, len(jobHandlers))
+	for kind := range jobHandlers {
+		kinds = append(kinds, kind)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runJobWorker(ctx, kinds, interval)
+		}()
+	}
+	wg.Wait()
+}
+
+// runJobWorker processes jobs of the given kinds until ctx is cancelled,
+// checking for new work every interval while the queue is empty.
+func (a *App) runJobWorker(ctx context.Context, kinds []string, interval time.Duration) {
+	for {
+		job, err := a.claimJob(ctx, kinds)
+		if err != nil {
+			log.Println("Error claiming job:", err)
+		}
+		if job == nil {
+			if err := a.requeueStaleJobs(ctx); err != nil {
+				log.Println("Error requeueing stale jobs:", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		runErr := a.runJob(ctx, job)
+		if runErr != nil {
+			log.Printf("Job %d (%s) attempt %d failed: %s", job.ID, job.Kind, job.Attempts, runErr)
+		}
+		// Record the outcome even if shutdown has begun
+		if err := a.finishJob(context.Background(), job, runErr); err != nil {
+			log.Printf("Error finishing job %d: %s", job.ID, err)
+		}
+	}
+}
+
+func (a *App) runJob(ctx context.Context, job *models.Job) (err error) {
+	h, ok := jobHandlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+
+	ctx, span := tracer.Start(ctx, "job."+job.Kind)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(a, ctx, job)
+}
+
+func (a *App) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	q := a.dbCtx(r.Context()).Order("id desc")
+	if status := r.URL.Query().Get("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var jobs []models.Job
+	if err := q.Limit(limit).Find(&jobs).Error; err != nil {
+		writeDBError(w, err, "Error loading jobs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, jobs)
+}
+
+// jobCount is one row of the queue summary.
+type jobCount struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// jobSummaryHandler counts jobs by kind and status, for spotting a growing
+// backlog or dead-letter queue.
+func (a *App) jobSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	counts := []jobCount{}
+	err := a.dbCtx(r.Context()).Model(&models.Job{}).Select("kind, status, count(*) AS count").Group("kind, status").Order("kind, status").Scan(&counts).Error
+	if err != nil {
+		writeDBError(w, err, "Error loading jobs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, counts)
+}
+
+func (a *App) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	var job models.Job
+	if err := a.dbCtx(r.Context()).First(&job, mux.Vars(r)["id"]).Error; err != nil {
+		writeDBError(w, err, "Error loading job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, job)
+}
+
+// retryJobHandler puts a dead-lettered job back in the queue with a fresh
+// set of attempts. Its last error is kept until the next run replaces it.
+func (a *App) retryJobHandler(w http.ResponseWriter, r *http.Request) {
+	var job models.Job
+	if err := a.dbCtx(r.Context()).First(&job, mux.Vars(r)["id"]).Error; err != nil {
+		writeDBError(w, err, "Error loading job")
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	result := tx.Model(&job).Where("status = ?", jobFailed).Updates(map[string]interface{}{
+		"status":      jobQueued,
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+	})
+	err := result.Error
+	if err == nil && result.RowsAffected == 0 {
+		tx.Rollback()
+		writeError(w, http.StatusConflict, "Only failed jobs can be retried")
+		return
+	}
+	if err == nil {
+		err = recordAudit(tx, actorName(r), "job.retried", "job", job.ID, map[string]interface{}{"kind": job.Kind, "lastError": job.LastError})
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to retry job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, job)
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// labelMergeBatchSize bounds how many issues are re-tagged per transaction
+// when merging labels, so large merges do not hold locks for long.
+const labelMergeBatchSize = 500
+
+// issueLabelNames returns the sorted names of the labels on an issue.
+func issueLabelNames(q *gorm.DB, issueID uint) ([]string, error) {
+	var names []string
+	err := q.Table("labels").Joins("JOIN issue_labels ON issue_labels.label_id = labels.id").
+		Where("issue_labels.issue_id = ?", issueID).Order("labels.name").Pluck("labels.name", &names).Error
+	return names, err
+}
+
+// findOrCreateLabel returns the label with the given name, creating it if
+// needed.
+func findOrCreateLabel(q *gorm.DB, name string) (models.Label, error) {
+	var label models.Label
+	err := q.Where("lower(name) = lower(?)", name).First(&label).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		label = models.Label{Name: name}
+		err = q.Create(&label).Error
+	}
+	return label, err
+}
+
+func (a *App) listLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	var labels []struct {
+		models.Label
+		Issues int `json:"issues"`
+	}
+	if err := a.dbCtx(r.Context()).Table("labels").
+		Select("labels.*, count(issue_labels.issue_id) AS issues").
+		Joins("LEFT JOIN issue_labels ON issue_labels.label_id = labels.id").
+		Group("labels.id").Order("labels.name").Scan(&labels).Error; err != nil {
+		log.Println("Error loading labels:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading labels")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, labels)
+}
+
+// setIssueLabelsHandler replaces the labels on an issue, creating any
+// labels that do not exist yet.
+func (a *App) setIssueLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Labels []string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	before, err := issueLabelNames(tx, issue.ID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("Error loading issue labels:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+	if err := tx.Where("issue_id = ?", issue.ID).Delete(&models.IssueLabel{}).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error updating issue labels:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+	seen := map[uint]bool{}
+	for _, name := range body.Labels {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		label, err := findOrCreateLabel(tx, name)
+		if err != nil {
+			tx.Rollback()
+			log.Println("Error creating label:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to update labels")
+			return
+		}
+		if seen[label.ID] {
+			continue
+		}
+		seen[label.ID] = true
+		if err := tx.Create(&models.IssueLabel{IssueID: issue.ID, LabelID: label.ID}).Error; err != nil {
+			tx.Rollback()
+			log.Println("Error updating issue labels:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to update labels")
+			return
+		}
+	}
+	after, err := issueLabelNames(tx, issue.ID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("Error loading issue labels:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+	from, to := strings.Join(before, ", "), strings.Join(after, ", ")
+	if from != to {
+		if err := a.recordIssueEvent(tx, issue.ID, eventLabelsChanged, actorName(r), from, to); err != nil {
+			tx.Rollback()
+			log.Println("Error recording issue event:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to update labels")
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error updating issue labels:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, after)
+}
+
+// renameLabelHandler renames a label on every issue that carries it.
+// Renaming onto an existing label's name is refused; merge them instead.
+func (a *App) renameLabelHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Label name is required")
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	var label models.Label
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&label, mux.Vars(r)["id"]).Error; err != nil {
+		tx.Rollback()
+		writeError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	var clashes int64
+	if err := tx.Model(&models.Label{}).Where("lower(name) = lower(?) AND id <> ?", body.Name, label.ID).Count(&clashes).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error renaming label:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to rename label")
+		return
+	}
+	if clashes > 0 {
+		tx.Rollback()
+		writeError(w, http.StatusConflict, "A label with that name already exists; merge the labels instead")
+		return
+	}
+
+	var touched int64
+	if err := tx.Model(&models.IssueLabel{}).Where("label_id = ?", label.ID).Count(&touched).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error renaming label:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to rename label")
+		return
+	}
+	oldName := label.Name
+	if err := tx.Model(&label).Update("name", body.Name).Error; err != nil {
+		tx.Rollback()
+		writeDBError(w, err, "Failed to rename label")
+		return
+	}
+	details := map[string]interface{}{"from": oldName, "to": body.Name, "issuesTouched": touched}
+	if err := recordAudit(tx, actorName(r), "label.renamed", "label", label.ID, details); err != nil {
+		tx.Rollback()
+		log.Println("Error recording audit entry:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to rename label")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error renaming label:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to rename label")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"label": label, "issuesTouched": touched})
+}
+
+// mergeLabelHandler moves every issue from the label in the URL onto the
+// target label, in batches of labelMergeBatchSize issues per transaction,
+// then deletes the source label. An interrupted merge can be retried.
+func (a *App) mergeLabelHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Into uint `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var source, target models.Label
+	if err := a.dbCtx(r.Context()).First(&source, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	if err := a.dbCtx(r.Context()).First(&target, body.Into).Error; err != nil {
+		writeError(w, http.StatusBadRequest, "Target label not found")
+		return
+	}
+	if source.ID == target.ID {
+		writeError(w, http.StatusBadRequest, "Cannot merge a label into itself")
+		return
+	}
+
+	actor := actorName(r)
+	touched, batches := 0, 0
+	for {
+		done, n, err := a.mergeLabelBatch(a.dbCtx(r.Context()), source, target, actor, touched)
+		if err != nil {
+			log.Println("Error merging labels:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to merge labels")
+			return
+		}
+		touched += n
+		if n > 0 {
+			batches++
+		}
+		if done {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"source":        source,
+		"target":        target,
+		"issuesTouched": touched,
+		"batches":       batches,
+	})
+}
+
+// mergeLabelBatch re-tags one batch of issues from source to target. Once
+// no issues remain it deletes the source label and reports done. The source
+// label row is locked so no issue can be tagged with it mid-batch.
+func (a *App) mergeLabelBatch(q *gorm.DB, source, target models.Label, actor string, touchedSoFar int) (bool, int, error) {
+	tx := q.Begin()
+
+	var locked models.Label
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, source.ID).Error; err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+
+	var ids []uint
+	if err := tx.Model(&models.IssueLabel{}).Where("label_id = ?", source.ID).
+		Order("issue_id").Limit(labelMergeBatchSize).Pluck("issue_id", &ids).Error; err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+
+	if len(ids) == 0 {
+		if err := tx.Delete(&locked).Error; err != nil {
+			tx.Rollback()
+			return false, 0, err
+		}
+		details := map[string]interface{}{"from": source.Name, "into": target.Name, "intoId": target.ID, "issuesTouched": touchedSoFar}
+		if err := recordAudit(tx, actor, "label.merged", "label", source.ID, details); err != nil {
+			tx.Rollback()
+			return false, 0, err
+		}
+		return true, 0, tx.Commit().Error
+	}
+
+	if err := tx.Exec(`INSERT INTO issue_labels (issue_id, label_id)
+		SELECT issue_id, ? FROM issue_labels WHERE label_id = ? AND issue_id IN (?)
+		ON CONFLICT DO NOTHING`, target.ID, source.ID, ids).Error; err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+	if err := tx.Where("label_id = ? AND issue_id IN (?)", source.ID, ids).Delete(&models.IssueLabel{}).Error; err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+	for _, id := range ids {
+		if err := a.recordIssueEvent(tx, id, eventLabelsMerged, actor, source.Name, target.Name); err != nil {
+			tx.Rollback()
+			return false, 0, err
+		}
+	}
+	return false, len(ids), tx.Commit().Error
+}
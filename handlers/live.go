@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"form/config"
+	"form/models"
+	"form/storage"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// Live event types streamed from /events.
+const (
+	liveIssueCreated = "issue.created"
+	liveIssueUpdated = "issue.updated"
+	liveCommentAdded = "comment.added"
+	// liveIssueArchived only tells other instances to drop their cached
+	// copy; the issue is gone, so subscribers are not sent anything.
+	liveIssueArchived = "issue.archived"
+)
+
+// liveChannel is the Postgres NOTIFY channel live events travel on, so every
+// instance sees changes made through any other.
+const liveChannel = "form_live_events"
+
+const (
+	liveHeartbeat  = 25 * time.Second
+	liveBufferSize = 16
+
+	liveMinReconnect = time.Second
+	liveMaxReconnect = time.Minute
+)
+
+// liveNotice is the NOTIFY payload. It only carries IDs because payloads are
+// limited to 8000 bytes; each instance loads the rows itself.
+type liveNotice struct {
+	Type      string `json:"type"`
+	IssueID   uint   `json:"issueId"`
+	CommentID uint   `json:"commentId,omitempty"`
+}
+
+// liveEvent is what subscribers receive.
+type liveEvent struct {
+	Type    string          `json:"type"`
+	Issue   models.Issue    `json:"issue"`
+	Comment *models.Comment `json:"comment,omitempty"`
+}
+
+// notifyLive queues a live event using q, which may be a transaction.
+// Postgres delivers it only if the transaction commits, and collapses
+// identical notices sent within one transaction. Every change to an issue
+// goes through here, so it also invalidates the issue's cached copy.
+func (a *App) notifyLive(q *gorm.DB, eventType string, issueID, commentID uint) error {
+	a.invalidateIssue(context.Background(), issueID)
+	payload, _ := json.Marshal(liveNotice{Type: eventType, IssueID: issueID, CommentID: commentID})
+	if storage.UsingSQLite(q) {
+		select {
+		case a.sqliteNotices <- string(payload):
+		default:
+			log.Println("Live event queue full, dropping", eventType)
+		}
+		return nil
+	}
+	return q.Exec("SELECT pg_notify(?, ?)", liveChannel, string(payload)).Error
+}
+
+type liveSubscriber struct {
+	user         *models.User
+	organization uint
+	ch           chan liveEvent
+}
+
+// liveHub fans events out to the subscribers connected to this instance.
+type liveHub struct {
+	sync.Mutex
+	subs map[*liveSubscriber]struct{}
+}
+
+func (a *App) subscribeLive(user *models.User, organization uint) *liveSubscriber {
+	sub := &liveSubscriber{user: user, organization: organization, ch: make(chan liveEvent, liveBufferSize)}
+	a.live.Lock()
+	a.live.subs[sub] = struct{}{}
+	a.live.Unlock()
+	return sub
+}
+
+func (a *App) unsubscribeLive(sub *liveSubscriber) {
+	a.live.Lock()
+	if _, ok := a.live.subs[sub]; ok {
+		delete(a.live.subs, sub)
+		close(sub.ch)
+	}
+	a.live.Unlock()
+}
+
+// publishLive sends an event to every subscriber in the issue's
+// organization allowed to see it.
+// Subscribers too slow to keep up are disconnected so they resync rather
+// than silently miss events.
+func (a *App) publishLive(evt liveEvent) {
+	a.live.Lock()
+	defer a.live.Unlock()
+	for sub := range a.live.subs {
+		if sub.organization != evt.Issue.OrganizationID || !canAccessIssue(sub.user, evt.Issue) {
+			continue
+		}
+		select {
+		case sub.ch <- a.visibleTo(viewerRole(sub.user), evt).(liveEvent):
+		default:
+			delete(a.live.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// listenLive relays NOTIFY messages from Postgres to local subscribers until
+// ctx is done, reconnecting with backoff when the connection drops.
+func (a *App) listenLive(ctx context.Context) {
+	// Notices come from every organization; publishLive sorts them out
+	ctx = allOrganizations(ctx)
+	if storage.UsingSQLite(a.db) {
+		a.relaySQLiteLive(ctx)
+		return
+	}
+	backoff := liveMinReconnect
+	for {
+		started := time.Now()
+		err := a.relayLive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// Anything sent while reconnecting is lost
+		log.Println("Live event listener:", err)
+		if time.Since(started) > liveMaxReconnect {
+			backoff = liveMinReconnect
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > liveMaxReconnect {
+			backoff = liveMaxReconnect
+		}
+	}
+}
+
+// relayLive listens on a dedicated connection until it fails or ctx is
+// done.
+func (a *App) relayLive(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, a.cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+liveChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		evt, err := a.loadLiveEvent(ctx, n.Payload)
+		if err != nil {
+			log.Println("Error loading live event:", err)
+			continue
+		}
+		if evt.Type != "" {
+			a.publishLive(evt)
+		}
+	}
+}
+
+// relaySQLiteLive publishes the notices sent on this process until ctx is
+// done.
+func (a *App) relaySQLiteLive(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-a.sqliteNotices:
+			// SQLite transactions here take the write lock when they begin,
+			// so beginning one waits for the sender's to finish
+			if tx := a.dbCtx(ctx).Begin(); tx.Error == nil {
+				tx.Rollback()
+			}
+			evt, err := a.loadLiveEvent(ctx, payload)
+			if err != nil {
+				log.Println("Error loading live event:", err)
+				continue
+			}
+			if evt.Type != "" {
+				a.publishLive(evt)
+			}
+		}
+	}
+}
+
+func (a *App) loadLiveEvent(ctx context.Context, payload string) (liveEvent, error) {
+	var notice liveNotice
+	if err := json.Unmarshal([]byte(payload), &notice); err != nil {
+		return liveEvent{}, err
+	}
+	a.invalidateIssue(ctx, notice.IssueID)
+	if notice.Type == liveIssueArchived {
+		return liveEvent{}, nil
+	}
+	issue, err := a.findIssue(ctx, notice.IssueID)
+	if err != nil {
+		return liveEvent{}, err
+	}
+	evt := liveEvent{Type: notice.Type, Issue: issue}
+	if notice.CommentID != 0 {
+		var comment models.Comment
+		if err := a.dbCtx(ctx).First(&comment, notice.CommentID).Error; err != nil {
+			return liveEvent{}, err
+		}
+		evt.Comment = &comment
+	}
+	return evt, nil
+}
+
+// eventsHandler streams live issue events as server-sent events. Browsers'
+// EventSource cannot set headers, so the token and organization may also be
+// passed as access_token and organization.
+func (a *App) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, err := currentUser(r)
+	if raw := r.URL.Query().Get("access_token"); err != nil && raw != "" {
+		user, err = a.tokenUser(ctx, raw)
+		if err == nil {
+			slug := r.Header.Get(config.OrganizationHeader)
+			if slug == "" {
+				slug = r.URL.Query().Get("organization")
+			}
+			if ctx, user, err = a.resolveOrganization(ctx, user, slug); err != nil {
+				writeServiceError(w, err, "Error resolving organization")
+				return
+			}
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	// The stream outlives any write deadline set for ordinary requests
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Println("Error starting event stream:", err)
+		return
+	}
+
+	sub := a.subscribeLive(user, contextOrganization(ctx))
+	defer a.unsubscribeLive(sub)
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.ctx.Done():
+			return
+		case evt, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(evt)
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"form/models"
+
+	"gorm.io/gorm"
+)
+
+// mentionPattern matches @username in text, not in email addresses.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w.-]+)`)
+
+// mentionedUsernames returns the distinct usernames text mentions.
+func mentionedUsernames(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Sentence punctuation after a mention is not part of the name
+		name := strings.TrimRight(m[1], ".-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mentionScope selects the mentions made in an issue's details, or in the
+// comment if commentID is set.
+func mentionScope(q *gorm.DB, issueID uint, commentID *uint) *gorm.DB {
+	q = q.Model(&models.Mention{}).Where("issue_id = ?", issueID)
+	if commentID == nil {
+		return q.Where("comment_id IS NULL")
+	}
+	return q.Where("comment_id = ?", *commentID)
+}
+
+// recordMentions brings the mentions stored for text, the details of issue
+// or the comment commentID, in line with it using q, which may be a
+// transaction. Only members of the issue's organization count as
+// mentioned; anything else after an @ is left as plain text. Users
+// newly mentioned are notified if they can see the issue, so editing text
+// does not notify them again. It returns the usernames text now mentions.
+func recordMentions(q *gorm.DB, issue *models.Issue, commentID *uint, actor, text string) ([]string, error) {
+	mentioned := []string{}
+	members := map[string]*models.User{}
+	if names := mentionedUsernames(text); len(names) > 0 {
+		var rows []struct {
+			Username       string
+			Role           string
+			MembershipRole string
+		}
+		if err := q.Model(&models.User{}).Joins("JOIN memberships ON memberships.user_id = users.id").
+			Select("users.username, users.role, memberships.role AS membership_role").
+			Where("memberships.organization_id = ? AND users.username IN (?)", issue.OrganizationID, names).
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			user := &models.User{Username: row.Username, Role: row.Role, MembershipRole: row.MembershipRole}
+			// Operators count as admins of every organization
+			if user.Role == "admin" {
+				user.MembershipRole = orgRoleAdmin
+			}
+			members[row.Username] = user
+			mentioned = append(mentioned, row.Username)
+		}
+		sort.Strings(mentioned)
+	}
+
+	var existing []string
+	if err := mentionScope(q, issue.ID, commentID).Pluck("username", &existing).Error; err != nil {
+		return nil, err
+	}
+	stale := mentionScope(q, issue.ID, commentID)
+	if len(mentioned) > 0 {
+		stale = stale.Where("username NOT IN (?)", mentioned)
+	}
+	if err := stale.Delete(&models.Mention{}).Error; err != nil {
+		return nil, err
+	}
+
+	var added []models.Mention
+	var names []string
+	for _, name := range mentioned {
+		if containsString(existing, name) {
+			continue
+		}
+		added = append(added, models.Mention{OrganizationID: issue.OrganizationID, IssueID: issue.ID, CommentID: commentID, Username: name})
+		// A mention does not let anyone see the issue; those who cannot are
+		// not sent its title or a link they would find missing
+		if canAccessIssue(members[name], *issue) {
+			names = append(names, name)
+		}
+	}
+	if len(added) == 0 {
+		return mentioned, nil
+	}
+	if err := q.Create(&added).Error; err != nil {
+		return nil, err
+	}
+	n := issueNotification(issue, notificationMentioned, actor)
+	n.CommentID = commentID
+	return mentioned, notify(q, names, n)
+}
+
+// attachIssueMentions fills in who each issue's details mention.
+func attachIssueMentions(q *gorm.DB, issues []models.Issue) error {
+	ptrs := make([]*models.Issue, len(issues))
+	for i := range issues {
+		ptrs[i] = &issues[i]
+	}
+	return fillIssueMentions(q, ptrs)
+}
+
+// attachStreamedMentions is attachIssueMentions for a batch of streamed
+// issues.
+func attachStreamedMentions(q *gorm.DB, batch []interface{}) error {
+	ptrs := make([]*models.Issue, len(batch))
+	for i, row := range batch {
+		ptrs[i] = row.(*models.Issue)
+	}
+	return fillIssueMentions(q, ptrs)
+}
+
+func fillIssueMentions(q *gorm.DB, issues []*models.Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(issues))
+	for i, issue := range issues {
+		ids[i] = issue.ID
+	}
+	var mentions []models.Mention
+	if err := q.Where("issue_id IN (?) AND comment_id IS NULL", ids).Order("username").Find(&mentions).Error; err != nil {
+		return err
+	}
+	byIssue := map[uint][]string{}
+	for _, m := range mentions {
+		byIssue[m.IssueID] = append(byIssue[m.IssueID], m.Username)
+	}
+	for _, issue := range issues {
+		issue.Mentions = byIssue[issue.ID]
+		if issue.Mentions == nil {
+			issue.Mentions = []string{}
+		}
+	}
+	return nil
+}
+
+// attachCommentMentions fills in who each comment mentions.
+func attachCommentMentions(q *gorm.DB, comments []models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	var mentions []models.Mention
+	if err := q.Where("comment_id IN (?)", ids).Order("username").Find(&mentions).Error; err != nil {
+		return err
+	}
+	byComment := map[uint][]string{}
+	for _, m := range mentions {
+		byComment[*m.CommentID] = append(byComment[*m.CommentID], m.Username)
+	}
+	for i := range comments {
+		comments[i].Mentions = byComment[comments[i].ID]
+		if comments[i].Mentions == nil {
+			comments[i].Mentions = []string{}
+		}
+	}
+	return nil
+}
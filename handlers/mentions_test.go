@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"form/models"
+)
+
+func TestMentionNotificationsRespectVisibility(t *testing.T) {
+	a := newTestApp(t)
+	ctx := withOrganization(context.Background(), models.DefaultOrganizationID)
+
+	roles := map[string]string{"ana": orgRoleMember, "bo": orgRoleMember, "cy": orgRoleMember, "dee": orgRoleAdmin}
+	for name, role := range roles {
+		u := models.User{Username: name, Password: "password1"}
+		if err := a.repo.CreateUser(ctx, &u, models.DefaultOrganizationID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bo can see the issue as its assignee and dee as an admin; cy is only
+	// mentioned
+	issue := models.Issue{Title: "Login broken", Details: "cc @bo @cy @dee", ReportedBy: "ana", Assignee: "bo", State: stateOpen}
+	if err := a.repo.CreateIssue(ctx, &issue, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(issue.Mentions, ","); got != "bo,cy,dee" {
+		t.Errorf("Mentions = %s, want bo,cy,dee", got)
+	}
+
+	var recipients []string
+	if err := a.dbCtx(ctx).Model(&models.Notification{}).Where("kind = ?", notificationMentioned).
+		Pluck("recipient", &recipients).Error; err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(recipients)
+	if got := strings.Join(recipients, ","); got != "bo,dee" {
+		t.Errorf("mention notifications went to %s, want bo,dee", got)
+	}
+}
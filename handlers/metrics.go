@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"form/models"
+)
+
+// reopenGroupings are the issue columns reopen metrics can be broken down
+// by.
+var reopenGroupings = map[string]string{
+	"assignee":  "issues.assignee",
+	"component": "issues.component",
+}
+
+type reopenStat struct {
+	Key            string  `json:"key"`
+	Closed         int     `json:"closed"`
+	ReopenedIssues int     `json:"reopenedIssues"`
+	Reopens        int     `json:"reopens"`
+	ReopenRate     float64 `json:"reopenRate"`
+}
+
+type flappyIssue struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Assignee    string `json:"assignee"`
+	Component   string `json:"component"`
+	State       string `json:"state"`
+	ReopenCount int    `json:"reopenCount"`
+}
+
+// reopenMetricsHandler reports, per assignee or component, how many issues
+// were closed in the window and how many of those came back. The reopen
+// rate is reopened issues over closed issues. Issues are grouped by their
+// current assignee or component.
+func (a *App) reopenMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "assignee"
+	}
+	column, ok := reopenGroupings[by]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "by must be assignee or component")
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	var stats []reopenStat
+	query := fmt.Sprintf(`SELECT COALESCE(NULLIF(%[1]s, ''), '(none)') AS key,
+			count(DISTINCT CASE WHEN issue_events.type = ? AND issue_events.to_value = ? THEN issue_events.issue_id END) AS closed,
+			count(DISTINCT CASE WHEN issue_events.type = ? THEN issue_events.issue_id END) AS reopened_issues,
+			count(CASE WHEN issue_events.type = ? THEN 1 END) AS reopens
+		FROM issue_events JOIN issues ON issues.id = issue_events.issue_id
+		WHERE issues.organization_id = ? AND issue_events.created_at >= ? AND issue_events.type IN (?, ?) AND issues.deleted_at IS NULL
+		GROUP BY 1 ORDER BY reopens DESC, key`, column)
+	if err := a.dbCtx(r.Context()).Raw(query,
+		eventStateChanged, stateClosed, eventReopened, eventReopened,
+		contextOrganization(r.Context()), since, eventStateChanged, eventReopened).Scan(&stats).Error; err != nil {
+		log.Println("Error computing reopen metrics:", err)
+		writeError(w, http.StatusInternalServerError, "Error computing reopen metrics")
+		return
+	}
+	for i := range stats {
+		if stats[i].Closed > 0 {
+			stats[i].ReopenRate = float64(stats[i].ReopenedIssues) / float64(stats[i].Closed)
+		}
+	}
+
+	// Issues that bounced back more than once are the likeliest bad fixes
+	var flappy []flappyIssue
+	if err := a.dbCtx(r.Context()).Model(&models.Issue{}).Where("reopen_count >= ?", 2).
+		Order("reopen_count desc, id").Limit(dashboardListSize).Scan(&flappy).Error; err != nil {
+		log.Println("Error computing reopen metrics:", err)
+		writeError(w, http.StatusInternalServerError, "Error computing reopen metrics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"by":        by,
+		"since":     since,
+		"groups":    stats,
+		"flappiest": flappy,
+	})
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Notification kinds.
+const (
+	notificationAssigned       = "assigned"
+	notificationMentioned      = "mentioned"
+	notificationStateChanged   = "state_changed"
+	notificationImportFinished = "import_finished"
+)
+
+// stateNames are the issue states as notifications name them.
+var stateNames = map[string]string{
+	stateOpen:              "Open",
+	stateWaitingOnReporter: "Waiting on reporter",
+	stateClosed:            "Closed",
+}
+
+// notificationMessage describes n in locale.
+func notificationMessage(locale string, n models.Notification) string {
+	switch n.Kind {
+	case notificationAssigned:
+		return fmt.Sprintf(translate(locale, "%s assigned you %s"), n.Actor, n.Title)
+	case notificationMentioned:
+		return fmt.Sprintf(translate(locale, "%s mentioned you on %s"), n.Actor, n.Title)
+	case notificationStateChanged:
+		return fmt.Sprintf(translate(locale, "%s moved %s to %s"), n.Actor, n.Title, translate(locale, stateNames[n.Detail]))
+	case notificationImportFinished:
+		if n.Detail == importCompleted {
+			return translate(locale, "Your contact import completed")
+		}
+		return translate(locale, "Your contact import failed")
+	}
+	return ""
+}
+
+// describeNotifications fills in each notification's message in the
+// language of user, their recipient.
+func (a *App) describeNotifications(ctx context.Context, user *models.User, notifications []models.Notification) {
+	locale := recipientLocale(a.dbCtx(ctx), *user, contextOrganization(ctx))
+	for i := range notifications {
+		notifications[i].Message = notificationMessage(locale, notifications[i])
+	}
+}
+
+// notify sends n to each recipient who is a member of n's organization,
+// using q, which may be a transaction. The actor is never told about their
+// own change.
+func notify(q *gorm.DB, recipients []string, n models.Notification) error {
+	var wanted []string
+	seen := map[string]bool{}
+	for _, name := range recipients {
+		if name != "" && name != n.Actor && !seen[name] {
+			seen[name] = true
+			wanted = append(wanted, name)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+	var members []string
+	if err := q.Model(&models.User{}).Joins("JOIN memberships ON memberships.user_id = users.id").
+		Where("memberships.organization_id = ? AND users.username IN (?)", n.OrganizationID, wanted).
+		Pluck("users.username", &members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	batch := make([]models.Notification, len(members))
+	for i, name := range members {
+		batch[i] = n
+		batch[i].Recipient = name
+	}
+	return q.Create(&batch).Error
+}
+
+// issueNotification starts a notification about issue.
+func issueNotification(issue *models.Issue, kind, actor string) models.Notification {
+	id := issue.ID
+	return models.Notification{OrganizationID: issue.OrganizationID, Kind: kind, Actor: actor, IssueID: &id, Title: issue.Title}
+}
+
+// notifyStateChange tells an issue's reporter and assignee it moved to
+// state.
+func notifyStateChange(q *gorm.DB, issue *models.Issue, state, actor string) error {
+	n := issueNotification(issue, notificationStateChanged, actor)
+	n.Detail = state
+	return notify(q, []string{issue.ReportedBy, issue.Assignee}, n)
+}
+
+// notifyImportFinished tells whoever started a CSV import how it ended.
+func notifyImportFinished(q *gorm.DB, job *models.ImportJob) error {
+	if job.Source != "csv_file" || job.Status == importAborted {
+		return nil
+	}
+	id := job.ID
+	return notify(q, []string{job.RequestedBy}, models.Notification{
+		OrganizationID: job.OrganizationID, Kind: notificationImportFinished, Actor: systemActor, ImportJobID: &id, Detail: job.Status,
+	})
+}
+
+// userNotifications selects the current organization's notifications for
+// user.
+func (a *App) userNotifications(ctx context.Context, user *models.User) *gorm.DB {
+	return a.dbCtx(ctx).Model(&models.Notification{}).Where("recipient = ?", user.Username)
+}
+
+// listNotificationsHandler pages backwards through the user's
+// notifications, optionally only unread ones.
+func (a *App) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	q := a.userNotifications(r.Context(), user).Order("id desc").Limit(limit)
+	if query.Get("unread") == "true" {
+		q = q.Where("read_at IS NULL")
+	}
+	if before, _ := strconv.ParseUint(query.Get("before"), 10, 64); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+
+	notifications := []models.Notification{}
+	if err := q.Find(&notifications).Error; err != nil {
+		writeDBError(w, err, "Error loading notifications")
+		return
+	}
+	a.describeNotifications(r.Context(), user, notifications)
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, notifications)
+}
+
+func (a *App) unreadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var unread int64
+	if err := a.userNotifications(r.Context(), user).Where("read_at IS NULL").Count(&unread).Error; err != nil {
+		writeDBError(w, err, "Error loading notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]int64{"unread": unread})
+}
+
+func (a *App) readNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+
+	var n models.Notification
+	err := a.userNotifications(r.Context(), user).First(&n, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err, "Error loading notifications")
+		return
+	}
+	if n.ReadAt == nil {
+		now := time.Now()
+		if err := a.dbCtx(r.Context()).Model(&n).Update("read_at", now).Error; err != nil {
+			writeDBError(w, err, "Failed to update notification")
+			return
+		}
+		n.ReadAt = &now
+	}
+	batch := []models.Notification{n}
+	a.describeNotifications(r.Context(), user, batch)
+	n = batch[0]
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, n)
+}
+
+func (a *App) readAllNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	result := a.userNotifications(r.Context(), user).Where("read_at IS NULL").Update("read_at", time.Now())
+	if result.Error != nil {
+		writeDBError(w, result.Error, "Failed to update notification")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]int64{"read": result.RowsAffected})
+}
@@ -0,0 +1,532 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Access levels for documented operations.
+const (
+	authNone  = ""
+	authUser  = "user"
+	authAdmin = "admin"
+	// authOperator is deployment-wide administration, beyond one
+	// organization.
+	authOperator = "operator"
+)
+
+// apiDoc describes one operation for the OpenAPI spec. Schemas are
+// generated from the request and response values' types, using their json
+// and validate tags.
+type apiDoc struct {
+	summary string
+	tag     string
+	auth    string
+	query   []string
+	request interface{}
+	// fileField names the multipart file field for upload endpoints.
+	fileField string
+	status    int
+	response  interface{}
+	// contentType overrides application/json for non-JSON responses.
+	contentType string
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type reportIssueResponse struct {
+	Message string `json:"message"`
+	ID      uint   `json:"id"`
+}
+
+type loginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type loginResponse struct {
+	Message string  `json:"message"`
+	User    profile `json:"user"`
+	Token   string  `json:"token"`
+}
+
+type tokenResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+type emailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type suppressionReport struct {
+	Total    int `json:"total"`
+	ByReason []struct {
+		Reason string `json:"reason"`
+		Count  int    `json:"count"`
+	} `json:"byReason"`
+	Contacts []models.Contact `json:"contacts"`
+}
+
+type labelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type commentRequest struct {
+	Body string `json:"body" validate:"required"`
+}
+
+// apiDocs documents each route, keyed by method and mux path template.
+// Routes missing here still appear in the spec, and are logged at startup.
+var apiDocs = map[string]apiDoc{
+	"GET /healthz": {summary: "Liveness probe", tag: "ops"},
+	"GET /readyz":  {summary: "Readiness probe", tag: "ops"},
+
+	"POST /register":           {summary: "Create an account", tag: "auth", request: models.User{}, status: http.StatusCreated, response: messageResponse{}},
+	"POST /login":              {summary: "Log in and get a bearer token", tag: "auth", request: loginRequest{}, response: loginResponse{}},
+	"POST /login-by-email":     {summary: "Check that an email is a known contact", tag: "auth", request: emailRequest{}},
+	"GET /me":                  {summary: "Get your profile", tag: "auth", auth: authUser, response: profile{}},
+	"PUT /me":                  {summary: "Update your display name, email and language", tag: "auth", auth: authUser, request: profileUpdate{}, response: profile{}},
+	"POST /me/change-password": {summary: "Change your password and get a new token", tag: "auth", auth: authUser, request: passwordChangeRequest{}, response: tokenResponse{}},
+
+	"POST /report-issue":      {summary: "Report an issue", tag: "issues", request: models.Issue{}, response: reportIssueResponse{}},
+	"GET /issues":             {summary: "List issues, or stream them all with stream=ndjson|array", tag: "issues", auth: authUser, query: []string{"state", "assignee", "reportedBy", "label", "project", "overdue", "before", "limit", "stream"}, response: []models.Issue{}},
+	"GET /issues/{id:[0-9]+}": {summary: "Get an issue", tag: "issues", auth: authUser, response: models.Issue{}},
+	"GET /issues/{key:[A-Za-z][A-Za-z0-9]*-[0-9]+}": {summary: "Get an issue by its project key, such as FORM-123", tag: "issues", auth: authUser, response: models.Issue{}},
+	"PATCH /issues/{id:[0-9]+}":                     {summary: "Update an issue, if its version still matches If-Match or version", tag: "issues", auth: authUser, request: issueUpdate{}, response: models.Issue{}},
+	"POST /issues/{id:[0-9]+}/reopen":               {summary: "Reopen a closed issue", tag: "issues", auth: authUser, response: models.Issue{}},
+	"GET /issues/{id:[0-9]+}/history":               {summary: "List an issue's history", tag: "issues", auth: authUser, response: []models.IssueEvent{}},
+	"GET /issues/{id:[0-9]+}/comments":              {summary: "List comments", tag: "issues", auth: authUser, response: []models.Comment{}},
+	"POST /issues/{id:[0-9]+}/comments":             {summary: "Add a comment", tag: "issues", auth: authUser, request: commentRequest{}, status: http.StatusCreated, response: models.Comment{}},
+	"PUT /issues/{id:[0-9]+}/labels":                {summary: "Replace an issue's labels", tag: "issues", auth: authUser, request: labelsRequest{}, response: []string{}},
+	"GET /issues/{id:[0-9]+}/attachment-url":        {summary: "Get a signed URL for the attachment", tag: "issues", auth: authUser},
+	"POST /issues/{id:[0-9]+}/image":                {summary: "Upload an attachment", tag: "issues", auth: authUser, fileField: "image"},
+	"GET /dashboard":                                {summary: "Home screen summary", tag: "issues", auth: authUser, response: dashboard{}},
+	"GET /digests":                                  {summary: "List your digest subscriptions, or all of them for admins", tag: "digests", auth: authUser, response: []models.DigestSubscription{}},
+	"POST /digests":                                 {summary: "Subscribe to a daily or weekly digest by email, or for admins Slack", tag: "digests", auth: authUser, request: digestRequest{}, status: http.StatusCreated, response: models.DigestSubscription{}},
+	"PATCH /digests/{id:[0-9]+}":                    {summary: "Change how often a digest is sent", tag: "digests", auth: authUser, request: digestUpdate{}, response: models.DigestSubscription{}},
+	"DELETE /digests/{id:[0-9]+}":                   {summary: "Unsubscribe from a digest", tag: "digests", auth: authUser, status: http.StatusNoContent},
+	"GET /digests/{id:[0-9]+}/preview":              {summary: "The digest that would be sent now", tag: "digests", auth: authUser},
+
+	"GET /notifications":                   {summary: "List your notifications, newest first", tag: "notifications", auth: authUser, query: []string{"unread", "before", "limit"}, response: []models.Notification{}},
+	"GET /notifications/unread-count":      {summary: "Count your unread notifications", tag: "notifications", auth: authUser},
+	"POST /notifications/read-all":         {summary: "Mark all your notifications read", tag: "notifications", auth: authUser},
+	"POST /notifications/{id:[0-9]+}/read": {summary: "Mark a notification read", tag: "notifications", auth: authUser, response: models.Notification{}},
+	"GET /events":                          {summary: "Stream issue and comment events (server-sent events)", tag: "issues", auth: authUser, query: []string{"access_token"}, contentType: "text/event-stream"},
+
+	"GET /labels":                             {summary: "List labels with issue counts", tag: "labels", auth: authUser},
+	"PUT /admin/labels/{id:[0-9]+}":           {summary: "Rename a label", tag: "labels", auth: authAdmin},
+	"POST /admin/labels/{id:[0-9]+}/merge":    {summary: "Merge a label into another", tag: "labels", auth: authAdmin},
+	"GET /projects":                           {summary: "List projects", tag: "projects", auth: authUser, response: []models.Project{}},
+	"GET /projects/{project}":                 {summary: "Get a project by ID or key", tag: "projects", auth: authUser, response: models.Project{}},
+	"GET /projects/{project}/issues":          {summary: "List a project's issues", tag: "projects", auth: authUser, query: []string{"state", "assignee", "reportedBy", "label", "before", "limit", "stream"}, response: []models.Issue{}},
+	"POST /admin/projects":                    {summary: "Create a project", tag: "projects", auth: authAdmin, request: models.Project{}, status: http.StatusCreated, response: models.Project{}},
+	"PATCH /admin/projects/{id:[0-9]+}":       {summary: "Rename or describe a project, or override its auto-close policy", tag: "projects", auth: authAdmin, request: projectUpdate{}, response: models.Project{}},
+	"DELETE /admin/projects/{id:[0-9]+}":      {summary: "Delete a project with no issues", tag: "projects", auth: authAdmin, status: http.StatusNoContent},
+	"GET /releases":                           {summary: "List releases", tag: "releases", auth: authUser, response: []models.Release{}},
+	"GET /releases/{id:[0-9]+}/notes":         {summary: "Generate release notes", tag: "releases", auth: authUser, query: []string{"group", "format"}, contentType: "text/markdown"},
+	"POST /admin/releases":                    {summary: "Create a release", tag: "releases", auth: authAdmin, request: models.Release{}, status: http.StatusCreated, response: models.Release{}},
+	"POST /admin/releases/{id:[0-9]+}/issues": {summary: "Attach resolved issues to a release", tag: "releases", auth: authAdmin},
+
+	"POST " + csvUploadRoute:               {summary: "Import contacts from a CSV file", tag: "imports", fileField: "csvFile", contentType: "text/plain"},
+	"GET /imports":                         {summary: "List import jobs", tag: "imports", auth: authAdmin, response: []models.ImportJob{}},
+	"POST /imports":                        {summary: "Start an import", tag: "imports", auth: authAdmin, request: importRequest{}, status: http.StatusAccepted, response: models.ImportJob{}},
+	"GET /imports/{id:[0-9]+}":             {summary: "Get an import job", tag: "imports", auth: authAdmin, response: models.ImportJob{}},
+	"POST /imports/{id:[0-9]+}/abort":      {summary: "Abort a queued or running import", tag: "imports", auth: authAdmin, response: models.ImportJob{}},
+	"GET /import-sources":                  {summary: "List import sources", tag: "imports", auth: authAdmin, response: []models.ImportSource{}},
+	"POST /import-sources":                 {summary: "Create an import source", tag: "imports", auth: authAdmin, request: models.ImportSource{}, status: http.StatusCreated, response: models.ImportSource{}},
+	"DELETE /import-sources/{id:[0-9]+}":   {summary: "Delete an import source", tag: "imports", auth: authAdmin, status: http.StatusNoContent},
+	"POST /import-sources/{id:[0-9]+}/run": {summary: "Run an import source now", tag: "imports", auth: authAdmin, status: http.StatusAccepted, response: models.ImportJob{}},
+
+	"GET /unsubscribe":                           {summary: "Unsubscribe confirmation page", tag: "contacts", query: []string{"email", "sig"}, contentType: "text/html"},
+	"POST /unsubscribe":                          {summary: "Unsubscribe a contact", tag: "contacts", contentType: "text/html"},
+	"GET /contacts/duplicates":                   {summary: "Find contacts that look like the same person", tag: "contacts", auth: authAdmin, query: []string{"threshold"}, response: []duplicateGroup{}},
+	"POST /contacts/merge":                       {summary: "Merge duplicate contacts into one", tag: "contacts", auth: authAdmin, request: mergeRequest{}, response: contactView{}},
+	"GET /contacts/{id:[0-9]+}":                  {summary: "Get a contact and its merged links", tag: "contacts", auth: authAdmin, response: contactView{}},
+	"GET /contacts/{id:[0-9]+}/unsubscribe-link": {summary: "Get a contact's unsubscribe link", tag: "contacts", auth: authAdmin},
+	"GET /admin/suppressions":                    {summary: "Report suppressed contacts", tag: "contacts", auth: authAdmin, query: []string{"reason", "limit", "offset"}, response: suppressionReport{}},
+	"POST /admin/suppressions":                   {summary: "Suppress a contact", tag: "contacts", auth: authAdmin, request: suppressionRequest{}},
+	"DELETE /admin/suppressions/{id:[0-9]+}":     {summary: "Lift a suppression", tag: "contacts", auth: authAdmin, status: http.StatusNoContent},
+
+	"GET /corrections/{type}/{id:[0-9]+}":                       {summary: "Show the data held about a subject", tag: "corrections", query: []string{"expires", "sig"}},
+	"POST /corrections/{type}/{id:[0-9]+}":                      {summary: "Request a data correction", tag: "corrections", query: []string{"expires", "sig"}, status: http.StatusCreated},
+	"GET /admin/correction-links":                               {summary: "Create a signed correction link", tag: "corrections", auth: authAdmin, query: []string{"subjectType", "subjectId"}},
+	"GET /admin/corrections":                                    {summary: "List correction requests", tag: "corrections", auth: authAdmin, query: []string{"status"}, response: []models.CorrectionRequest{}},
+	"POST /admin/corrections/{id:[0-9]+}/{action:apply|reject}": {summary: "Apply or reject a correction request", tag: "corrections", auth: authAdmin, response: models.CorrectionRequest{}},
+
+	"GET /admin/metrics/reopens":   {summary: "Reopen rates per assignee or component", tag: "admin", auth: authAdmin, query: []string{"by", "days"}},
+	"GET /stats/issues":            {summary: "Issue counts, open and close trends, and time to resolution", tag: "admin", auth: authAdmin, query: []string{"interval", "days", "limit"}, response: issueStats{}},
+	"GET /admin/retention":         {summary: "Report what the retention sweep would archive now", tag: "admin", auth: authAdmin, response: retentionReport{}},
+	"POST /admin/retention/run":    {summary: "Run the retention sweep now", tag: "admin", auth: authAdmin, response: retentionResult{}},
+	"GET /admin/archive":           {summary: "List archived and merged issues and contacts", tag: "admin", auth: authAdmin, query: []string{"kind", "before", "limit"}, response: []archivedRecordView{}},
+	"GET /stats/imports":           {summary: "Contact totals and import growth over time", tag: "admin", auth: authAdmin, query: []string{"interval", "days"}, response: importStatsResponse{}},
+	"GET /admin/audit":             {summary: "Search the audit log", tag: "admin", auth: authAdmin, query: []string{"action", "subjectType", "subjectId", "limit", "stream"}, response: []models.AuditEntry{}},
+	"GET /admin/settings":          {summary: "List settings", tag: "admin", auth: authOperator},
+	"GET /admin/settings/{key}":    {summary: "Get a setting", tag: "admin", auth: authOperator},
+	"PUT /admin/settings/{key}":    {summary: "Override a setting", tag: "admin", auth: authOperator},
+	"DELETE /admin/settings/{key}": {summary: "Reset a setting to its default", tag: "admin", auth: authOperator},
+	"GET /admin/exports":           {summary: "List past exports", tag: "admin", auth: authAdmin, response: []models.ExportJob{}},
+	"GET /admin/exports/profiles":  {summary: "List redaction profiles", tag: "admin", auth: authAdmin},
+	"GET /admin/exports/{dataset}": {summary: "Export a dataset as CSV", tag: "admin", auth: authAdmin, query: []string{"profile"}, contentType: "text/csv"},
+	"GET /bi/{entity}":             {summary: "Incremental BI export", tag: "admin", auth: authAdmin, query: []string{"cursor", "limit"}},
+
+	"GET /admin/webhooks":                   {summary: "List webhooks", tag: "webhooks", auth: authAdmin, response: []models.Webhook{}},
+	"POST /admin/webhooks":                  {summary: "Register a webhook", tag: "webhooks", auth: authAdmin, status: http.StatusCreated},
+	"DELETE /admin/webhooks/{id:[0-9]+}":    {summary: "Delete a webhook", tag: "webhooks", auth: authAdmin, status: http.StatusNoContent},
+	"POST /admin/webhooks/{id:[0-9]+}/test": {summary: "Send a test delivery", tag: "webhooks", auth: authAdmin},
+
+	"GET /admin/jobs":                    {summary: "List background jobs", tag: "jobs", auth: authOperator, query: []string{"status", "kind", "limit"}, response: []models.Job{}},
+	"GET /admin/jobs/summary":            {summary: "Count jobs by kind and status", tag: "jobs", auth: authOperator, response: []jobCount{}},
+	"GET /admin/jobs/{id:[0-9]+}":        {summary: "Get a background job", tag: "jobs", auth: authOperator, response: models.Job{}},
+	"POST /admin/jobs/{id:[0-9]+}/retry": {summary: "Retry a failed job from the dead-letter queue", tag: "jobs", auth: authOperator, response: models.Job{}},
+	"GET /admin/emails":                  {summary: "List recent outgoing emails", tag: "email", auth: authOperator, query: []string{"status", "to"}, response: []models.EmailMessage{}},
+	"POST /admin/emails":                 {summary: "Queue an email", tag: "email", auth: authOperator, request: sendEmailRequest{}, status: http.StatusAccepted, response: models.EmailMessage{}},
+	"POST /webhooks/ses":                 {summary: "Receive SES bounces and complaints via SNS", tag: "email", status: http.StatusNoContent},
+	"POST /webhooks/mailgun":             {summary: "Receive Mailgun bounces and complaints", tag: "email", status: http.StatusNoContent},
+	"POST /webhooks/github":              {summary: "Close issues fixed by merged GitHub pull requests", tag: "integrations", status: http.StatusNoContent},
+	"POST /webhooks/sentry":              {summary: "File bug reports for new Sentry issues", tag: "integrations", status: http.StatusCreated},
+
+	"GET /admin/users":                             {summary: "List user accounts", tag: "users", auth: authOperator, query: []string{"role", "before", "limit"}, response: []userView{}},
+	"GET /admin/users/{id:[0-9]+}":                 {summary: "Get a user account", tag: "users", auth: authOperator, response: userView{}},
+	"DELETE /admin/users/{id:[0-9]+}":              {summary: "Delete a user, reassigning or clearing the issues they reported", tag: "users", auth: authOperator, query: []string{"reassignTo"}},
+	"PUT /admin/users/{id:[0-9]+}/role":            {summary: "Change a user's account role", tag: "users", auth: authOperator, request: roleRequest{}, response: userView{}},
+	"POST /admin/users/{id:[0-9]+}/disable":        {summary: "Disable a user account", tag: "users", auth: authOperator, response: userView{}},
+	"POST /admin/users/{id:[0-9]+}/enable":         {summary: "Re-enable a user account", tag: "users", auth: authOperator, response: userView{}},
+	"POST /admin/users/{id:[0-9]+}/password-reset": {summary: "Force a password reset and get the reset link", tag: "users", auth: authOperator},
+	"POST /password-reset/{id:[0-9]+}":             {summary: "Choose a new password through a reset link", tag: "users", query: []string{"expires", "sig"}, request: passwordResetRequest{}, status: http.StatusNoContent},
+
+	"GET /organizations":                    {summary: "List the caller's organizations", tag: "organizations", auth: authUser, response: []models.Organization{}},
+	"POST /admin/organizations":             {summary: "Create an organization", tag: "organizations", auth: authOperator, request: models.Organization{}, status: http.StatusCreated, response: models.Organization{}},
+	"PATCH /admin/organization":             {summary: "Rename the organization or set its default language", tag: "organizations", auth: authAdmin, request: organizationUpdate{}, response: models.Organization{}},
+	"GET /admin/members":                    {summary: "List members of the organization", tag: "organizations", auth: authAdmin, response: []memberView{}},
+	"POST /admin/members":                   {summary: "Add a member or change their role", tag: "organizations", auth: authAdmin, request: addMemberRequest{}, response: memberView{}},
+	"DELETE /admin/members/{userId:[0-9]+}": {summary: "Remove a member", tag: "organizations", auth: authAdmin, status: http.StatusNoContent},
+
+	"GET " + uploadsPrefix + "{name}":               {summary: "Download an attachment from before organizations via a signed URL", tag: "issues", query: []string{"expires", "sig"}, contentType: "application/octet-stream"},
+	"HEAD " + uploadsPrefix + "{name}":              {summary: "Check an attachment from before organizations via a signed URL", tag: "issues", query: []string{"expires", "sig"}},
+	"GET " + uploadsPrefix + "{org:[0-9]+}/{name}":  {summary: "Download an attachment via a signed URL", tag: "issues", query: []string{"expires", "sig"}, contentType: "application/octet-stream"},
+	"HEAD " + uploadsPrefix + "{org:[0-9]+}/{name}": {summary: "Check an attachment via a signed URL", tag: "issues", query: []string{"expires", "sig"}},
+
+	"POST /graphql":              {summary: "GraphQL queries over issues, comments, labels and users; see graph/schema.graphqls", tag: "graphql", auth: authUser, request: graphQLRequest{}},
+	"GET /openapi.json":          {summary: "This specification", tag: "ops"},
+	"GET /debug/config":          {summary: "Effective configuration, schema drift and dependency versions", tag: "ops", auth: authOperator, response: debugConfigResponse{}},
+	"GET /debug/pprof/":          {summary: "List runtime profiles; operators or X-Debug-Token", tag: "ops", auth: authOperator, contentType: "text/html"},
+	"GET /debug/pprof/cmdline":   {summary: "The running command line", tag: "ops", auth: authOperator, contentType: "text/plain"},
+	"GET /debug/pprof/profile":   {summary: "CPU profile over the given seconds", tag: "ops", auth: authOperator, query: []string{"seconds"}, contentType: "application/octet-stream"},
+	"GET /debug/pprof/symbol":    {summary: "Look up program counters", tag: "ops", auth: authOperator, contentType: "text/plain"},
+	"POST /debug/pprof/symbol":   {summary: "Look up program counters", tag: "ops", auth: authOperator, contentType: "text/plain"},
+	"GET /debug/pprof/trace":     {summary: "Execution trace over the given seconds", tag: "ops", auth: authOperator, query: []string{"seconds"}, contentType: "application/octet-stream"},
+	"GET /debug/pprof/{profile}": {summary: "A named profile such as heap, allocs or goroutine", tag: "ops", auth: authOperator, query: []string{"debug", "gc", "seconds"}, contentType: "application/octet-stream"},
+	"GET /debug/vars":            {summary: "expvar counters, including memstats", tag: "ops", auth: authOperator},
+	"GET /docs":                  {summary: "Swagger UI", tag: "ops", contentType: "text/html"},
+}
+
+// buildOpenAPISpec generates the OpenAPI 3 document from the registered
+// routes and apiDocs.
+func (a *App) buildOpenAPISpec(router *mux.Router) ([]byte, error) {
+	g := &schemaGen{components: map[string]interface{}{}}
+	g.components["Error"] = g.object(reflect.TypeOf(apiError{}))
+	paths := map[string]map[string]interface{}{}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path, params := openAPIPath(tpl)
+		for _, method := range methods {
+			doc, ok := apiDocs[method+" "+tpl]
+			if !ok {
+				log.Printf("No API docs for %s %s", method, tpl)
+			}
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			paths[path][strings.ToLower(method)] = g.operation(method, path, params, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Form API",
+			"version": version,
+		},
+		"servers": []map[string]string{{"url": strings.TrimRight(a.cfg.PublicURL, "/")}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}, "", "  ")
+}
+
+var pathVarPattern = regexp.MustCompile(`\{([^}:]+)(?::([^}]+))?\}`)
+
+// openAPIPath turns a mux template such as /issues/{id:[0-9]+} into
+// /issues/{id} and describes its path parameters.
+func openAPIPath(tpl string) (string, []interface{}) {
+	var params []interface{}
+	for _, m := range pathVarPattern.FindAllStringSubmatch(tpl, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		switch {
+		case m[2] == "[0-9]+":
+			schema = map[string]interface{}{"type": "integer", "minimum": 1}
+		case m[2] != "" && regexp.MustCompile(`^[a-z|]+$`).MatchString(m[2]):
+			schema["enum"] = strings.Split(m[2], "|")
+		}
+		params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": schema})
+	}
+	return pathVarPattern.ReplaceAllString(tpl, "{$1}"), params
+}
+
+func (g *schemaGen) operation(method, path string, params []interface{}, doc apiDoc) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": strings.ToLower(method) + strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_").Replace(path),
+	}
+	if doc.summary != "" {
+		op["summary"] = doc.summary
+	}
+	if doc.tag != "" {
+		op["tags"] = []string{doc.tag}
+	}
+	for _, q := range doc.query {
+		params = append(params, map[string]interface{}{"name": q, "in": "query", "schema": map[string]string{"type": "string"}})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.fileField != "" {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{
+				"schema": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{doc.fileField: map[string]string{"type": "string", "format": "binary"}},
+				},
+			}},
+		}
+	} else if doc.request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(doc.request))}},
+		}
+	}
+
+	status := doc.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if status != http.StatusNoContent {
+		switch {
+		case doc.contentType != "":
+			success["content"] = map[string]interface{}{doc.contentType: map[string]interface{}{}}
+		case doc.response != nil:
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(doc.response))}}
+		default:
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]string{"type": "object"}}}
+		}
+	}
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/Error"}}},
+	}
+	op["responses"] = map[string]interface{}{strconv.Itoa(status): success, "default": errorResponse}
+
+	if doc.auth != authNone {
+		op["security"] = []map[string][]string{{"bearerAuth": {}}}
+		switch doc.auth {
+		case authAdmin:
+			op["description"] = "Requires an admin of the organization."
+		case authOperator:
+			op["description"] = "Requires an operator token."
+		}
+	}
+	return op
+}
+
+// schemaGen builds JSON schemas from Go types, collecting named structs as
+// reusable components.
+type schemaGen struct {
+	components map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return g.object(t)
+		}
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = map[string]interface{}{} // placeholder for recursive types
+			g.components[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// object describes a struct the way encoding/json encodes it, applying
+// validate tags as constraints.
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	g.fields(t, properties, &required)
+
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGen) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := g.schema(f.Type)
+		if _, isRef := s["$ref"]; !isRef {
+			applyValidateTag(s, f.Tag.Get("validate"), required, name)
+		}
+		if f.Type.Kind() == reflect.Ptr || strings.Contains(opts, "omitempty") {
+			if _, isRef := s["$ref"]; !isRef {
+				s["nullable"] = true
+			}
+		}
+		properties[name] = s
+	}
+}
+
+// applyValidateTag translates the validator rules the handlers enforce into
+// schema constraints.
+func applyValidateTag(s map[string]interface{}, tag string, required *[]string, name string) {
+	if tag == "" {
+		return
+	}
+	str := s["type"] == "string"
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		n, _ := strconv.Atoi(param)
+		switch key {
+		case "required":
+			*required = append(*required, name)
+		case "email":
+			s["format"] = "email"
+		case "url":
+			s["format"] = "uri"
+		case "oneof":
+			s["enum"] = strings.Fields(param)
+		case "min":
+			if str {
+				s["minLength"] = n
+			} else if s["type"] == "array" {
+				s["minItems"] = n
+			} else {
+				s["minimum"] = n
+			}
+		case "max":
+			if str {
+				s["maxLength"] = n
+			} else if s["type"] == "array" {
+				s["maxItems"] = n
+			} else {
+				s["maximum"] = n
+			}
+		}
+	}
+}
+
+func (a *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(a.openAPISpec)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Form API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head><body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body></html>`
+
+// docsHandler serves Swagger UI for the generated spec.
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
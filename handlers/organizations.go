@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"form/config"
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Membership roles. Org admins manage their organization; deployment-wide
+// settings and operations stay with operators, whose account role is admin.
+const (
+	orgRoleAdmin  = "admin"
+	orgRoleMember = "member"
+)
+
+// tenantTables are the tables with an organization_id column. Every query
+// on them is scoped to the organization in its context.
+var tenantTables = map[string]bool{
+	"issues": true, "emails": true, "labels": true, "releases": true,
+	"webhooks": true, "import_jobs": true, "import_sources": true,
+	"export_jobs": true, "audit_entries": true, "correction_requests": true,
+	"projects": true, "archived_records": true, "digest_subscriptions": true,
+	"contact_links": true, "notifications": true, "mentions": true,
+}
+
+const (
+	organizationContextKey contextKey = "organization"
+	accountContextKey      contextKey = "account"
+)
+
+// orgScope is what queries are scoped to. all is for background work that
+// spans organizations, such as the auto-close sweep.
+type orgScope struct {
+	id  uint
+	all bool
+}
+
+var errNoOrganization = errors.New("tenant table used outside an organization")
+
+// withOrganization scopes queries made with ctx to one organization.
+func withOrganization(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, organizationContextKey, orgScope{id: id})
+}
+
+// allOrganizations lifts tenant scoping for ctx. Rows created under it must
+// set OrganizationID themselves.
+func allOrganizations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, organizationContextKey, orgScope{all: true})
+}
+
+// contextOrganization returns the organization ctx is scoped to, or 0.
+func contextOrganization(ctx context.Context) uint {
+	s, _ := ctx.Value(organizationContextKey).(orgScope)
+	return s.id
+}
+
+// sameOrganization reports whether a row from organization id may be used
+// under ctx.
+func sameOrganization(ctx context.Context, id uint) bool {
+	s, _ := ctx.Value(organizationContextKey).(orgScope)
+	return s.all || s.id == id
+}
+
+// isOperator reports whether the account behind ctx runs the deployment,
+// as opposed to administering one organization.
+func isOperator(ctx context.Context) bool {
+	account, _ := ctx.Value(accountContextKey).(*models.User)
+	return account != nil && account.Role == "admin"
+}
+
+// organizationAdmin reports whether user administers the organization they
+// are acting in.
+func organizationAdmin(user *models.User) bool {
+	return user != nil && user.MembershipRole == orgRoleAdmin
+}
+
+// requireOperator rejects requests that are not from an operator.
+func requireOperator(h http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !isOperator(r.Context()) {
+			writeError(w, http.StatusForbidden, "Operator access required")
+			return
+		}
+		h(w, r)
+	})
+}
+
+var (
+	errUnknownOrganization = newServiceError(http.StatusNotFound, "Unknown organization")
+	errNotMember           = newServiceError(http.StatusForbidden, "Not a member of this organization")
+)
+
+// resolveOrganization picks the organization a caller acts in: the one
+// named by slug, else the user's oldest membership, else the default. The
+// returned user carries their role in it, so role checks elsewhere are per
+// organization; operators act as admins everywhere.
+func (a *App) resolveOrganization(ctx context.Context, user *models.User, slug string) (context.Context, *models.User, error) {
+	var org models.Organization
+	if slug != "" {
+		err := a.dbCtx(ctx).Where("slug = ?", slug).First(&org).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx, user, errUnknownOrganization
+		}
+		if err != nil {
+			return ctx, user, err
+		}
+	}
+	if user == nil {
+		if org.ID == 0 {
+			org.ID = models.DefaultOrganizationID
+		}
+		return withOrganization(ctx, org.ID), nil, nil
+	}
+
+	var m models.Membership
+	q := a.dbCtx(ctx).Where("user_id = ?", user.ID)
+	if org.ID != 0 {
+		q = q.Where("organization_id = ?", org.ID)
+	}
+	err := q.Order("created_at, id").First(&m).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx, user, err
+	}
+
+	effective := *user
+	switch {
+	case user.Role == "admin":
+		effective.Role = "admin"
+		effective.MembershipRole = orgRoleAdmin
+		if org.ID == 0 {
+			org.ID = m.OrganizationID
+		}
+		if org.ID == 0 {
+			org.ID = models.DefaultOrganizationID
+		}
+	case err != nil:
+		return ctx, user, errNotMember
+	default:
+		org.ID = m.OrganizationID
+		effective.Role = ""
+		effective.MembershipRole = m.Role
+		if m.Role == orgRoleAdmin {
+			effective.Role = "admin"
+		}
+	}
+
+	ctx = context.WithValue(ctx, accountContextKey, user)
+	ctx = context.WithValue(ctx, userContextKey, &effective)
+	return withOrganization(ctx, org.ID), &effective, nil
+}
+
+// organizationMiddleware resolves the organization after authMiddleware has
+// identified the caller.
+func (a *App) organizationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, _, err := a.resolveOrganization(r.Context(), contextUser(r.Context()), r.Header.Get(config.OrganizationHeader))
+		if err != nil {
+			writeServiceError(w, err, "Error resolving organization")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// registerTenantCallbacks scopes every GORM query, update and delete on a
+// tenant table to the organization in its context, and stamps it on
+// created rows. Without one they fail rather than touch every tenant.
+func registerTenantCallbacks(db *gorm.DB) {
+	scoped := func(tx *gorm.DB) {
+		// Hand-written SQL cannot take extra conditions; such queries
+		// filter by organization themselves
+		if !tenantTables[tx.Statement.Table] || tx.Statement.SQL.Len() > 0 {
+			return
+		}
+		s, ok := statementOrganization(tx.Statement)
+		if !ok {
+			tx.AddError(errNoOrganization)
+			return
+		}
+		if !s.all {
+			tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "organization_id"}, Value: s.id},
+			}})
+		}
+	}
+	create := func(tx *gorm.DB) {
+		stmt := tx.Statement
+		if !tenantTables[stmt.Table] {
+			return
+		}
+		var field *schema.Field
+		if stmt.Schema != nil {
+			field = stmt.Schema.LookUpField("OrganizationID")
+		}
+		s, found := statementOrganization(stmt)
+		if field == nil || !found {
+			tx.AddError(errNoOrganization)
+			return
+		}
+		stamp := func(rv reflect.Value) {
+			v, blank := field.ValueOf(stmt.Context, rv)
+			switch {
+			case s.all:
+				// Cross-organization work must say where each row goes
+				if blank {
+					tx.AddError(errNoOrganization)
+				}
+			case blank:
+				tx.AddError(field.Set(stmt.Context, rv, s.id))
+			case v.(uint) != s.id:
+				tx.AddError(errNoOrganization)
+			}
+		}
+		switch rv := stmt.ReflectValue; rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				stamp(reflect.Indirect(rv.Index(i)))
+			}
+		case reflect.Struct:
+			stamp(rv)
+		default:
+			tx.AddError(errNoOrganization)
+		}
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("tenant:create", create)
+	cb.Query().Before("gorm:query").Register("tenant:query", scoped)
+	cb.Row().Before("gorm:row").Register("tenant:row", scoped)
+	cb.Update().Before("gorm:update").Register("tenant:update", scoped)
+	cb.Delete().Before("gorm:delete").Register("tenant:delete", scoped)
+}
+
+func statementOrganization(stmt *gorm.Statement) (orgScope, bool) {
+	s, ok := stmt.Context.Value(organizationContextKey).(orgScope)
+	return s, ok && (s.all || s.id != 0)
+}
+
+// acrossOrganizations returns q, which may be a transaction, with tenant
+// scoping lifted as allOrganizations does for a context.
+func acrossOrganizations(q *gorm.DB) *gorm.DB {
+	return q.WithContext(allOrganizations(q.Statement.Context))
+}
+
+// recordOrganization returns the organization a tenant model belongs to.
+func recordOrganization(record interface{}) uint {
+	field := reflect.Indirect(reflect.ValueOf(record)).FieldByName("OrganizationID")
+	if !field.IsValid() {
+		return 0
+	}
+	return uint(field.Uint())
+}
+
+// organizationMembers selects the users who belong to ctx's organization.
+func (a *App) organizationMembers(ctx context.Context) *gorm.DB {
+	return a.dbCtx(ctx).Model(&models.User{}).Select("users.*").
+		Joins("JOIN memberships ON memberships.user_id = users.id").
+		Where("memberships.organization_id = ?", contextOrganization(ctx))
+}
+
+// organizationMember loads username as they act in ctx's organization,
+// with MembershipRole set to their role in it. Operators act as admins
+// without a membership; anyone else who is not a member is not found.
+func (a *App) organizationMember(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := a.dbCtx(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if user.Role == "admin" {
+		user.MembershipRole = orgRoleAdmin
+		return &user, nil
+	}
+	var m models.Membership
+	if err := a.dbCtx(ctx).Where("organization_id = ? AND user_id = ?", contextOrganization(ctx), user.ID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	user.MembershipRole = m.Role
+	return &user, nil
+}
+
+// addMember gives user a role in an organization, replacing any role they
+// already had there.
+func addMember(q *gorm.DB, orgID, userID uint, role string) (models.Membership, error) {
+	m := models.Membership{OrganizationID: orgID, UserID: userID, Role: role}
+	err := q.Where(models.Membership{OrganizationID: orgID, UserID: userID}).
+		Assign(models.Membership{Role: role}).FirstOrCreate(&m).Error
+	return m, err
+}
+
+type addMemberRequest struct {
+	Username string `json:"username" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=admin member"`
+}
+
+type memberView struct {
+	UserID   uint      `json:"userId"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Since    time.Time `json:"since"`
+}
+
+// listOrganizationsHandler returns the organizations the caller belongs to,
+// or every organization for operators.
+func (a *App) listOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentAccount(r)
+	q := a.dbCtx(r.Context()).Order("id")
+	if user.Role != "admin" {
+		q = q.Where("id IN (SELECT organization_id FROM memberships WHERE user_id = ?)", user.ID)
+	}
+	var orgs []models.Organization
+	if err := q.Find(&orgs).Error; err != nil {
+		writeDBError(w, err, "Error loading organizations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, orgs)
+}
+
+// createOrganizationHandler creates an organization with the operator
+// calling it as its first admin.
+func (a *App) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentAccount(r)
+	var org models.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	org.ID, org.CreatedAt = 0, time.Time{}
+	org.Slug = strings.ToLower(org.Slug)
+	if !validateRequest(w, &org) {
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	err := tx.Create(&org).Error
+	if err == nil {
+		_, err = addMember(tx, org.ID, user.ID, orgRoleAdmin)
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to create organization")
+		return
+	}
+
+	log.Printf("Organization %s created by %s", org.Slug, user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, org)
+}
+
+// organizationUpdate is a partial update of the current organization; nil
+// fields are left unchanged and an empty language means English.
+type organizationUpdate struct {
+	Name            *string `json:"name" validate:"omitnil,notblank,max=255"`
+	DefaultLanguage *string `json:"defaultLanguage" validate:"omitnil,omitempty,locale"`
+}
+
+// updateOrganizationHandler renames the current organization or changes its
+// default language.
+func (a *App) updateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var body organizationUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if body.DefaultLanguage != nil {
+		lang := strings.ToLower(strings.TrimSpace(*body.DefaultLanguage))
+		body.DefaultLanguage = &lang
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	var org models.Organization
+	if err := a.dbCtx(r.Context()).First(&org, contextOrganization(r.Context())).Error; err != nil {
+		writeDBError(w, err, "Error loading organization")
+		return
+	}
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		updates["name"] = strings.TrimSpace(*body.Name)
+	}
+	if body.DefaultLanguage != nil {
+		updates["default_language"] = *body.DefaultLanguage
+	}
+	if len(updates) > 0 {
+		if err := a.dbCtx(r.Context()).Model(&org).Updates(updates).Error; err != nil {
+			writeDBError(w, err, "Failed to update organization")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, org)
+}
+
+// listMembersHandler returns the members of the current organization.
+func (a *App) listMembersHandler(w http.ResponseWriter, r *http.Request) {
+	var members []memberView
+	err := a.dbCtx(r.Context()).Table("memberships").
+		Select("memberships.user_id, users.username, memberships.role, memberships.created_at AS since").
+		Joins("JOIN users ON users.id = memberships.user_id").
+		Where("memberships.organization_id = ?", contextOrganization(r.Context())).
+		Order("users.username").Scan(&members).Error
+	if err != nil {
+		writeDBError(w, err, "Error loading members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, members)
+}
+
+// addMemberHandler adds a user to the current organization, or changes the
+// role of one who is already a member.
+func (a *App) addMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var body addMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	var member models.User
+	if err := a.dbCtx(r.Context()).Where("username = ?", body.Username).First(&member).Error; err != nil {
+		writeDBError(w, err, "Error loading user")
+		return
+	}
+	m, err := addMember(a.dbCtx(r.Context()), contextOrganization(r.Context()), member.ID, body.Role)
+	if err != nil {
+		writeDBError(w, err, "Failed to add member")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, memberView{UserID: member.ID, Username: member.Username, Role: m.Role, Since: m.CreatedAt})
+}
+
+// removeMemberHandler removes a user from the current organization.
+func (a *App) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	result := a.dbCtx(r.Context()).
+		Where("organization_id = ? AND user_id = ?", contextOrganization(r.Context()), mux.Vars(r)["userId"]).
+		Delete(&models.Membership{})
+	if result.Error != nil {
+		writeDBError(w, result.Error, "Failed to remove member")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentAccount returns the caller's own account, whose role is the
+// deployment-wide one rather than their role in the organization.
+func currentAccount(r *http.Request) (*models.User, error) {
+	if account, ok := r.Context().Value(accountContextKey).(*models.User); ok && account != nil {
+		return account, nil
+	}
+	return currentUser(r)
+}
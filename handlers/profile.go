@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"form/models"
+)
+
+// profile is the caller's own account as /me and /login return it.
+type profile struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+	Language    string `json:"language"`
+	Role        string `json:"role"`
+}
+
+func newProfile(u models.User) profile {
+	return profile{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, Email: u.Email, Language: u.Language, Role: u.Role}
+}
+
+// profileUpdate replaces the caller's display name, email and language;
+// leaving a field out clears it.
+type profileUpdate struct {
+	DisplayName string `json:"displayName" validate:"max=255"`
+	Email       string `json:"email" validate:"omitempty,email,max=255"`
+	Language    string `json:"language" validate:"omitempty,locale"`
+}
+
+type passwordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=72"`
+}
+
+func (a *App) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	account, err := currentAccount(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, newProfile(*account))
+}
+
+func (a *App) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	account, err := currentAccount(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	var body profileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	body.DisplayName = strings.TrimSpace(body.DisplayName)
+	body.Email = strings.TrimSpace(body.Email)
+	body.Language = strings.ToLower(strings.TrimSpace(body.Language))
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	user := *account
+	if err := a.dbCtx(r.Context()).Model(&user).Updates(map[string]interface{}{
+		"display_name": body.DisplayName,
+		"email":        body.Email,
+		"language":     body.Language,
+	}).Error; err != nil {
+		writeDBError(w, err, "Failed to update profile")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, newProfile(user))
+}
+
+// changePasswordHandler sets a new password for the caller once they have
+// confirmed the current one. Other tokens stop working, so the response
+// carries a fresh one.
+func (a *App) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	account, err := currentAccount(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	var body passwordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	var user models.User
+	if err := a.dbCtx(r.Context()).First(&user, account.ID).Error; err != nil {
+		writeDBError(w, err, "Error loading user")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(body.CurrentPassword), []byte(user.Password)) != 1 {
+		writeError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+
+	err = a.dbCtx(r.Context()).Model(&user).Updates(map[string]interface{}{
+		"password":            body.NewPassword,
+		"password_changed_at": time.Now(),
+	}).Error
+	if err != nil {
+		writeDBError(w, err, "Failed to change password")
+		return
+	}
+	token, err := a.issueToken(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"message": localize(w, "Password changed"), "token": token})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// projectUpdate is a partial project update; nil fields are left unchanged.
+// Key is only accepted unchanged.
+type projectUpdate struct {
+	Key         *string `json:"key"`
+	Name        *string `json:"name" validate:"omitempty,notblank,max=255"`
+	Description *string `json:"description" validate:"omitempty,max=2000"`
+	// AutoCloseAfterDays and AutoCloseWarningDays set the project's
+	// overrides; -1 clears one, so the setting applies again.
+	AutoCloseAfterDays   *int `json:"autoCloseAfterDays" validate:"omitnil,min=-1,max=3650"`
+	AutoCloseWarningDays *int `json:"autoCloseWarningDays" validate:"omitnil,min=-1,max=3650"`
+}
+
+var (
+	errProjectNotFound = newServiceError(http.StatusNotFound, "Project not found")
+	errUnknownProject  = newServiceError(http.StatusBadRequest, "Unknown project")
+)
+
+// findProject loads a project by ID or by key.
+func (a *App) findProject(ctx context.Context, ref string) (models.Project, error) {
+	var project models.Project
+	q := a.dbCtx(ctx)
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		q = q.Where("id = ?", id)
+	} else {
+		q = q.Where("key = ?", strings.ToUpper(ref))
+	}
+	err := q.First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return project, errProjectNotFound
+	}
+	return project, err
+}
+
+// claimIssueNumber hands out the next issue number in a project and the key
+// that goes with it. tx must be a transaction: the project row stays locked
+// until it ends, so two issues never get the same number.
+func claimIssueNumber(tx *gorm.DB, projectID uint) (int, string, error) {
+	var project models.Project
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, projectID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", errUnknownProject
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tx.Model(&project).UpdateColumn("issue_count", gorm.Expr("issue_count + 1")).Error; err != nil {
+		return 0, "", err
+	}
+	number := project.IssueCount + 1
+	return number, fmt.Sprintf("%s-%d", project.Key, number), nil
+}
+
+func (a *App) listProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	var projects []models.Project
+	if err := a.dbCtx(r.Context()).Order("key").Find(&projects).Error; err != nil {
+		writeDBError(w, err, "Error loading projects")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, projects)
+}
+
+// getProjectHandler returns a project by ID or key.
+func (a *App) getProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := a.findProject(r.Context(), mux.Vars(r)["project"])
+	if err != nil {
+		writeServiceError(w, err, "Error loading project")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, project)
+}
+
+func (a *App) createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var project models.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	project.ID, project.OrganizationID, project.IssueCount = 0, 0, 0
+	project.Key = strings.ToUpper(strings.TrimSpace(project.Key))
+	project.Name = strings.TrimSpace(project.Name)
+	if !validateRequest(w, &project) {
+		return
+	}
+
+	if err := a.dbCtx(r.Context()).Create(&project).Error; err != nil {
+		writeDBError(w, err, "Failed to create project")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, project)
+}
+
+// updateProjectHandler changes a project's name, description or auto-close
+// overrides.
+func (a *App) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var body projectUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	project, err := a.findProject(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, err, "Error loading project")
+		return
+	}
+	if body.Key != nil && strings.ToUpper(*body.Key) != project.Key {
+		writeError(w, http.StatusBadRequest, "Project keys cannot be changed")
+		return
+	}
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		updates["name"] = strings.TrimSpace(*body.Name)
+	}
+	if body.Description != nil {
+		updates["description"] = *body.Description
+	}
+	for column, days := range map[string]*int{
+		"auto_close_after_days":   body.AutoCloseAfterDays,
+		"auto_close_warning_days": body.AutoCloseWarningDays,
+	} {
+		if days != nil && *days < 0 {
+			updates[column] = nil
+		} else if days != nil {
+			updates[column] = *days
+		}
+	}
+	if len(updates) > 0 {
+		if err := a.dbCtx(r.Context()).Model(&project).Updates(updates).Error; err != nil {
+			writeDBError(w, err, "Failed to update project")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, project)
+}
+
+// deleteProjectHandler deletes a project with no issues in it. Issues have
+// to be moved out first so their keys are not left dangling.
+func (a *App) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := a.findProject(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, err, "Error loading project")
+		return
+	}
+
+	// An issue filed since the count makes the delete fail on the foreign
+	// key on Postgres. SQLite has no foreign keys, but its transactions take
+	// the write lock when they begin, so none can be filed in between.
+	var count int64
+	tx := a.dbCtx(r.Context()).Begin()
+	// Soft-deleted issues still reference the project
+	err = tx.Unscoped().Model(&models.Issue{}).Where("project_id = ?", project.ID).Count(&count).Error
+	if err == nil && count > 0 {
+		tx.Rollback()
+		writeError(w, http.StatusConflict, "Project still has issues")
+		return
+	}
+	if err == nil {
+		err = tx.Delete(&project).Error
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to delete project")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getIssueByKeyHandler is getIssueByIDHandler for project keys such as
+// FORM-123.
+func (a *App) getIssueByKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var ref models.Issue
+	err := a.dbCtx(r.Context()).Select("id").Where("key = ?", strings.ToUpper(mux.Vars(r)["key"])).First(&ref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errIssueNotFound
+	}
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving issue")
+		return
+	}
+
+	user, _ := currentUser(r)
+	issue, err := a.cachedAccessibleIssue(r.Context(), user, ref.ID)
+	if err != nil {
+		writeServiceError(w, err, "Error retrieving issue")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", issueETag(issue))
+	a.encodeJSON(w, r, issue)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// issueTypeHeadings names the release notes sections when grouping by the
+// issue Type flag, which is set for bug reports.
+var issueTypeHeadings = map[bool]string{true: "Bug fixes", false: "Changes"}
+
+const unlabelledHeading = "Other"
+
+func (a *App) listReleasesHandler(w http.ResponseWriter, r *http.Request) {
+	var releases []models.Release
+	if err := a.dbCtx(r.Context()).Order("created_at desc").Find(&releases).Error; err != nil {
+		log.Println("Error loading releases:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading releases")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, releases)
+}
+
+func (a *App) createReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	var release models.Release
+	if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &release) {
+		return
+	}
+	release.ID, release.OrganizationID = 0, 0
+	release.Version = strings.TrimSpace(release.Version)
+	release.CreatedBy = actorName(r)
+
+	var count int64
+	a.dbCtx(r.Context()).Model(&models.Release{}).Where("version = ?", release.Version).Count(&count)
+	if count > 0 {
+		writeError(w, http.StatusConflict, "Release already exists")
+		return
+	}
+	if err := a.dbCtx(r.Context()).Create(&release).Error; err != nil {
+		writeDBError(w, err, "Failed to create release")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, release)
+}
+
+// attachReleaseIssuesHandler sets the release as the fix version of the
+// given issues. Only resolved (closed) issues are attached; the rest are
+// reported back as skipped.
+func (a *App) attachReleaseIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	var release models.Release
+	if err := a.dbCtx(r.Context()).First(&release, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Release not found")
+		return
+	}
+
+	var body struct {
+		IssueIDs []uint `json:"issueIds" validate:"required,min=1,max=1000"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	actor := actorName(r)
+	tx := a.dbCtx(r.Context()).Begin()
+	var issues []models.Issue
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN (?)", body.IssueIDs).Find(&issues).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error loading issues:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to attach issues")
+		return
+	}
+
+	attached := []uint{}
+	skipped := map[uint]string{}
+	found := map[uint]bool{}
+	for _, issue := range issues {
+		found[issue.ID] = true
+		if !issue.Status {
+			skipped[issue.ID] = "not resolved"
+			continue
+		}
+		if issue.FixVersionID != nil && *issue.FixVersionID == release.ID {
+			attached = append(attached, issue.ID)
+			continue
+		}
+
+		from := ""
+		if issue.FixVersionID != nil {
+			var previous models.Release
+			if tx.First(&previous, *issue.FixVersionID).Error == nil {
+				from = previous.Version
+			}
+		}
+		if err := tx.Model(&issue).Updates(map[string]interface{}{
+			"fix_version_id": release.ID,
+			"version":        gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			tx.Rollback()
+			log.Println("Error attaching issue to release:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to attach issues")
+			return
+		}
+		if err := a.recordIssueEvent(tx, issue.ID, eventFixVersionSet, actor, from, release.Version); err != nil {
+			tx.Rollback()
+			log.Println("Error recording issue event:", err)
+			writeError(w, http.StatusInternalServerError, "Failed to attach issues")
+			return
+		}
+		attached = append(attached, issue.ID)
+	}
+	for _, id := range body.IssueIDs {
+		if !found[id] {
+			skipped[id] = "not found"
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error attaching issues to release:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to attach issues")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"attached": attached, "skipped": skipped})
+}
+
+type releaseNoteEntry struct {
+	ID       uint     `json:"id"`
+	Key      string   `json:"key,omitempty"`
+	Title    string   `json:"title"`
+	Priority int      `json:"priority"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+type releaseNoteSection struct {
+	Heading string             `json:"heading"`
+	Issues  []releaseNoteEntry `json:"issues"`
+}
+
+// releaseNotesHandler generates the changelog for a release, grouped by
+// label (the default) or by issue type. An issue with several labels is
+// listed under each. Markdown is returned unless format=json.
+func (a *App) releaseNotesHandler(w http.ResponseWriter, r *http.Request) {
+	var release models.Release
+	if err := a.dbCtx(r.Context()).First(&release, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Release not found")
+		return
+	}
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = "label"
+	}
+	if group != "label" && group != "type" {
+		writeError(w, http.StatusBadRequest, "group must be label or type")
+		return
+	}
+
+	var issues []models.Issue
+	if err := a.dbCtx(r.Context()).Where("fix_version_id = ?", release.ID).Order("priority desc, id").Find(&issues).Error; err != nil {
+		log.Println("Error loading release issues:", err)
+		writeError(w, http.StatusInternalServerError, "Error generating release notes")
+		return
+	}
+
+	labels := map[uint][]string{}
+	if len(issues) > 0 {
+		ids := make([]uint, len(issues))
+		for i, issue := range issues {
+			ids[i] = issue.ID
+		}
+		var rows []struct {
+			IssueID uint
+			Name    string
+		}
+		if err := a.dbCtx(r.Context()).Table("issue_labels").Select("issue_labels.issue_id, labels.name").
+			Joins("JOIN labels ON labels.id = issue_labels.label_id").
+			Where("issue_labels.issue_id IN (?)", ids).Order("labels.name").Scan(&rows).Error; err != nil {
+			log.Println("Error loading release issue labels:", err)
+			writeError(w, http.StatusInternalServerError, "Error generating release notes")
+			return
+		}
+		for _, row := range rows {
+			labels[row.IssueID] = append(labels[row.IssueID], row.Name)
+		}
+	}
+
+	byHeading := map[string][]releaseNoteEntry{}
+	for _, issue := range issues {
+		entry := releaseNoteEntry{ID: issue.ID, Key: issue.Key, Title: issue.Title, Priority: issue.Priority, Labels: labels[issue.ID]}
+		headings := []string{issueTypeHeadings[issue.Type]}
+		if group == "label" {
+			headings = labels[issue.ID]
+			if len(headings) == 0 {
+				headings = []string{unlabelledHeading}
+			}
+		}
+		for _, h := range headings {
+			byHeading[h] = append(byHeading[h], entry)
+		}
+	}
+
+	sections := make([]releaseNoteSection, 0, len(byHeading))
+	for h, entries := range byHeading {
+		sections = append(sections, releaseNoteSection{Heading: h, Issues: entries})
+	}
+	// Alphabetical, with the catch-all section last
+	sort.Slice(sections, func(i, j int) bool {
+		if (sections[i].Heading == unlabelledHeading) != (sections[j].Heading == unlabelledHeading) {
+			return sections[j].Heading == unlabelledHeading
+		}
+		return sections[i].Heading < sections[j].Heading
+	})
+
+	locale := responseLocale(w)
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		a.encodeJSON(w, r, map[string]interface{}{"release": release, "sections": sections})
+		return
+	}
+
+	var b strings.Builder
+	title := release.Version
+	if release.Name != "" {
+		title += " – " + release.Name
+	}
+	fmt.Fprintf(&b, "# %s\n", title)
+	if release.ReleasedAt != nil {
+		fmt.Fprintf(&b, "\n"+translate(locale, "Released %s")+"\n", formatDate(locale, *release.ReleasedAt))
+	}
+	if len(sections) == 0 {
+		b.WriteString("\n" + translate(locale, "No issues are attached to this release.") + "\n")
+	}
+	for _, s := range sections {
+		heading := s.Heading
+		// Label names are shown as they are; only built-in headings translate
+		if group == "type" || heading == unlabelledHeading {
+			heading = translate(locale, heading)
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", heading)
+		for _, e := range s.Issues {
+			ref := e.Key
+			if ref == "" {
+				ref = fmt.Sprintf("#%d", e.ID)
+			}
+			fmt.Fprintf(&b, "- %s (%s)\n", e.Title, ref)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+)
+
+const dueReminderBatchSize = 100
+
+// Due date reminders: one DueReminderBeforeHours ahead of the due date and
+// one DueReminderAfterHours past it.
+const (
+	reminderDueSoon = "due_soon"
+	reminderOverdue = "overdue"
+)
+
+// runDueReminders sends due date reminders on every tick until ctx is
+// cancelled.
+func (a *App) runDueReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.sendDueReminders(ctx, time.Now()); err != nil {
+				log.Println("Error sending due date reminders:", err)
+			}
+		}
+	}
+}
+
+// sendDueReminders reminds the assignees of open issues coming up to or
+// past their due date. A zero hours setting turns that reminder off.
+func (a *App) sendDueReminders(ctx context.Context, now time.Time) error {
+	settings := a.currentSettings()
+	// The sweep spans organizations; each issue is then handled inside its
+	// own organization by remindAssignee
+	ctx = allOrganizations(ctx)
+	assigned := func() *gorm.DB {
+		return a.openIssues(ctx).Where("assignee <> '' AND due_at IS NOT NULL")
+	}
+
+	if settings.DueReminderBeforeHours > 0 {
+		soon := now.Add(time.Duration(settings.DueReminderBeforeHours) * time.Hour)
+		var upcoming []models.Issue
+		if err := assigned().Where("due_reminder_sent_at IS NULL AND due_at > ? AND due_at <= ?", now, soon).
+			Limit(dueReminderBatchSize).Find(&upcoming).Error; err != nil {
+			return err
+		}
+		for _, issue := range upcoming {
+			if err := a.remindAssignee(withOrganization(ctx, issue.OrganizationID), issue, reminderDueSoon, now); err != nil {
+				log.Printf("Error reminding assignee of issue %d: %s", issue.ID, err)
+			}
+		}
+	}
+
+	if settings.DueReminderAfterHours > 0 {
+		var overdue []models.Issue
+		if err := assigned().Where("overdue_reminder_sent_at IS NULL AND due_at <= ?", now.Add(-time.Duration(settings.DueReminderAfterHours)*time.Hour)).
+			Limit(dueReminderBatchSize).Find(&overdue).Error; err != nil {
+			return err
+		}
+		for _, issue := range overdue {
+			if err := a.remindAssignee(withOrganization(ctx, issue.OrganizationID), issue, reminderOverdue, now); err != nil {
+				log.Printf("Error reminding assignee of overdue issue %d: %s", issue.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// remindAssignee emails an issue's assignee about its due date, in their
+// language. The conditional update lets only one instance send each
+// reminder; assignees without an email address are skipped.
+func (a *App) remindAssignee(ctx context.Context, issue models.Issue, kind string, now time.Time) error {
+	var assignee models.User
+	err := a.dbCtx(ctx).Where("username = ?", issue.Assignee).First(&assignee).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	locale := recipientLocale(a.dbCtx(ctx), assignee, issue.OrganizationID)
+	due := issue.DueAt.UTC()
+	dueAt := formatDate(locale, due) + " " + due.Format("15:04 MST")
+
+	column := "due_reminder_sent_at"
+	left := max(int(math.Ceil(due.Sub(now).Hours())), 1)
+	subject := pluralf(locale, left, "Issue #%[2]d is due in %[1]d hour", "Issue #%[2]d is due in %[1]d hours", issue.ID)
+	if kind == reminderOverdue {
+		column = "overdue_reminder_sent_at"
+		late := max(int(now.Sub(due).Hours()), 1)
+		subject = pluralf(locale, late, "Issue #%[2]d is %[1]d hour overdue", "Issue #%[2]d is %[1]d hours overdue", issue.ID)
+	}
+
+	tx := a.dbCtx(ctx).Begin()
+	err = func() error {
+		// The sent-at columns are not part of the issue's JSON, so this
+		// leaves the version alone and does not fail a client's next update
+		result := tx.Model(&models.Issue{}).Where("id = ? AND "+column+" IS NULL AND due_at = ?", issue.ID, issue.DueAt).Update(column, now)
+		if result.Error != nil || result.RowsAffected == 0 || strings.TrimSpace(assignee.Email) == "" {
+			return result.Error
+		}
+		body := subject + "\n\n" + issue.Title + "\n" + fmt.Sprintf(translate(locale, "Due: %s"), dueAt)
+		_, err := a.queueEmail(tx, assignee.Email, subject, body, systemActor)
+		return err
+	}()
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return err
+}
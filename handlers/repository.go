@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository stores the users, issues and contacts the service layer works
+// with. Missing records are reported as gorm.ErrRecordNotFound; the service
+// layer turns them into its own errors.
+type Repository interface {
+	UserRepository
+	IssueRepository
+	ContactRepository
+}
+
+type UserRepository interface {
+	FindUser(ctx context.Context, id uint) (models.User, error)
+	FindUserByUsername(ctx context.Context, username string) (models.User, error)
+	// CreateUser stores a new account with role in organization orgID.
+	CreateUser(ctx context.Context, user *models.User, orgID uint, role string) error
+}
+
+type IssueRepository interface {
+	// FindIssue loads an issue with its mentions.
+	FindIssue(ctx context.Context, id uint) (models.Issue, error)
+	// ListIssues returns the issues f selects that user may see, newest
+	// first, with their mentions.
+	ListIssues(ctx context.Context, user *models.User, f issueFilter) ([]models.Issue, error)
+	// CreateIssue stores a new issue, numbering it in its project, with its
+	// first history entry, its mentions and a notification for the
+	// assignee.
+	CreateIssue(ctx context.Context, issue *models.Issue, actor string) error
+	// UpdateIssue applies the fields set in u, with dueAt already parsed,
+	// recording each change in the history. It fails with errIssueChanged
+	// if u names a version that is no longer current.
+	UpdateIssue(ctx context.Context, issue *models.Issue, u issueUpdate, dueAt *time.Time, actor string) error
+}
+
+type ContactRepository interface {
+	FindContact(ctx context.Context, id uint) (models.Contact, error)
+	// ListContacts returns contacts newest first, paging backwards from
+	// beforeID when it is set.
+	ListContacts(ctx context.Context, includeSuppressed bool, beforeID uint, limit int) ([]models.Contact, error)
+	// SuppressContacts marks every active contact with email suppressed,
+	// returning how many were.
+	SuppressContacts(ctx context.Context, email, reason, note string) (int64, error)
+}
+
+// gormRepository is the Repository on either database driver. Writes that
+// Postgres guards with row locks are serialized by SQLite's single writer,
+// and GORM leaves the locking clauses out there.
+type gormRepository struct {
+	db *gorm.DB
+	// app records the history, mentions and notifications that go with
+	// the writes.
+	app *App
+}
+
+func (r gormRepository) q(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+func (r gormRepository) FindUser(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	return user, r.q(ctx).First(&user, id).Error
+}
+
+func (r gormRepository) FindUserByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	return user, r.q(ctx).Where("username = ?", username).First(&user).Error
+}
+
+func (r gormRepository) CreateUser(ctx context.Context, user *models.User, orgID uint, role string) error {
+	return r.q(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		_, err := addMember(tx, orgID, user.ID, role)
+		return err
+	})
+}
+
+func (r gormRepository) FindIssue(ctx context.Context, id uint) (models.Issue, error) {
+	var issue models.Issue
+	if err := r.q(ctx).First(&issue, id).Error; err != nil {
+		return issue, err
+	}
+	issues := []models.Issue{issue}
+	err := attachIssueMentions(r.q(ctx), issues)
+	return issues[0], err
+}
+
+func (r gormRepository) ListIssues(ctx context.Context, user *models.User, f issueFilter) ([]models.Issue, error) {
+	var issues []models.Issue
+	if err := issueQuery(r.q(ctx), user, f).Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	return issues, attachIssueMentions(r.q(ctx), issues)
+}
+
+func (r gormRepository) CreateIssue(ctx context.Context, issue *models.Issue, actor string) error {
+	return r.q(ctx).Transaction(func(tx *gorm.DB) error {
+		if issue.ProjectID != nil {
+			var err error
+			if issue.Number, issue.Key, err = claimIssueNumber(tx, *issue.ProjectID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(issue).Error; err != nil {
+			return err
+		}
+		if err := r.app.recordIssueEvent(tx, issue.ID, eventCreated, actor, "", ""); err != nil {
+			return err
+		}
+		mentions, err := recordMentions(tx, issue, nil, actor, issue.Details)
+		if err != nil {
+			return err
+		}
+		issue.Mentions = mentions
+		if issue.Assignee == "" {
+			return nil
+		}
+		return notify(tx, []string{issue.Assignee}, issueNotification(issue, notificationAssigned, actor))
+	})
+}
+
+func (r gormRepository) UpdateIssue(ctx context.Context, issue *models.Issue, u issueUpdate, dueAt *time.Time, actor string) error {
+	updates := map[string]interface{}{}
+	if u.Title != nil {
+		updates["title"] = *u.Title
+	}
+	if u.Details != nil {
+		updates["details"] = *u.Details
+	}
+
+	return r.q(ctx).Transaction(func(tx *gorm.DB) error {
+		// The lock keeps the version from moving before this commits
+		var current models.Issue
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, version").First(&current, issue.ID).Error; err != nil {
+			return err
+		}
+		if u.Version != nil && *u.Version != current.Version {
+			return errIssueChanged
+		}
+		issue.Version = current.Version
+
+		if u.Priority != nil && *u.Priority != issue.Priority {
+			if err := r.app.recordIssueEvent(tx, issue.ID, eventPriorityChanged, actor, strconv.Itoa(issue.Priority), strconv.Itoa(*u.Priority)); err != nil {
+				return err
+			}
+			updates["priority"] = *u.Priority
+		}
+		if u.Assignee != nil && *u.Assignee != issue.Assignee {
+			if err := r.app.recordIssueEvent(tx, issue.ID, eventAssigned, actor, issue.Assignee, *u.Assignee); err != nil {
+				return err
+			}
+			if err := notify(tx, []string{*u.Assignee}, issueNotification(issue, notificationAssigned, actor)); err != nil {
+				return err
+			}
+			updates["assignee"] = *u.Assignee
+		}
+		if u.Component != nil && *u.Component != issue.Component {
+			if err := r.app.recordIssueEvent(tx, issue.ID, eventComponentSet, actor, issue.Component, *u.Component); err != nil {
+				return err
+			}
+			updates["component"] = *u.Component
+		}
+		if u.DueAt != nil && !sameTime(issue.DueAt, dueAt) {
+			if err := r.app.recordIssueEvent(tx, issue.ID, eventDueDateChanged, actor, formatDueAt(issue.DueAt), formatDueAt(dueAt)); err != nil {
+				return err
+			}
+			// A new due date earns new reminders
+			updates["due_at"], updates["due_reminder_sent_at"], updates["overdue_reminder_sent_at"] = dueAt, nil, nil
+		}
+		if u.ProjectID != nil && !sameProject(issue.ProjectID, *u.ProjectID) {
+			updates["project_id"], updates["number"], updates["key"] = nil, 0, ""
+			if *u.ProjectID != 0 {
+				number, key, err := claimIssueNumber(tx, *u.ProjectID)
+				if err != nil {
+					return err
+				}
+				updates["project_id"], updates["number"], updates["key"] = *u.ProjectID, number, key
+			}
+			if err := r.app.recordIssueEvent(tx, issue.ID, eventProjectChanged, actor, issue.Key, updates["key"].(string)); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			updates["version"] = gorm.Expr("version + 1")
+			if err := tx.Model(issue).Updates(updates).Error; err != nil {
+				return err
+			}
+			issue.Version++
+			// Title and details edits are not in the history
+			if err := r.app.notifyLive(tx, liveIssueUpdated, issue.ID, 0); err != nil {
+				return err
+			}
+			if u.Details != nil {
+				mentions, err := recordMentions(tx, issue, nil, actor, *u.Details)
+				if err != nil {
+					return err
+				}
+				issue.Mentions = mentions
+			}
+		}
+		if u.State != nil {
+			return r.app.setIssueState(tx, issue, *u.State, actor)
+		}
+		return nil
+	})
+}
+
+func (r gormRepository) FindContact(ctx context.Context, id uint) (models.Contact, error) {
+	var contact models.Contact
+	return contact, r.q(ctx).First(&contact, id).Error
+}
+
+func (r gormRepository) ListContacts(ctx context.Context, includeSuppressed bool, beforeID uint, limit int) ([]models.Contact, error) {
+	q := r.q(ctx).Order("id desc").Limit(limit)
+	if !includeSuppressed {
+		q = q.Where("suppressed = ?", false)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var contacts []models.Contact
+	return contacts, q.Find(&contacts).Error
+}
+
+func (r gormRepository) SuppressContacts(ctx context.Context, email, reason, note string) (int64, error) {
+	now := time.Now()
+	result := r.q(ctx).Model(&models.Contact{}).Where("lower(email) = lower(?) AND suppressed = ?", email, false).Updates(map[string]interface{}{
+		"suppressed":         true,
+		"suppressed_at":      now,
+		"suppression_reason": reason,
+		"suppression_note":   note,
+	})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"form/config"
+	"form/models"
+	"form/storage"
+
+	"gorm.io/gorm"
+)
+
+// newTestApp builds the service on a new SQLite database that lasts as
+// long as the test.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	c := config.Config{
+		DatabaseDriver: config.DriverSQLite,
+		DatabaseURL:    filepath.Join(t.TempDir(), "form.db"),
+		PublicURL:      "http://form.test",
+		SigningKey:     []byte("test signing key"),
+		TokenTTL:       time.Hour,
+	}
+	database, err := storage.Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := database.DB()
+		sqlDB.Close()
+	})
+	if err := storage.CreateSQLiteSchema(database); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(c, database, storage.DiskStorage(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestRepositoryUsers(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+
+	user := models.User{Username: "ana", Password: "password1"}
+	if err := a.repo.CreateUser(ctx, &user, models.DefaultOrganizationID, orgRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	found, err := a.repo.FindUserByUsername(ctx, "ana")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("FindUserByUsername = %+v, %v; want ID %d", found, err, user.ID)
+	}
+	if found, err = a.repo.FindUser(ctx, user.ID); err != nil || found.Username != "ana" {
+		t.Fatalf("FindUser = %+v, %v", found, err)
+	}
+	if _, err := a.repo.FindUserByUsername(ctx, "nobody"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing user: err = %v, want ErrRecordNotFound", err)
+	}
+
+	member, err := a.organizationMember(withOrganization(ctx, models.DefaultOrganizationID), "ana")
+	if err != nil || member.MembershipRole != orgRoleMember {
+		t.Fatalf("organizationMember = %+v, %v; want a member", member, err)
+	}
+}
+
+func TestRepositoryIssues(t *testing.T) {
+	a := newTestApp(t)
+	ctx := withOrganization(context.Background(), models.DefaultOrganizationID)
+
+	ana := models.User{Username: "ana", Password: "password1"}
+	bo := models.User{Username: "bo", Password: "password1"}
+	for _, u := range []*models.User{&ana, &bo} {
+		if err := a.repo.CreateUser(ctx, u, models.DefaultOrganizationID, orgRoleMember); err != nil {
+			t.Fatal(err)
+		}
+		u.MembershipRole = orgRoleMember
+	}
+
+	mine := models.Issue{Title: "Login broken", Details: "cc @bo and @nobody", ReportedBy: "ana", State: stateOpen}
+	if err := a.repo.CreateIssue(ctx, &mine, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	theirs := models.Issue{Title: "Slow search", ReportedBy: "bo", State: stateOpen}
+	if err := a.repo.CreateIssue(ctx, &theirs, "bo"); err != nil {
+		t.Fatal(err)
+	}
+	if mine.OrganizationID != models.DefaultOrganizationID {
+		t.Errorf("OrganizationID = %d, want %d", mine.OrganizationID, models.DefaultOrganizationID)
+	}
+
+	found, err := a.repo.FindIssue(ctx, mine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Mentions) != 1 || found.Mentions[0] != "bo" {
+		t.Errorf("Mentions = %v, want [bo]", found.Mentions)
+	}
+
+	// Members only list what they reported or are assigned
+	list, err := a.repo.ListIssues(ctx, &ana, issueFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != mine.ID {
+		t.Errorf("ana sees %d issues, want only #%d", len(list), mine.ID)
+	}
+
+	priority := 3
+	version := found.Version
+	if err := a.repo.UpdateIssue(ctx, &found, issueUpdate{Priority: &priority, Version: &version}, nil, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if found.Version != version+1 {
+		t.Errorf("Version = %d, want %d", found.Version, version+1)
+	}
+	stale := models.Issue{Model: gorm.Model{ID: mine.ID}}
+	if err := a.repo.UpdateIssue(ctx, &stale, issueUpdate{Priority: &priority, Version: &version}, nil, "ana"); !errors.Is(err, errIssueChanged) {
+		t.Errorf("stale update: err = %v, want errIssueChanged", err)
+	}
+
+	// Another organization's issues are out of reach
+	other := withOrganization(context.Background(), models.DefaultOrganizationID+1)
+	if _, err := a.repo.FindIssue(other, mine.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("other organization: err = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestRepositoryContacts(t *testing.T) {
+	a := newTestApp(t)
+	ctx := withOrganization(context.Background(), models.DefaultOrganizationID)
+
+	contacts := []models.Contact{
+		{Email: "Ann@Example.com", FullName: "Ann"},
+		{Email: "bo@example.com", FullName: "Bo"},
+	}
+	if err := a.db.WithContext(ctx).Create(&contacts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := a.repo.SuppressContacts(ctx, "ann@example.com", suppressedByRequest, "")
+	if err != nil || n != 1 {
+		t.Fatalf("SuppressContacts = %d, %v; want 1", n, err)
+	}
+	active, err := a.repo.ListContacts(ctx, false, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != contacts[1].ID {
+		t.Errorf("active contacts = %+v, want only %d", active, contacts[1].ID)
+	}
+	all, err := a.repo.ListContacts(ctx, true, 0, 10)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("all contacts = %d, %v; want 2", len(all), err)
+	}
+	if all[0].ID != contacts[1].ID {
+		t.Errorf("contacts are not newest first: %d before %d", all[0].ID, all[1].ID)
+	}
+
+	found, err := a.repo.FindContact(ctx, contacts[0].ID)
+	if err != nil || !found.Suppressed || found.SuppressionReason != suppressedByRequest {
+		t.Errorf("FindContact = %+v, %v; want suppressed by request", found, err)
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"form/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of archived record.
+const (
+	archivedIssue   = "issue"
+	archivedContact = "contact"
+)
+
+const (
+	retentionBatchSize  = 100
+	retentionReportSize = 100
+)
+
+// archivedRecordView is an ArchivedRecord with its data inlined.
+type archivedRecordView struct {
+	models.ArchivedRecord
+	Data json.RawMessage `json:"data"`
+}
+
+// retentionPolicy is how long closed issues and inactive contacts are kept
+// before they are archived. A zero duration keeps them forever.
+type retentionPolicy struct {
+	closedIssues     time.Duration
+	inactiveContacts time.Duration
+}
+
+func (a *App) currentRetentionPolicy() retentionPolicy {
+	s := a.currentSettings()
+	return retentionPolicy{
+		closedIssues:     time.Duration(s.RetainClosedIssuesDays) * 24 * time.Hour,
+		inactiveContacts: time.Duration(s.RetainInactiveContactsDays) * 24 * time.Hour,
+	}
+}
+
+// expiredIssues scopes a query to the closed issues due for archiving, or
+// returns nil if the policy keeps them. Any change to an issue restarts its
+// clock, since it touches updated_at. The result can be reused.
+func (p retentionPolicy) expiredIssues(q *gorm.DB, now time.Time) *gorm.DB {
+	if p.closedIssues <= 0 {
+		return nil
+	}
+	return q.Model(&models.Issue{}).Where("state = ? AND updated_at < ?", stateClosed, now.Add(-p.closedIssues)).Session(&gorm.Session{})
+}
+
+// expiredContacts scopes a query to the contacts due for archiving, or
+// returns nil if the policy keeps them. Suppressed contacts are kept for
+// good: imports rely on them to keep opted-out addresses out. The result can
+// be reused.
+func (p retentionPolicy) expiredContacts(q *gorm.DB, now time.Time) *gorm.DB {
+	if p.inactiveContacts <= 0 {
+		return nil
+	}
+	return q.Model(&models.Contact{}).Where("suppressed = ? AND last_active_at < ?", false, now.Add(-p.inactiveContacts)).Session(&gorm.Session{})
+}
+
+// runRetention applies the retention policy on every tick until ctx is
+// cancelled.
+func (a *App) runRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// The sweep spans organizations; each record is handled inside its own
+			result, err := a.applyRetention(allOrganizations(ctx), time.Now(), systemActor)
+			if err != nil {
+				log.Println("Error applying retention policy:", err)
+			}
+			if result.Issues+result.Contacts+result.Failed > 0 {
+				log.Printf("Retention sweep archived %d issues and %d contacts, %d failed", result.Issues, result.Contacts, result.Failed)
+			}
+		}
+	}
+}
+
+type retentionResult struct {
+	Issues   int `json:"issues"`
+	Contacts int `json:"contacts"`
+	Failed   int `json:"failed"`
+}
+
+// applyRetention archives everything the policy says is due in ctx's
+// organization, or in all of them. A record that fails is logged and
+// left for the next sweep.
+func (a *App) applyRetention(ctx context.Context, now time.Time, actor string) (retentionResult, error) {
+	var result retentionResult
+	policy := a.currentRetentionPolicy()
+
+	if q := policy.expiredIssues(a.dbCtx(ctx), now); q != nil {
+		for after := uint(0); ; {
+			var batch []models.Issue
+			if err := q.Where("id > ?", after).Order("id").Limit(retentionBatchSize).Find(&batch).Error; err != nil {
+				return result, err
+			}
+			for _, issue := range batch {
+				after = issue.ID
+				archived, err := a.archiveIssue(withOrganization(ctx, issue.OrganizationID), issue.ID, policy, now, actor)
+				if err != nil {
+					log.Printf("Error archiving issue %d: %s", issue.ID, err)
+					result.Failed++
+				} else if archived {
+					result.Issues++
+				}
+			}
+			if len(batch) < retentionBatchSize {
+				break
+			}
+		}
+	}
+
+	if q := policy.expiredContacts(a.dbCtx(ctx), now); q != nil {
+		for after := uint(0); ; {
+			var batch []models.Contact
+			if err := q.Where("id > ?", after).Order("id").Limit(retentionBatchSize).Find(&batch).Error; err != nil {
+				return result, err
+			}
+			for _, contact := range batch {
+				after = contact.ID
+				archived, err := a.archiveContact(withOrganization(ctx, contact.OrganizationID), contact.ID, policy, now, actor)
+				if err != nil {
+					log.Printf("Error archiving contact %d: %s", contact.ID, err)
+					result.Failed++
+				} else if archived {
+					result.Contacts++
+				}
+			}
+			if len(batch) < retentionBatchSize {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// archiveIssue moves an issue, its comments, history and labels into the
+// archive. It does nothing if the issue stopped being due since it was
+// listed, for example because it was reopened.
+func (a *App) archiveIssue(ctx context.Context, id uint, policy retentionPolicy, now time.Time, actor string) (bool, error) {
+	tx := a.dbCtx(ctx).Begin()
+	archived, err := func() (bool, error) {
+		var issue models.Issue
+		err := policy.expiredIssues(tx, now).Clauses(clause.Locking{Strength: "UPDATE"}).First(&issue, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		var comments []models.Comment
+		if err := tx.Unscoped().Where("issue_id = ?", id).Order("id").Find(&comments).Error; err != nil {
+			return false, err
+		}
+		var events []models.IssueEvent
+		if err := tx.Where("issue_id = ?", id).Order("id").Find(&events).Error; err != nil {
+			return false, err
+		}
+		labels, err := issueLabelNames(tx, id)
+		if err != nil {
+			return false, err
+		}
+		data, err := json.Marshal(map[string]interface{}{"issue": issue, "comments": comments, "events": events, "labels": labels})
+		if err != nil {
+			return false, err
+		}
+		if err := tx.Create(&models.ArchivedRecord{Kind: archivedIssue, RecordID: id, Data: string(data), ArchivedAt: now}).Error; err != nil {
+			return false, err
+		}
+
+		if err := tx.Where("issue_id = ?", id).Delete(&models.IssueLabel{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Unscoped().Where("issue_id = ?", id).Delete(&models.Comment{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Where("issue_id = ?", id).Delete(&models.IssueEvent{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Where("issue_id = ?", id).Delete(&models.Notification{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Where("issue_id = ?", id).Delete(&models.Mention{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Unscoped().Delete(&issue).Error; err != nil {
+			return false, err
+		}
+		if err := recordAudit(tx, actor, "issue.archived", archivedIssue, id, map[string]interface{}{"comments": len(comments), "events": len(events)}); err != nil {
+			return false, err
+		}
+		return true, a.notifyLive(tx, liveIssueArchived, id, 0)
+	}()
+	if err == nil && archived {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return archived && err == nil, err
+}
+
+// archiveContact moves a contact into the archive unless it stopped being
+// due since it was listed.
+func (a *App) archiveContact(ctx context.Context, id uint, policy retentionPolicy, now time.Time, actor string) (bool, error) {
+	tx := a.dbCtx(ctx).Begin()
+	archived, err := func() (bool, error) {
+		var contact models.Contact
+		err := policy.expiredContacts(tx, now).Clauses(clause.Locking{Strength: "UPDATE"}).First(&contact, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		links, err := contactLinks(tx, id)
+		if err != nil {
+			return false, err
+		}
+		data, err := json.Marshal(map[string]interface{}{"contact": contact, "links": links})
+		if err != nil {
+			return false, err
+		}
+		if err := tx.Create(&models.ArchivedRecord{Kind: archivedContact, RecordID: id, Data: string(data), ArchivedAt: now}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Where("contact_id = ?", id).Delete(&models.ContactLink{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Delete(&contact).Error; err != nil {
+			return false, err
+		}
+		return true, recordAudit(tx, actor, "contact.archived", archivedContact, id, nil)
+	}()
+	if err == nil && archived {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	return archived && err == nil, err
+}
+
+type retentionCandidates struct {
+	AfterDays int    `json:"afterDays"`
+	Count     int64  `json:"count"`
+	IDs       []uint `json:"ids"`
+}
+
+type retentionReport struct {
+	Issues   retentionCandidates `json:"issues"`
+	Contacts retentionCandidates `json:"contacts"`
+}
+
+// retentionCandidatesFor counts what q selects and lists the oldest IDs.
+func retentionCandidatesFor(q *gorm.DB, days int) (retentionCandidates, error) {
+	c := retentionCandidates{AfterDays: days, IDs: []uint{}}
+	if q == nil {
+		return c, nil
+	}
+	if err := q.Count(&c.Count).Error; err != nil {
+		return c, err
+	}
+	err := q.Order("id").Limit(retentionReportSize).Pluck("id", &c.IDs).Error
+	return c, err
+}
+
+// retentionReportHandler is a dry run of the retention sweep: it reports
+// what would be archived now without changing anything.
+func (a *App) retentionReportHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	policy := a.currentRetentionPolicy()
+	settings := a.currentSettings()
+
+	var report retentionReport
+	var err error
+	report.Issues, err = retentionCandidatesFor(policy.expiredIssues(a.dbCtx(r.Context()), now), settings.RetainClosedIssuesDays)
+	if err == nil {
+		report.Contacts, err = retentionCandidatesFor(policy.expiredContacts(a.dbCtx(r.Context()), now), settings.RetainInactiveContactsDays)
+	}
+	if err != nil {
+		log.Println("Error building retention report:", err)
+		writeError(w, http.StatusInternalServerError, "Error building retention report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, report)
+}
+
+// runRetentionHandler runs the retention sweep for the caller's
+// organization now rather than waiting for the next scheduled one.
+func (a *App) runRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := a.applyRetention(r.Context(), time.Now(), actorName(r))
+	if err != nil {
+		log.Println("Error applying retention policy:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to apply retention policy")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, result)
+}
+
+// listArchiveHandler pages backwards through archived records, optionally
+// only those of one kind.
+func (a *App) listArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q := a.dbCtx(r.Context()).Order("id desc").Limit(limit)
+	switch kind := query.Get("kind"); kind {
+	case "":
+	case archivedIssue, archivedContact, archivedMergedContact:
+		q = q.Where("kind = ?", kind)
+	default:
+		writeError(w, http.StatusBadRequest, "kind must be issue, contact or merged_contact")
+		return
+	}
+	if before, _ := strconv.ParseUint(query.Get("before"), 10, 64); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+
+	var records []models.ArchivedRecord
+	if err := q.Find(&records).Error; err != nil {
+		writeDBError(w, err, "Error loading archive")
+		return
+	}
+	views := make([]archivedRecordView, len(records))
+	for i, rec := range records {
+		views[i] = archivedRecordView{ArchivedRecord: rec, Data: json.RawMessage(rec.Data)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, views)
+}
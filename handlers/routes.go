@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+)
+
+// Handler builds the routes and API docs and wraps them in the middleware
+// every request goes through.
+func (a *App) Handler() (http.Handler, error) {
+	r := a.newRouter()
+	spec, err := a.buildOpenAPISpec(r)
+	if err != nil {
+		return nil, fmt.Errorf("building OpenAPI spec: %w", err)
+	}
+	a.openAPISpec = spec
+	return requestIDMiddleware(localeMiddleware(a.corsMiddleware(r))), nil
+}
+
+// Start sets up email and the admin account, then runs the background work
+// until ctx is cancelled: settings reloads, import polling, the auto-close
+// and retention sweeps, digests, due date reminders, live events and the
+// job workers. Work requests start in the background runs under ctx too.
+func (a *App) Start(ctx context.Context) error {
+	a.ctx = ctx
+	// Send queued email through the configured provider
+	if err := a.setupEmail(ctx); err != nil {
+		return fmt.Errorf("setting up email: %w", err)
+	}
+	if err := a.createAdmin(); err != nil {
+		return err
+	}
+
+	a.goBackground(ctx, func(ctx context.Context) { a.watchSettings(ctx, a.cfg.SettingsReloadInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.pollImportSources(ctx, a.cfg.ImportPollInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.runAutoClose(ctx, a.cfg.AutoCloseInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.runRetention(ctx, a.cfg.RetentionInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.runDigests(ctx, a.cfg.DigestInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.runDueReminders(ctx, a.cfg.DueReminderInterval) })
+	a.goBackground(ctx, func(ctx context.Context) { a.pruneInboundNonces(ctx, time.Hour) })
+	a.goBackground(ctx, a.listenLive)
+	// Run queued background work: email, webhook deliveries
+	a.goBackground(ctx, func(ctx context.Context) { a.runJobWorkers(ctx, a.cfg.JobWorkers, a.cfg.JobPollInterval) })
+	return nil
+}
+
+// Drain marks the service as shutting down, so readiness checks fail while
+// in-flight requests finish.
+func (a *App) Drain() {
+	a.shuttingDown.Store(true)
+}
+
+// Wait blocks until the background work Start ran has returned, once its
+// context is cancelled.
+func (a *App) Wait() {
+	a.background.Wait()
+}
+
+// newRouter registers every REST route.
+func (a *App) newRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not found")
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+	r.Use(otelmux.Middleware("form"))
+	r.Use(gzipMiddleware)
+	r.Use(a.authMiddleware)
+	r.Use(a.organizationMiddleware)
+	r.Use(a.timeoutMiddleware)
+	r.Use(a.bodyLimitMiddleware)
+
+	// Define routes
+	r.HandleFunc("/healthz", a.healthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", a.readyzHandler).Methods("GET")
+	r.HandleFunc("/register", a.registerHandler).Methods("POST")
+	r.HandleFunc("/login", a.loginHandler).Methods("POST")
+	r.HandleFunc(csvUploadRoute, a.uploadCSVHandler).Methods("POST")
+	r.HandleFunc("/login-by-email", a.loginByEmailHandler).Methods("POST")
+	r.HandleFunc("/me", requireAuth(a.getProfileHandler)).Methods("GET")
+	r.HandleFunc("/me", requireAuth(a.updateProfileHandler)).Methods("PUT")
+	r.HandleFunc("/me/change-password", requireAuth(a.changePasswordHandler)).Methods("POST")
+	r.HandleFunc("/report-issue", a.reportIssueHandler).Methods("POST") // Changed the endpoint to /report-issue
+	r.HandleFunc("/issues", requireAuth(a.listIssuesHandler)).Methods("GET")
+	r.HandleFunc("/issues/{id:[0-9]+}", requireAuth(a.getIssueByIDHandler)).Methods("GET")
+	r.HandleFunc("/issues/{key:[A-Za-z][A-Za-z0-9]*-[0-9]+}", requireAuth(a.getIssueByKeyHandler)).Methods("GET")
+	r.HandleFunc("/issues/{id:[0-9]+}", requireAuth(a.updateIssueHandler)).Methods("PATCH")
+	r.HandleFunc("/issues/{id:[0-9]+}/reopen", requireAuth(a.reopenIssueHandler)).Methods("POST")
+	r.HandleFunc("/issues/{id:[0-9]+}/history", requireAuth(a.issueHistoryHandler)).Methods("GET")
+	r.HandleFunc("/issues/{id:[0-9]+}/comments", requireAuth(a.listCommentsHandler)).Methods("GET")
+	r.HandleFunc("/issues/{id:[0-9]+}/comments", requireAuth(a.createCommentHandler)).Methods("POST")
+	r.HandleFunc("/issues/{id:[0-9]+}/labels", requireAuth(a.setIssueLabelsHandler)).Methods("PUT")
+	r.HandleFunc("/labels", requireAuth(a.listLabelsHandler)).Methods("GET")
+	r.HandleFunc("/notifications", requireAuth(a.listNotificationsHandler)).Methods("GET")
+	r.HandleFunc("/notifications/unread-count", requireAuth(a.unreadNotificationsHandler)).Methods("GET")
+	r.HandleFunc("/notifications/read-all", requireAuth(a.readAllNotificationsHandler)).Methods("POST")
+	r.HandleFunc("/notifications/{id:[0-9]+}/read", requireAuth(a.readNotificationHandler)).Methods("POST")
+	r.HandleFunc("/admin/labels/{id:[0-9]+}", requireAdmin(a.renameLabelHandler)).Methods("PUT")
+	r.HandleFunc("/admin/labels/{id:[0-9]+}/merge", requireAdmin(a.mergeLabelHandler)).Methods("POST")
+	r.HandleFunc("/projects", requireAuth(a.listProjectsHandler)).Methods("GET")
+	r.HandleFunc("/projects/{project}", requireAuth(a.getProjectHandler)).Methods("GET")
+	r.HandleFunc("/projects/{project}/issues", requireAuth(a.listIssuesHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects", requireAdmin(a.createProjectHandler)).Methods("POST")
+	r.HandleFunc("/admin/projects/{id:[0-9]+}", requireAdmin(a.updateProjectHandler)).Methods("PATCH")
+	r.HandleFunc("/admin/projects/{id:[0-9]+}", requireAdmin(a.deleteProjectHandler)).Methods("DELETE")
+	r.HandleFunc("/releases", requireAuth(a.listReleasesHandler)).Methods("GET")
+	r.HandleFunc("/releases/{id:[0-9]+}/notes", requireAuth(a.releaseNotesHandler)).Methods("GET")
+	r.HandleFunc("/admin/releases", requireAdmin(a.createReleaseHandler)).Methods("POST")
+	r.HandleFunc("/admin/releases/{id:[0-9]+}/issues", requireAdmin(a.attachReleaseIssuesHandler)).Methods("POST")
+	r.HandleFunc("/dashboard", requireAuth(a.dashboardHandler)).Methods("GET")
+	r.HandleFunc("/digests", requireAuth(a.listDigestsHandler)).Methods("GET")
+	r.HandleFunc("/digests", requireAuth(a.createDigestHandler)).Methods("POST")
+	r.HandleFunc("/digests/{id:[0-9]+}", requireAuth(a.updateDigestHandler)).Methods("PATCH")
+	r.HandleFunc("/digests/{id:[0-9]+}", requireAuth(a.deleteDigestHandler)).Methods("DELETE")
+	r.HandleFunc("/digests/{id:[0-9]+}/preview", requireAuth(a.previewDigestHandler)).Methods("GET")
+	r.HandleFunc("/events", a.eventsHandler).Methods("GET")
+	r.HandleFunc("/unsubscribe", a.unsubscribeHandler).Methods("GET", "POST")
+	r.HandleFunc("/contacts/duplicates", requireAdmin(a.listDuplicateContactsHandler)).Methods("GET")
+	r.HandleFunc("/contacts/merge", requireAdmin(a.mergeContactsHandler)).Methods("POST")
+	r.HandleFunc("/contacts/{id:[0-9]+}", requireAdmin(a.getContactHandler)).Methods("GET")
+	r.HandleFunc("/contacts/{id:[0-9]+}/unsubscribe-link", requireAdmin(a.contactUnsubscribeLinkHandler)).Methods("GET")
+	r.HandleFunc("/corrections/{type}/{id:[0-9]+}", a.getCorrectionSubjectHandler).Methods("GET")
+	r.HandleFunc("/corrections/{type}/{id:[0-9]+}", a.submitCorrectionHandler).Methods("POST")
+	r.HandleFunc("/admin/correction-links", requireAdmin(a.correctionLinkHandler)).Methods("GET")
+	r.HandleFunc("/admin/corrections", requireAdmin(a.listCorrectionsHandler)).Methods("GET")
+	r.HandleFunc("/admin/corrections/{id:[0-9]+}/{action:apply|reject}", requireAdmin(a.reviewCorrectionHandler)).Methods("POST")
+	r.HandleFunc("/admin/metrics/reopens", requireAdmin(a.reopenMetricsHandler)).Methods("GET")
+	r.HandleFunc("/stats/issues", requireAdmin(a.issueStatsHandler)).Methods("GET")
+	r.HandleFunc("/stats/imports", requireAdmin(a.importStatsHandler)).Methods("GET")
+	r.HandleFunc("/admin/retention", requireAdmin(a.retentionReportHandler)).Methods("GET")
+	r.HandleFunc("/admin/retention/run", requireAdmin(a.runRetentionHandler)).Methods("POST")
+	r.HandleFunc("/admin/archive", requireAdmin(a.listArchiveHandler)).Methods("GET")
+	r.HandleFunc("/debug/config", requireOperator(a.debugConfigHandler)).Methods("GET")
+	// Runtime profiles and counters, for chasing memory use in production
+	r.HandleFunc("/debug/pprof/", a.requireDebugAccess(pprof.Index)).Methods("GET")
+	r.HandleFunc("/debug/pprof/cmdline", a.requireDebugAccess(pprof.Cmdline)).Methods("GET")
+	r.HandleFunc("/debug/pprof/profile", a.requireDebugAccess(pprof.Profile)).Methods("GET")
+	r.HandleFunc("/debug/pprof/symbol", a.requireDebugAccess(pprof.Symbol)).Methods("GET", "POST")
+	r.HandleFunc("/debug/pprof/trace", a.requireDebugAccess(pprof.Trace)).Methods("GET")
+	r.HandleFunc("/debug/pprof/{profile}", a.requireDebugAccess(pprof.Index)).Methods("GET")
+	r.HandleFunc("/debug/vars", a.requireDebugAccess(expvar.Handler().ServeHTTP)).Methods("GET")
+	r.HandleFunc("/admin/audit", requireAdmin(a.listAuditHandler)).Methods("GET")
+	r.HandleFunc("/admin/suppressions", requireAdmin(a.suppressionReportHandler)).Methods("GET")
+	r.HandleFunc("/admin/suppressions", requireAdmin(a.createSuppressionHandler)).Methods("POST")
+	r.HandleFunc("/admin/suppressions/{id:[0-9]+}", requireAdmin(a.deleteSuppressionHandler)).Methods("DELETE")
+	r.HandleFunc("/issues/{id:[0-9]+}/attachment-url", requireAuth(a.issueAttachmentURLHandler)).Methods("GET")
+	r.HandleFunc("/issues/{id:[0-9]+}/image", requireAuth(a.uploadIssueImageHandler)).Methods("POST")
+	r.HandleFunc("/admin/settings", requireOperator(a.listSettingsHandler)).Methods("GET")
+	r.HandleFunc("/admin/settings/{key}", requireOperator(a.getSettingHandler)).Methods("GET")
+	r.HandleFunc("/admin/settings/{key}", requireOperator(a.updateSettingHandler)).Methods("PUT")
+	r.HandleFunc("/admin/settings/{key}", requireOperator(a.deleteSettingHandler)).Methods("DELETE")
+	r.HandleFunc("/admin/exports", requireAdmin(a.listExportsHandler)).Methods("GET")
+	r.HandleFunc("/admin/exports/profiles", requireAdmin(a.listRedactionProfilesHandler)).Methods("GET")
+	r.HandleFunc("/admin/exports/{dataset}", requireAdmin(a.exportHandler)).Methods("GET")
+	r.HandleFunc("/imports", requireAdmin(a.listImportsHandler)).Methods("GET")
+	r.HandleFunc("/imports", requireAdmin(a.createImportHandler)).Methods("POST")
+	r.HandleFunc("/imports/{id:[0-9]+}", requireAdmin(a.getImportHandler)).Methods("GET")
+	r.HandleFunc("/imports/{id:[0-9]+}/abort", requireAdmin(a.abortImportHandler)).Methods("POST")
+	r.HandleFunc("/import-sources", requireAdmin(a.listImportSourcesHandler)).Methods("GET")
+	r.HandleFunc("/import-sources", requireAdmin(a.createImportSourceHandler)).Methods("POST")
+	r.HandleFunc("/import-sources/{id:[0-9]+}", requireAdmin(a.deleteImportSourceHandler)).Methods("DELETE")
+	r.HandleFunc("/import-sources/{id:[0-9]+}/run", requireAdmin(a.runImportSourceHandler)).Methods("POST")
+	r.HandleFunc("/bi/{entity}", requireAdmin(a.biExportHandler)).Methods("GET")
+	r.HandleFunc("/admin/jobs", requireOperator(a.listJobsHandler)).Methods("GET")
+	r.HandleFunc("/admin/jobs/summary", requireOperator(a.jobSummaryHandler)).Methods("GET")
+	r.HandleFunc("/admin/jobs/{id:[0-9]+}", requireOperator(a.getJobHandler)).Methods("GET")
+	r.HandleFunc("/admin/jobs/{id:[0-9]+}/retry", requireOperator(a.retryJobHandler)).Methods("POST")
+	r.HandleFunc("/admin/emails", requireOperator(a.listEmailsHandler)).Methods("GET")
+	r.HandleFunc("/admin/emails", requireOperator(a.sendEmailHandler)).Methods("POST")
+	r.HandleFunc("/admin/users", requireOperator(a.listUsersHandler)).Methods("GET")
+	r.HandleFunc("/admin/users/{id:[0-9]+}", requireOperator(a.getUserHandler)).Methods("GET")
+	r.HandleFunc("/admin/users/{id:[0-9]+}", requireOperator(a.deleteUserHandler)).Methods("DELETE")
+	r.HandleFunc("/admin/users/{id:[0-9]+}/role", requireOperator(a.updateUserRoleHandler)).Methods("PUT")
+	r.HandleFunc("/admin/users/{id:[0-9]+}/disable", requireOperator(a.setUserDisabledHandler(true))).Methods("POST")
+	r.HandleFunc("/admin/users/{id:[0-9]+}/enable", requireOperator(a.setUserDisabledHandler(false))).Methods("POST")
+	r.HandleFunc("/admin/users/{id:[0-9]+}/password-reset", requireOperator(a.forcePasswordResetHandler)).Methods("POST")
+	r.HandleFunc("/password-reset/{id:[0-9]+}", a.completePasswordResetHandler).Methods("POST")
+	r.HandleFunc("/organizations", requireAuth(a.listOrganizationsHandler)).Methods("GET")
+	r.HandleFunc("/admin/organizations", requireOperator(a.createOrganizationHandler)).Methods("POST")
+	r.HandleFunc("/admin/organization", requireAdmin(a.updateOrganizationHandler)).Methods("PATCH")
+	r.HandleFunc("/admin/members", requireAdmin(a.listMembersHandler)).Methods("GET")
+	r.HandleFunc("/admin/members", requireAdmin(a.addMemberHandler)).Methods("POST")
+	r.HandleFunc("/admin/members/{userId:[0-9]+}", requireAdmin(a.removeMemberHandler)).Methods("DELETE")
+	r.HandleFunc("/webhooks/ses", a.inboundWebhook(sesInbound, a.sesWebhookHandler)).Methods("POST")
+	r.HandleFunc("/webhooks/mailgun", a.inboundWebhook(mailgunInbound, a.mailgunWebhookHandler)).Methods("POST")
+	r.HandleFunc("/webhooks/github", a.inboundWebhook(githubInbound, a.githubWebhookHandler)).Methods("POST")
+	r.HandleFunc("/webhooks/sentry", a.inboundWebhook(sentryInbound, a.sentryWebhookHandler)).Methods("POST")
+	r.HandleFunc("/admin/webhooks", requireAdmin(a.listWebhooksHandler)).Methods("GET")
+	r.HandleFunc("/admin/webhooks", requireAdmin(a.createWebhookHandler)).Methods("POST")
+	r.HandleFunc("/admin/webhooks/{id:[0-9]+}", requireAdmin(a.deleteWebhookHandler)).Methods("DELETE")
+	r.HandleFunc("/admin/webhooks/{id:[0-9]+}/test", requireAdmin(a.testWebhookHandler)).Methods("POST")
+
+	// Serve uploaded images through short-lived signed URLs
+	r.HandleFunc(uploadsPrefix+"{name}", a.serveUploadHandler).Methods("GET", "HEAD")
+	r.HandleFunc(uploadsPrefix+"{org:[0-9]+}/{name}", a.serveUploadHandler).Methods("GET", "HEAD")
+
+	// GraphQL for nested issue queries in one round trip
+	r.HandleFunc("/graphql", requireAuth(a.newGraphQLHandler().ServeHTTP)).Methods("POST")
+
+	// API docs, generated from the routes above
+	r.HandleFunc("/openapi.json", a.openAPIHandler).Methods("GET")
+	r.HandleFunc("/docs", docsHandler).Methods("GET")
+	return r
+}
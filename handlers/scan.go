@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"form/config"
+)
+
+// ScanResult is the verdict a Scanner returns for one file.
+type ScanResult struct {
+	Clean  bool   `json:"clean"`
+	Threat string `json:"threat,omitempty"`
+}
+
+// Scanner inspects uploaded content before it is stored or processed.
+type Scanner interface {
+	Scan(ctx context.Context, name string, content []byte) (ScanResult, error)
+}
+
+// newScanner builds the scanner selected by UPLOAD_SCANNER.
+func newScanner(c config.Config) (Scanner, error) {
+	switch c.UploadScanner {
+	case "", "none":
+		return noopScanner{}, nil
+	case "clamav":
+		network, address := "tcp", c.ClamAVAddress
+		if strings.HasPrefix(address, "unix:") {
+			network, address = "unix", strings.TrimPrefix(address, "unix:")
+		}
+		return clamAVScanner{network: network, address: address}, nil
+	case "http":
+		if c.ScannerURL == "" {
+			return nil, errors.New("SCANNER_URL is required for the http scanner")
+		}
+		return httpScanner{url: c.ScannerURL, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unknown upload scanner %q", c.UploadScanner)
+}
+
+type noopScanner struct{}
+
+func (noopScanner) Scan(context.Context, string, []byte) (ScanResult, error) {
+	return ScanResult{Clean: true}, nil
+}
+
+// clamAVScanner streams content to clamd using the INSTREAM command.
+type clamAVScanner struct {
+	network string
+	address string
+}
+
+func (s clamAVScanner) Scan(ctx context.Context, name string, content []byte) (ScanResult, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	const chunkSize = 64 << 10
+	for len(content) > 0 {
+		n := len(content)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		binary.Write(w, binary.BigEndian, uint32(n))
+		w.Write(content[:n])
+		content = content[n:]
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return ScanResult{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return ScanResult{}, err
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND"
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return ScanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		threat := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return ScanResult{Threat: threat}, nil
+	}
+	return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+}
+
+// httpScanner posts content to a scanning service that answers with a
+// JSON ScanResult.
+type httpScanner struct {
+	url    string
+	client *http.Client
+}
+
+func (s httpScanner) Scan(ctx context.Context, name string, content []byte) (ScanResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(content))
+	if err != nil {
+		return ScanResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Filename", name)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ScanResult{}, fmt.Errorf("scanner returned %s", resp.Status)
+	}
+
+	var result ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ScanResult{}, err
+	}
+	return result, nil
+}
+
+var errUploadRejected = errors.New("upload rejected by scanner")
+
+// scanUpload runs the configured scanner over an upload. Flagged files are
+// rejected and, in quarantine mode, kept aside for review.
+func (a *App) scanUpload(ctx context.Context, name string, content []byte) error {
+	result, err := a.scanner.Scan(ctx, name, content)
+	if err != nil {
+		return fmt.Errorf("scanning %s: %w", name, err)
+	}
+	if result.Clean {
+		return nil
+	}
+
+	log.Printf("Upload %s flagged by scanner: %s", name, result.Threat)
+	if a.cfg.UploadScanAction == "quarantine" {
+		dest := filepath.Join(a.cfg.QuarantineDir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(name)))
+		if err := os.MkdirAll(a.cfg.QuarantineDir, 0o700); err != nil {
+			log.Println("Error creating quarantine directory:", err)
+		} else if err := os.WriteFile(dest, content, 0o600); err != nil {
+			log.Println("Error quarantining upload:", err)
+		} else {
+			log.Printf("Upload %s quarantined as %s", name, dest)
+		}
+	}
+	return errUploadRejected
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Settings are the runtime-adjustable options admins can change without a
+// redeploy. Each JSON field name is also the key it is stored under.
+type Settings struct {
+	// UploadLimitMB caps multipart upload requests and BodyLimitKB every
+	// other request body.
+	UploadLimitMB    int64 `json:"upload_limit_mb" validate:"min=1,max=4096"`
+	BodyLimitKB      int64 `json:"body_limit_kb" validate:"min=1,max=102400"`
+	DefaultPriority  int   `json:"default_priority" validate:"min=0"`
+	RegistrationOpen bool  `json:"registration_open"`
+	PublicReporting  bool  `json:"public_reporting"`
+	// AutoCloseAfterDays closes issues left waiting on their reporter this
+	// long; 0 disables it. The reporter is warned AutoCloseWarningDays
+	// beforehand.
+	AutoCloseAfterDays   int `json:"auto_close_after_days" validate:"min=0,max=3650"`
+	AutoCloseWarningDays int `json:"auto_close_warning_days" validate:"min=0,max=3650"`
+	// RetainClosedIssuesDays archives issues closed and untouched this long,
+	// and RetainInactiveContactsDays contacts inactive this long; 0 keeps
+	// them forever.
+	RetainClosedIssuesDays     int `json:"retain_closed_issues_days" validate:"min=0,max=36500"`
+	RetainInactiveContactsDays int `json:"retain_inactive_contacts_days" validate:"min=0,max=36500"`
+	// DueReminderBeforeHours and DueReminderAfterHours are how long before
+	// and after an issue's due date its assignee is reminded; 0 turns that
+	// reminder off.
+	DueReminderBeforeHours int `json:"due_reminder_before_hours" validate:"min=0,max=8760"`
+	DueReminderAfterHours  int `json:"due_reminder_after_hours" validate:"min=0,max=8760"`
+	// DigestHourUTC is the hour digests go out, daily or on Mondays.
+	DigestHourUTC int `json:"digest_hour_utc" validate:"min=0,max=23"`
+
+	// ExportRedactionProfiles maps a profile name to the export columns it
+	// blanks out.
+	ExportRedactionProfiles map[string][]string `json:"export_redaction_profiles"`
+	// FieldVisibility maps "resource.column" to the roles allowed to see it
+	// in API responses, exports and webhook payloads. Regular users are
+	// "user", unauthenticated callers "anonymous" and webhook receivers
+	// "webhook"; unlisted columns are visible to everyone.
+	FieldVisibility map[string][]string `json:"field_visibility"`
+}
+
+var defaultSettings = Settings{
+	UploadLimitMB:    10,
+	BodyLimitKB:      1024,
+	RegistrationOpen: true,
+	PublicReporting:  true,
+
+	AutoCloseAfterDays:   14,
+	AutoCloseWarningDays: 3,
+	DigestHourUTC:        8,
+
+	DueReminderBeforeHours: 24,
+	DueReminderAfterHours:  24,
+	ExportRedactionProfiles: map[string][]string{
+		"analytics":  {"email", "full_name", "twitter_profile", "linkedin_profile", "reported_by"},
+		"compliance": {},
+	},
+	FieldVisibility: map[string][]string{
+		"issues.reported_by": {"admin"},
+	},
+}
+
+// settingsCache holds the effective settings between reloads.
+type settingsCache struct {
+	sync.RWMutex
+	current Settings
+}
+
+// currentSettings returns the cached effective settings.
+func (a *App) currentSettings() Settings {
+	a.settings.RLock()
+	defer a.settings.RUnlock()
+	return a.settings.current
+}
+
+// settingsMap returns s as raw JSON values keyed by setting name.
+func settingsMap(s Settings) map[string]json.RawMessage {
+	b, _ := json.Marshal(s)
+	m := map[string]json.RawMessage{}
+	json.Unmarshal(b, &m)
+	return m
+}
+
+// applySettings overlays the stored overrides on the defaults. Overrides for
+// unknown keys, with values of the wrong type or out of range are rejected.
+func applySettings(base Settings, overrides []models.Setting) (Settings, error) {
+	m := settingsMap(base)
+	for _, o := range overrides {
+		m[o.Key] = json.RawMessage(o.Value)
+	}
+	b, _ := json.Marshal(m)
+
+	// Decode into a fresh value so maps in base are never written to
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var s Settings
+	if err := dec.Decode(&s); err != nil {
+		return base, err
+	}
+	for key := range s.FieldVisibility {
+		if !validVisibilityRule(key) {
+			return base, fmt.Errorf("unknown field %q in field_visibility", key)
+		}
+	}
+	if err := validateInput(&s); err != nil {
+		return base, err
+	}
+	return s, nil
+}
+
+// reloadSettings refreshes the cache from the database.
+func (a *App) reloadSettings(ctx context.Context) error {
+	var overrides []models.Setting
+	if err := a.dbCtx(ctx).Find(&overrides).Error; err != nil {
+		return err
+	}
+
+	s := defaultSettings
+	for _, o := range overrides {
+		next, err := applySettings(s, []models.Setting{o})
+		if err != nil {
+			log.Printf("Ignoring invalid setting %s: %s", o.Key, err)
+			continue
+		}
+		s = next
+	}
+
+	a.settings.Lock()
+	a.settings.current = s
+	a.settings.Unlock()
+	return nil
+}
+
+// watchSettings reloads settings periodically so changes made through
+// another instance are picked up.
+func (a *App) watchSettings(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.reloadSettings(ctx); err != nil {
+				log.Println("Error reloading settings:", err)
+			}
+		}
+	}
+}
+
+type settingView struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Default   json.RawMessage `json:"default"`
+	UpdatedBy string          `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time      `json:"updatedAt,omitempty"`
+}
+
+func (a *App) listSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var overrides []models.Setting
+	if err := a.dbCtx(r.Context()).Find(&overrides).Error; err != nil {
+		log.Println("Error loading settings:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading settings")
+		return
+	}
+	byKey := map[string]models.Setting{}
+	for _, o := range overrides {
+		byKey[o.Key] = o
+	}
+
+	current := settingsMap(a.currentSettings())
+	defaults := settingsMap(defaultSettings)
+	views := make([]settingView, 0, len(defaults))
+	for key, def := range defaults {
+		v := settingView{Key: key, Value: current[key], Default: def}
+		if o, ok := byKey[key]; ok {
+			v.UpdatedBy = o.UpdatedBy
+			v.UpdatedAt = &o.UpdatedAt
+		}
+		views = append(views, v)
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Key < views[j].Key })
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, views)
+}
+
+func (a *App) getSettingHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	value, ok := settingsMap(a.currentSettings())[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown setting")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, settingView{Key: key, Value: value, Default: settingsMap(defaultSettings)[key]})
+}
+
+func (a *App) updateSettingHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	key := mux.Vars(r)["key"]
+	if _, ok := settingsMap(defaultSettings)[key]; !ok {
+		writeError(w, http.StatusNotFound, "Unknown setting")
+		return
+	}
+
+	var body struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Value) == 0 {
+		writeError(w, http.StatusBadRequest, "Request body must be {\"value\": ...}")
+		return
+	}
+
+	// Make sure the value decodes into the setting's type before storing it
+	setting := models.Setting{Key: key, Value: string(body.Value), UpdatedBy: user.Username, UpdatedAt: time.Now()}
+	if _, err := applySettings(defaultSettings, []models.Setting{setting}); err != nil {
+		var se *serviceError
+		if errors.As(err, &se) && len(se.fields) > 0 {
+			sendError(w, http.StatusBadRequest, localizef(w, "Invalid value for %s", key), map[string]interface{}{"fields": localizeFields(w, se.fields)})
+			return
+		}
+		writeErrorf(w, http.StatusBadRequest, "Invalid value for %s", key)
+		return
+	}
+
+	if err := a.dbCtx(r.Context()).Save(&setting).Error; err != nil {
+		log.Println("Error saving setting:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to save setting")
+		return
+	}
+	if err := a.reloadSettings(r.Context()); err != nil {
+		log.Println("Error reloading settings:", err)
+	}
+
+	log.Printf("Setting %s changed to %s by %s", key, setting.Value, user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, setting)
+}
+
+func (a *App) deleteSettingHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if err := a.dbCtx(r.Context()).Where("key = ?", key).Delete(&models.Setting{}).Error; err != nil {
+		log.Println("Error deleting setting:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to reset setting")
+		return
+	}
+	if err := a.reloadSettings(r.Context()); err != nil {
+		log.Println("Error reloading settings:", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"form/models"
+	"form/storage"
+
+	"gorm.io/gorm"
+)
+
+// statsIntervals are the bucket sizes trend statistics can use, as
+// date_trunc fields and the matching series step.
+var statsIntervals = map[string]string{
+	"day":   "1 day",
+	"week":  "1 week",
+	"month": "1 month",
+}
+
+const statsMaxDays = 3650
+
+type statsWindow struct {
+	Interval string    `json:"interval"`
+	Since    time.Time `json:"since"`
+}
+
+// parseStatsWindow reads the interval and days query parameters, writing an
+// error response if they are invalid or the database cannot compute
+// statistics.
+func (a *App) parseStatsWindow(w http.ResponseWriter, r *http.Request) (statsWindow, bool) {
+	window := statsWindow{Interval: r.URL.Query().Get("interval")}
+	// The trends are built with Postgres date functions
+	if storage.UsingSQLite(a.dbCtx(r.Context())) {
+		writeError(w, http.StatusNotImplemented, "Statistics are not available on SQLite")
+		return window, false
+	}
+	if window.Interval == "" {
+		window.Interval = "day"
+	}
+	if _, ok := statsIntervals[window.Interval]; !ok {
+		writeError(w, http.StatusBadRequest, "interval must be day, week or month")
+		return window, false
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	if days > statsMaxDays {
+		days = statsMaxDays
+	}
+	window.Since = time.Now().AddDate(0, 0, -days)
+	return window, true
+}
+
+type stateCount struct {
+	State string `json:"state"`
+	Count int    `json:"count"`
+}
+
+type reporterCount struct {
+	ReportedBy string `json:"reportedBy"`
+	Count      int    `json:"count"`
+}
+
+type issueTrendBucket struct {
+	Bucket time.Time `json:"bucket"`
+	Opened int       `json:"opened"`
+	Closed int       `json:"closed"`
+}
+
+type resolutionStats struct {
+	Resolved    int     `json:"resolved"`
+	MeanHours   float64 `json:"meanHours"`
+	MedianHours float64 `json:"medianHours"`
+}
+
+type issueStats struct {
+	Window     statsWindow        `json:"window"`
+	ByState    []stateCount       `json:"byState"`
+	ByPriority []priorityCount    `json:"byPriority"`
+	ByReporter []reporterCount    `json:"byReporter"`
+	Trend      []issueTrendBucket `json:"trend"`
+	Resolution resolutionStats    `json:"resolution"`
+}
+
+// issueStatsHandler breaks the organization's issues down by state,
+// priority and reporter, and over the window counts issues opened and
+// closed per bucket and how long closed issues took to resolve. The most
+// active reporters come first, up to limit. Everything is aggregated in
+// SQL.
+func (a *App) issueStatsHandler(w http.ResponseWriter, r *http.Request) {
+	window, ok := a.parseStatsWindow(w, r)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	org := contextOrganization(r.Context())
+	stats := issueStats{Window: window}
+	issues := func() *gorm.DB { return a.dbCtx(r.Context()).Model(&models.Issue{}) }
+	queries := []func() error{
+		func() error {
+			return issues().Select("state, count(*) AS count").Group("state").Order("state").Scan(&stats.ByState).Error
+		},
+		func() error {
+			return issues().Select("priority, count(*) AS count").Group("priority").Order("priority desc").Scan(&stats.ByPriority).Error
+		},
+		func() error {
+			return issues().Select("reported_by, count(*) AS count").Group("reported_by").
+				Order("count desc, reported_by").Limit(limit).Scan(&stats.ByReporter).Error
+		},
+		func() error {
+			return a.dbCtx(r.Context()).Raw(`SELECT b.bucket, COALESCE(o.n, 0) AS opened, COALESCE(c.n, 0) AS closed
+				FROM generate_series(date_trunc(?, ?::timestamptz), date_trunc(?, now()), ?::interval) AS b(bucket)
+				LEFT JOIN (SELECT date_trunc(?, created_at) AS bucket, count(*) AS n FROM issues
+					WHERE organization_id = ? AND deleted_at IS NULL AND created_at >= ? GROUP BY 1) o ON o.bucket = b.bucket
+				LEFT JOIN (SELECT date_trunc(?, issue_events.created_at) AS bucket, count(*) AS n
+					FROM issue_events JOIN issues ON issues.id = issue_events.issue_id
+					WHERE issues.organization_id = ? AND issues.deleted_at IS NULL AND issue_events.created_at >= ?
+						AND issue_events.type = ? AND issue_events.to_value = ? GROUP BY 1) c ON c.bucket = b.bucket
+				ORDER BY b.bucket`,
+				window.Interval, window.Since, window.Interval, statsIntervals[window.Interval],
+				window.Interval, org, window.Since,
+				window.Interval, org, window.Since, eventStateChanged, stateClosed).Scan(&stats.Trend).Error
+		},
+		func() error {
+			// Time to resolution runs from filing to the last close, for
+			// issues still closed whose last close falls in the window
+			return a.dbCtx(r.Context()).Raw(`SELECT count(*) AS resolved,
+					COALESCE(avg(extract(epoch FROM c.closed_at - issues.created_at)) / 3600, 0) AS mean_hours,
+					COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY extract(epoch FROM c.closed_at - issues.created_at)) / 3600, 0) AS median_hours
+				FROM issues JOIN (SELECT issue_id, max(created_at) AS closed_at FROM issue_events
+					WHERE type = ? AND to_value = ? GROUP BY issue_id) c ON c.issue_id = issues.id
+				WHERE issues.organization_id = ? AND issues.deleted_at IS NULL AND issues.state = ? AND c.closed_at >= ?`,
+				eventStateChanged, stateClosed, org, stateClosed, window.Since).Scan(&stats.Resolution).Error
+		},
+	}
+	for _, q := range queries {
+		if err := q(); err != nil {
+			log.Println("Error computing issue statistics:", err)
+			writeError(w, http.StatusInternalServerError, "Error computing statistics")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, stats)
+}
+
+type importTrendBucket struct {
+	Bucket             time.Time `json:"bucket"`
+	Jobs               int       `json:"jobs"`
+	Failed             int       `json:"failed"`
+	Inserted           int       `json:"inserted"`
+	Skipped            int       `json:"skipped"`
+	Invalid            int       `json:"invalid"`
+	CumulativeInserted int       `json:"cumulativeInserted"`
+}
+
+type importStatsResponse struct {
+	Window     statsWindow         `json:"window"`
+	Contacts   int64               `json:"contacts"`
+	Suppressed int64               `json:"suppressed"`
+	Growth     []importTrendBucket `json:"growth"`
+}
+
+// importStatsHandler reports how many contacts the organization has and, per
+// bucket of the window, what finished imports added. Contacts carry no
+// creation time, so growth is measured by the rows imports inserted.
+func (a *App) importStatsHandler(w http.ResponseWriter, r *http.Request) {
+	window, ok := a.parseStatsWindow(w, r)
+	if !ok {
+		return
+	}
+
+	stats := importStatsResponse{Window: window}
+	queries := []func() error{
+		func() error {
+			return a.dbCtx(r.Context()).Model(&models.Contact{}).Count(&stats.Contacts).Error
+		},
+		func() error {
+			return a.dbCtx(r.Context()).Model(&models.Contact{}).Where("suppressed = ?", true).Count(&stats.Suppressed).Error
+		},
+		func() error {
+			return a.dbCtx(r.Context()).Raw(`SELECT b.bucket, COALESCE(j.jobs, 0) AS jobs, COALESCE(j.failed, 0) AS failed,
+					COALESCE(j.inserted, 0) AS inserted, COALESCE(j.skipped, 0) AS skipped, COALESCE(j.invalid, 0) AS invalid,
+					sum(COALESCE(j.inserted, 0)) OVER (ORDER BY b.bucket) AS cumulative_inserted
+				FROM generate_series(date_trunc(?, ?::timestamptz), date_trunc(?, now()), ?::interval) AS b(bucket)
+				LEFT JOIN (SELECT date_trunc(?, finished_at) AS bucket, count(*) AS jobs,
+						count(CASE WHEN status = ? THEN 1 END) AS failed,
+						sum(inserted) AS inserted, sum(skipped) AS skipped, sum(invalid) AS invalid
+					FROM import_jobs WHERE organization_id = ? AND finished_at >= ? GROUP BY 1) j ON j.bucket = b.bucket
+				ORDER BY b.bucket`,
+				window.Interval, window.Since, window.Interval, statsIntervals[window.Interval],
+				window.Interval, importFailed, contextOrganization(r.Context()), window.Since).Scan(&stats.Growth).Error
+		},
+	}
+	for _, q := range queries {
+		if err := q(); err != nil {
+			log.Println("Error computing import statistics:", err)
+			writeError(w, http.StatusInternalServerError, "Error computing statistics")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, stats)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"form/storage"
+
+	"gorm.io/gorm"
+)
+
+// streamBatchSize is how many rows each FETCH pulls through a streaming
+// cursor.
+const streamBatchSize = 500
+
+// Streaming formats list endpoints accept in ?stream=.
+const (
+	streamNDJSON = "ndjson"
+	streamArray  = "array"
+)
+
+// streamFormat returns the streaming format a list request opted into, or ""
+// for an ordinary page. Asking for application/x-ndjson also opts in.
+func streamFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("stream"); format {
+	case streamNDJSON, streamArray:
+		return format, true
+	case "":
+		if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			return streamNDJSON, true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// streamList writes every row q selects, reading them through a server-side
+// cursor in batches so neither the server nor Postgres client holds the
+// whole result. newRow returns a fresh pointer to scan each row into;
+// attach, if set, fills in what each batch of rows needs from other tables
+// before it is written. GORM builds the cursor's query through its
+// callbacks, so it is tenant scoped like any other. SQLite has no cursors,
+// but its rows are read as they are stepped through anyway.
+//
+// Errors after the first row cannot change the status: NDJSON streams end
+// with an {"error": ...} line and array streams are left unterminated.
+func (a *App) streamList(w http.ResponseWriter, r *http.Request, format string, q *gorm.DB, newRow func() interface{}, attach func(tx *gorm.DB, batch []interface{}) error) {
+	tx, next, closeStream, err := a.openStream(r.Context(), q, newRow)
+	if err != nil {
+		writeDBError(w, err, "Error loading results")
+		return
+	}
+	defer closeStream()
+
+	if format == streamNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[")
+	}
+
+	role := viewerRole(contextUser(r.Context()))
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	written := 0
+	err = func() error {
+		for {
+			batch, err := next()
+			if err != nil {
+				return err
+			}
+			if attach != nil && len(batch) > 0 {
+				if err := attach(tx, batch); err != nil {
+					return err
+				}
+			}
+			for _, row := range batch {
+				if format == streamArray && written > 0 {
+					io.WriteString(w, ",")
+				}
+				if err := enc.Encode(a.visibleTo(role, row)); err != nil {
+					return err
+				}
+				written++
+			}
+			if len(batch) < streamBatchSize {
+				return nil
+			}
+			if err := rc.Flush(); err != nil {
+				return err
+			}
+		}
+	}()
+	if err != nil {
+		log.Printf("Error streaming %s after %d rows: %s", r.URL.Path, written, err)
+		if format == streamNDJSON {
+			enc.Encode(map[string]string{"error": "Stream interrupted"})
+		}
+		return
+	}
+	if format == streamArray {
+		io.WriteString(w, "]\n")
+	}
+}
+
+// openStream starts reading the rows q selects. next returns up to
+// streamBatchSize of them at a time, and tx is where to run the queries
+// that go with them; closeStream releases both.
+func (a *App) openStream(ctx context.Context, q *gorm.DB, newRow func() interface{}) (tx *gorm.DB, next func() ([]interface{}, error), closeStream func(), err error) {
+	if storage.UsingSQLite(q) {
+		rows, err := q.Rows()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tx = a.dbCtx(ctx)
+		next = func() ([]interface{}, error) {
+			return scanBatch(tx, rows, newRow)
+		}
+		return tx, next, func() { rows.Close() }, nil
+	}
+
+	// Cursors only live inside a transaction; this one only reads
+	tx = a.dbCtx(ctx).Begin()
+	if err := tx.Exec("DECLARE list_stream NO SCROLL CURSOR FOR ?", q).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, nil, err
+	}
+	next = func() ([]interface{}, error) {
+		rows, err := tx.Raw(fmt.Sprintf("FETCH %d FROM list_stream", streamBatchSize)).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return scanBatch(tx, rows, newRow)
+	}
+	return tx, next, func() { tx.Rollback() }, nil
+}
+
+// scanBatch scans up to streamBatchSize rows.
+func scanBatch(tx *gorm.DB, rows *sql.Rows, newRow func() interface{}) ([]interface{}, error) {
+	var batch []interface{}
+	for len(batch) < streamBatchSize && rows.Next() {
+		row := newRow()
+		if err := tx.ScanRows(rows, row); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// untimedRoutes run for as long as they need: they stream results, hold a
+// connection open or import whole files.
+var untimedRoutes = map[string]bool{
+	"/events":                  true,
+	"/admin/exports/{dataset}": true,
+	"/bi/{entity}":             true,
+	csvUploadRoute:             true,
+}
+
+// timeoutMiddleware cancels the request context after cfg.RequestTimeout,
+// which stops any queries still running for a client that has gone or
+// waited too long. Streamed lists, profiles and the routes above are
+// exempt.
+func (a *App) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.cfg.RequestTimeout <= 0 || untimedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func untimedRequest(r *http.Request) bool {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && (untimedRoutes[tpl] || strings.HasPrefix(tpl, uploadsPrefix) || strings.HasPrefix(tpl, "/debug/pprof/")) {
+			return true
+		}
+	}
+	format, _ := streamFormat(r)
+	return format != ""
+}
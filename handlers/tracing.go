@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"form/storage"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	tracerName = "form"
+
+	traceSpanKey = "otel:span"
+)
+
+var tracer = otel.Tracer(tracerName)
+
+// SetupTracing installs the W3C trace context propagator and, when an OTLP
+// endpoint is configured through the standard OTEL_EXPORTER_OTLP_* variables,
+// a batching exporter. The returned function flushes pending spans.
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	if os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL") == "grpc" {
+		exporter, err = otlptracegrpc.New(ctx)
+	} else {
+		exporter, err = otlptracehttp.New(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "form"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// dbCtx returns a handle whose queries are traced as children of the span
+// in ctx. Transactions begun from it inherit the context.
+func (a *App) dbCtx(ctx context.Context) *gorm.DB {
+	return a.db.WithContext(ctx)
+}
+
+// registerTracingCallbacks wraps every GORM operation in a client span.
+func registerTracingCallbacks(db *gorm.DB) {
+	// db.system takes OpenTelemetry's names for the databases
+	system := "postgresql"
+	if storage.UsingSQLite(db) {
+		system = "sqlite"
+	}
+	before := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			_, span := tracer.Start(tx.Statement.Context, "gorm."+op, trace.WithSpanKind(trace.SpanKindClient))
+			tx.InstanceSet(traceSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(traceSpanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		span.SetAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.statement", tx.Statement.SQL.String()),
+			attribute.String("db.sql.table", tx.Statement.Table),
+			attribute.Int64("db.rows_affected", tx.RowsAffected),
+		)
+		if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("gorm:begin_transaction").Register("otel:before_create", before("create"))
+	cb.Create().After("gorm:commit_or_rollback_transaction").Register("otel:after_create", after)
+	cb.Query().Before("gorm:query").Register("otel:before_query", before("query"))
+	cb.Query().After("gorm:after_query").Register("otel:after_query", after)
+	cb.Update().Before("gorm:begin_transaction").Register("otel:before_update", before("update"))
+	cb.Update().After("gorm:commit_or_rollback_transaction").Register("otel:after_update", after)
+	cb.Delete().Before("gorm:begin_transaction").Register("otel:before_delete", before("delete"))
+	cb.Delete().After("gorm:commit_or_rollback_transaction").Register("otel:after_delete", after)
+	cb.Row().Before("gorm:row").Register("otel:before_row", before("row"))
+	cb.Row().After("gorm:row").Register("otel:after_row", after)
+	cb.Raw().Before("gorm:raw").Register("otel:before_raw", before("raw"))
+	cb.Raw().After("gorm:raw").Register("otel:after_raw", after)
+}
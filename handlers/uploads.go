@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const uploadsPrefix = "/uploads/"
+
+// imageExtensions lists the image types accepted as issue attachments.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// uploadName returns the path an ImageURL points at inside the uploads
+// directory, or "" if the URL refers to something else. Uploads are kept in
+// a directory per organization, "<organization ID>/<file>"; older ones are
+// bare file names.
+func uploadName(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		imageURL = u.Path
+	}
+	i := strings.LastIndex(imageURL, strings.TrimPrefix(uploadsPrefix, "/"))
+	if i < 0 {
+		return ""
+	}
+	name := imageURL[i+len(uploadsPrefix)-1:]
+	file := name
+	if dir, rest, ok := strings.Cut(name, "/"); ok {
+		if _, err := strconv.ParseUint(dir, 10, 64); err != nil {
+			return ""
+		}
+		file = rest
+	}
+	if file == "" || strings.Contains(file, "/") {
+		return ""
+	}
+	return name
+}
+
+// issueUpload returns the upload issue's ImageURL points at, or "" unless
+// it is in the issue's own organization's directory. Bare names predate
+// organizations, so only the default organization, which took over all
+// earlier data, can refer to them.
+func issueUpload(issue models.Issue) string {
+	name := uploadName(issue.ImageURL)
+	dir, _, ok := strings.Cut(name, "/")
+	if !ok {
+		if issue.OrganizationID != models.DefaultOrganizationID {
+			return ""
+		}
+		return name
+	}
+	if dir != strconv.FormatUint(uint64(issue.OrganizationID), 10) {
+		return ""
+	}
+	return name
+}
+
+func (a *App) uploadSignature(name string, expires int64) string {
+	return a.signParts(name, strconv.FormatInt(expires, 10))
+}
+
+// signedUploadURL returns a URL for the named upload valid until expires.
+func (a *App) signedUploadURL(name string, expires time.Time) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", a.uploadSignature(name, expires.Unix()))
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return uploadsPrefix + strings.Join(segments, "/") + "?" + q.Encode()
+}
+
+// canAccessIssue reports whether user may see an issue and its attachments:
+// admins of its organization, its reporter and its assignee.
+func canAccessIssue(user *models.User, issue models.Issue) bool {
+	return organizationAdmin(user) || issue.ReportedBy == user.Username || issue.Assignee == user.Username
+}
+
+// visibleIssues narrows q to the issues canAccessIssue lets user see.
+func visibleIssues(q *gorm.DB, user *models.User) *gorm.DB {
+	if organizationAdmin(user) {
+		return q
+	}
+	return q.Where("(reported_by = ? OR assignee = ?)", user.Username, user.Username)
+}
+
+func (a *App) issueAttachmentURLHandler(w http.ResponseWriter, r *http.Request) {
+	// Issues the user cannot see are treated as missing rather than forbidden
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	name := issueUpload(issue)
+	if name == "" {
+		writeError(w, http.StatusNotFound, "Issue has no attachment")
+		return
+	}
+
+	expires := time.Now().Add(a.cfg.UploadURLTTL)
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"url":       a.signedUploadURL(name, expires),
+		"expiresAt": expires,
+	})
+}
+
+// serveUploadHandler serves a file from upload storage if the request
+// carries a valid, unexpired signature for it.
+func (a *App) serveUploadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	path := name
+	if org := vars["org"]; org != "" {
+		path = org + "/" + name
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusForbidden, "Missing or invalid signature")
+		return
+	}
+	sig := r.URL.Query().Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(a.uploadSignature(path, expires))) {
+		writeError(w, http.StatusForbidden, "Missing or invalid signature")
+		return
+	}
+	if time.Now().Unix() > expires {
+		writeError(w, http.StatusForbidden, "Link expired")
+		return
+	}
+
+	f, modTime, err := a.storage.Open(r.Context(), path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	defer f.Close()
+
+	// Decide how to serve the file from its content, not its extension
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		writeError(w, http.StatusInternalServerError, "Error reading file")
+		return
+	}
+	setAttachmentHeaders(w.Header(), name, http.DetectContentType(head[:n]))
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, name, modTime, f)
+}
+
+// inlineContentTypes are the attachment types browsers may render in place.
+// Anything else, and HTML in particular, is only ever offered as a download.
+var inlineContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// setAttachmentHeaders applies the serving policy for an attachment of the
+// given sniffed content type.
+func setAttachmentHeaders(h http.Header, name, contentType string) {
+	mediaType := contentType
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	if inlineContentTypes[mediaType] {
+		h.Set("Content-Type", mediaType)
+		h.Set("Content-Disposition", "inline")
+		return
+	}
+
+	// Serve everything else as opaque bytes so it cannot execute in our origin
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sanitizeFilename(name)))
+}
+
+// sanitizeFilename reduces a filename to a safe ASCII subset for use in a
+// Content-Disposition header.
+func sanitizeFilename(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	safe := strings.TrimLeft(b.String(), ".")
+	if len(safe) > 100 {
+		safe = safe[len(safe)-100:]
+	}
+	if safe == "" {
+		return "attachment"
+	}
+	return safe
+}
+
+// uploadIssueImageHandler stores an image attachment for an issue. The file is
+// scanned and stripped of metadata before it is written to upload storage.
+func (a *App) uploadIssueImageHandler(w http.ResponseWriter, r *http.Request) {
+	issue, ok := a.loadAccessibleIssue(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(a.currentSettings().UploadLimitMB << 20); bodyTooLarge(err) {
+		writeError(w, http.StatusRequestEntityTooLarge, "Body too large")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, "Unable to parse form")
+		return
+	}
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Error retrieving file")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Error reading file")
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		writeError(w, http.StatusUnsupportedMediaType, "Unsupported image type")
+		return
+	}
+
+	if err := a.scanUpload(r.Context(), header.Filename, content); err != nil {
+		if errors.Is(err, errUploadRejected) {
+			writeError(w, http.StatusUnprocessableEntity, "File rejected by upload scanner")
+			return
+		}
+		log.Println("Error scanning upload:", err)
+		writeError(w, http.StatusServiceUnavailable, "Upload scanning unavailable")
+		return
+	}
+
+	content, err = stripImageMetadata(contentType, content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid image")
+		return
+	}
+
+	// Store under a random name so client filenames never reach the disk,
+	// in the issue's organization's directory
+	random := make([]byte, 16)
+	rand.Read(random)
+	orgDir := strconv.FormatUint(uint64(issue.OrganizationID), 10)
+	name := orgDir + "/" + hex.EncodeToString(random) + ext
+	if err := a.storage.Put(r.Context(), name, content); err != nil {
+		log.Println("Error writing upload:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	tx := a.dbCtx(r.Context()).Begin()
+	if err := tx.Model(&issue).Updates(map[string]interface{}{
+		"image_url": uploadsPrefix + name,
+		"version":   gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		tx.Rollback()
+		log.Println("Error updating issue image:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to attach file")
+		return
+	}
+	if err := a.recordIssueEvent(tx, issue.ID, eventAttachmentAdded, actorName(r), "", name); err != nil {
+		tx.Rollback()
+		log.Println("Error recording issue event:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to attach file")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Error updating issue image:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to attach file")
+		return
+	}
+
+	expires := time.Now().Add(a.cfg.UploadURLTTL)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, map[string]interface{}{
+		"url":       a.signedUploadURL(name, expires),
+		"expiresAt": expires,
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"form/models"
+)
+
+func TestIssueUpload(t *testing.T) {
+	tests := []struct {
+		imageURL string
+		org      uint
+		want     string
+	}{
+		{"/uploads/2/4f2a.png", 2, "2/4f2a.png"},
+		{"http://form.test/uploads/2/4f2a.png", 2, "2/4f2a.png"},
+		// Another organization's upload
+		{"/uploads/3/4f2a.png", 2, ""},
+		{"/uploads/1/4f2a.png", 2, ""},
+		// Bare names predate organizations and belong to the default one
+		{"/uploads/4f2a.png", models.DefaultOrganizationID, "4f2a.png"},
+		{"/uploads/4f2a.png", 2, ""},
+		{"/uploads/../2/4f2a.png", 2, ""},
+		{"https://example.com/cat.png", 2, ""},
+		{"", 2, ""},
+	}
+	for _, tt := range tests {
+		issue := models.Issue{ImageURL: tt.imageURL, OrganizationID: tt.org}
+		if got := issueUpload(issue); got != tt.want {
+			t.Errorf("issueUpload(%q in %d) = %q, want %q", tt.imageURL, tt.org, got, tt.want)
+		}
+	}
+}
+
+func TestCreateIssueIgnoresImageURL(t *testing.T) {
+	a := newTestApp(t)
+	ctx := withOrganization(context.Background(), models.DefaultOrganizationID)
+	user := &models.User{Username: "ana"}
+
+	issue, err := a.createIssue(ctx, user, models.Issue{Title: "Broken", ImageURL: "/uploads/2/4f2a.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := a.findIssue(ctx, issue.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ImageURL != "" {
+		t.Errorf("ImageURL = %q, want it left for uploads to set", stored.ImageURL)
+	}
+}
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Account roles. Admin accounts are operators of the deployment; everyone
+// else has no account role and gets their access from memberships.
+const (
+	accountRoleAdmin = "admin"
+	accountRoleUser  = "user"
+)
+
+// userView is a user as operators see it, without the password.
+type userView struct {
+	ID                    uint       `json:"id"`
+	Username              string     `json:"username"`
+	Role                  string     `json:"role"`
+	Disabled              bool       `json:"disabled"`
+	DisabledAt            *time.Time `json:"disabledAt,omitempty"`
+	PasswordResetRequired bool       `json:"passwordResetRequired"`
+}
+
+func newUserView(u models.User) userView {
+	role := u.Role
+	if role == "" {
+		role = accountRoleUser
+	}
+	return userView{
+		ID:                    u.ID,
+		Username:              u.Username,
+		Role:                  role,
+		Disabled:              u.DisabledAt != nil,
+		DisabledAt:            u.DisabledAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+	}
+}
+
+type roleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin user"`
+}
+
+type passwordResetRequest struct {
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+var (
+	errUserNotFound    = newServiceError(http.StatusNotFound, "User not found")
+	errOwnAccount      = newServiceError(http.StatusConflict, "Operators cannot change their own account here")
+	errAccountDisabled = newServiceError(http.StatusForbidden, "Account disabled")
+	errResetRequired   = newServiceError(http.StatusForbidden, "Password reset required")
+)
+
+// managedUser loads the user named in the URL for an operator to change,
+// refusing the operator's own account so nobody locks themselves out.
+func (a *App) managedUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	var user models.User
+	err := a.dbCtx(r.Context()).First(&user, mux.Vars(r)["id"]).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errUserNotFound
+	}
+	if err == nil {
+		if account, _ := currentAccount(r); account.ID == user.ID {
+			err = errOwnAccount
+		}
+	}
+	if err != nil {
+		writeServiceError(w, err, "Error loading user")
+		return user, false
+	}
+	return user, true
+}
+
+// listUsersHandler returns accounts newest first, paging backwards from
+// before, optionally only those with the given role.
+func (a *App) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q := a.dbCtx(r.Context()).Order("id desc").Limit(limit)
+	switch query.Get("role") {
+	case "":
+	case accountRoleAdmin:
+		q = q.Where("role = ?", accountRoleAdmin)
+	case accountRoleUser:
+		q = q.Where("coalesce(role, '') = ''")
+	default:
+		writeError(w, http.StatusBadRequest, "role must be admin or user")
+		return
+	}
+	if before, _ := strconv.ParseUint(query.Get("before"), 10, 64); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+
+	var users []models.User
+	if err := q.Find(&users).Error; err != nil {
+		writeDBError(w, err, "Error loading users")
+		return
+	}
+	views := make([]userView, len(users))
+	for i, u := range users {
+		views[i] = newUserView(u)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, views)
+}
+
+func (a *App) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := a.dbCtx(r.Context()).First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		writeDBError(w, err, "Error loading user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, newUserView(user))
+}
+
+// updateUserRoleHandler makes a user an operator or takes that away.
+func (a *App) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var body roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+	user, ok := a.managedUser(w, r)
+	if !ok {
+		return
+	}
+
+	role := body.Role
+	if role == accountRoleUser {
+		role = ""
+	}
+	from := newUserView(user).Role
+	tx := a.dbCtx(r.Context()).Begin()
+	err := tx.Model(&user).Update("role", role).Error
+	if err == nil {
+		err = recordAudit(tx, actorName(r), "user.role_changed", "user", user.ID, map[string]string{"from": from, "to": body.Role})
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to update user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, newUserView(user))
+}
+
+// setUserDisabledHandler disables or re-enables an account. Disabled
+// accounts cannot log in and their tokens stop working at once.
+func (a *App) setUserDisabledHandler(disable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := a.managedUser(w, r)
+		if !ok {
+			return
+		}
+
+		var disabledAt *time.Time
+		action := "user.enabled"
+		if disable {
+			now := time.Now()
+			disabledAt, action = &now, "user.disabled"
+		}
+		if (user.DisabledAt != nil) != disable {
+			tx := a.dbCtx(r.Context()).Begin()
+			err := tx.Model(&user).Update("disabled_at", disabledAt).Error
+			if err == nil {
+				err = recordAudit(tx, actorName(r), action, "user", user.ID, nil)
+			}
+			if err == nil {
+				err = tx.Commit().Error
+			} else {
+				tx.Rollback()
+			}
+			if err != nil {
+				writeDBError(w, err, "Failed to update user")
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		a.encodeJSON(w, r, newUserView(user))
+	}
+}
+
+// passwordResetSignature signs a reset link. Forcing another reset changes
+// PasswordChangedAt, which retires earlier links.
+func (a *App) passwordResetSignature(user models.User, expires int64) string {
+	changed := ""
+	if user.PasswordChangedAt != nil {
+		changed = strconv.FormatInt(user.PasswordChangedAt.Unix(), 10)
+	}
+	return a.signParts("password-reset", strconv.FormatUint(uint64(user.ID), 10), strconv.FormatInt(expires, 10), changed)
+}
+
+// passwordResetURL returns the signed link a user follows to choose a new
+// password.
+func (a *App) passwordResetURL(user models.User, expires time.Time) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", a.passwordResetSignature(user, expires.Unix()))
+	return strings.TrimRight(a.cfg.PublicURL, "/") + "/password-reset/" + strconv.FormatUint(uint64(user.ID), 10) + "?" + q.Encode()
+}
+
+// forcePasswordResetHandler blocks a user's login until they choose a new
+// password, revokes their tokens, and returns the reset link to pass on.
+func (a *App) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.managedUser(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	tx := a.dbCtx(r.Context()).Begin()
+	err := tx.Model(&user).Updates(map[string]interface{}{
+		"password_reset_required": true,
+		"password_changed_at":     now,
+	}).Error
+	if err == nil {
+		err = recordAudit(tx, actorName(r), "user.password_reset_forced", "user", user.ID, nil)
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to reset password")
+		return
+	}
+
+	expires := now.Add(a.cfg.PasswordResetTTL)
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{
+		"url":       a.passwordResetURL(user, expires),
+		"expiresAt": expires,
+	})
+}
+
+// completePasswordResetHandler sets the password chosen through a reset
+// link and lets the user log in again.
+func (a *App) completePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var body passwordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validateRequest(w, &body) {
+		return
+	}
+
+	var user models.User
+	// Links only work while a reset is pending, so each works once
+	if err := a.dbCtx(r.Context()).First(&user, mux.Vars(r)["id"]).Error; err != nil || !user.PasswordResetRequired {
+		writeError(w, http.StatusForbidden, "Invalid password reset link")
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	sig := r.URL.Query().Get("sig")
+	if err != nil || !hmac.Equal([]byte(sig), []byte(a.passwordResetSignature(user, expires))) {
+		writeError(w, http.StatusForbidden, "Invalid password reset link")
+		return
+	}
+	if time.Now().Unix() > expires {
+		writeError(w, http.StatusForbidden, "Link expired")
+		return
+	}
+
+	err = a.dbCtx(r.Context()).Model(&user).Updates(map[string]interface{}{
+		"password":                body.Password,
+		"password_reset_required": false,
+		"password_changed_at":     time.Now(),
+	}).Error
+	if err != nil {
+		writeDBError(w, err, "Failed to reset password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteUserHandler deletes an account. Issues it reported move to the
+// user named by reassignTo, or are left without a reporter so no later
+// account with the same name inherits them; issues assigned to it are
+// unassigned. This spans every organization.
+func (a *App) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.managedUser(w, r)
+	if !ok {
+		return
+	}
+
+	reporter := ""
+	if name := r.URL.Query().Get("reassignTo"); name != "" {
+		var target models.User
+		if err := a.dbCtx(r.Context()).Where("username = ?", name).First(&target).Error; err != nil || target.ID == user.ID {
+			writeError(w, http.StatusBadRequest, "Unknown reassignTo user")
+			return
+		}
+		reporter = target.Username
+	}
+
+	actor := actorName(r)
+	var reassigned, unassigned int
+	tx := a.dbCtx(r.Context()).Begin()
+	err := func() error {
+		var err error
+		// Accounts span organizations, so their issues do too
+		issues := acrossOrganizations(tx)
+		if reassigned, err = a.moveUserIssues(issues, "reported_by", user.Username, reporter, eventReporterChanged, actor); err != nil {
+			return err
+		}
+		if unassigned, err = a.moveUserIssues(issues, "assignee", user.Username, "", eventAssigned, actor); err != nil {
+			return err
+		}
+		if err := acrossOrganizations(tx).Where("recipient = ?", user.Username).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		if err := acrossOrganizations(tx).Where("owner = ?", user.Username).Delete(&models.DigestSubscription{}).Error; err != nil {
+			return err
+		}
+		// The foreign key cascades on Postgres, but the SQLite schema has
+		// none
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		details := map[string]interface{}{"username": user.Username, "issuesReassigned": reassigned, "issuesUnassigned": unassigned}
+		if reporter != "" {
+			details["reassignedTo"] = reporter
+		}
+		return recordAudit(tx, actor, "user.deleted", "user", user.ID, details)
+	}()
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		writeDBError(w, err, "Failed to delete user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, map[string]interface{}{"issuesReassigned": reassigned, "issuesUnassigned": unassigned})
+}
+
+// moveUserIssues sets column from one username to another on every issue,
+// recording the change in each issue's history.
+func (a *App) moveUserIssues(tx *gorm.DB, column, from, to, eventType, actor string) (int, error) {
+	var ids []uint
+	if err := tx.Model(&models.Issue{}).Where(column+" = ?", from).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := tx.Model(&models.Issue{}).Where("id IN (?)", ids).UpdateColumns(map[string]interface{}{
+		column:    to,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := a.recordIssueEvent(tx, id, eventType, actor, from, to); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
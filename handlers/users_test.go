@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"form/models"
+)
+
+func TestDeleteUserRemovesMemberships(t *testing.T) {
+	a := newTestApp(t)
+	if err := a.createAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	var admin models.User
+	if err := a.db.Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	org := models.Organization{Name: "Acme", Slug: "acme"}
+	if err := a.db.Create(&org).Error; err != nil {
+		t.Fatal(err)
+	}
+	ana := models.User{Username: "ana", Password: "password1"}
+	if err := a.repo.CreateUser(context.Background(), &ana, models.DefaultOrganizationID, orgRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addMember(a.db, org.ID, ana.ID, orgRoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := a.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := a.issueToken(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/admin/users/%d", ana.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /admin/users/%d: status %d: %s", ana.ID, rec.Code, rec.Body)
+	}
+
+	var memberships int64
+	if err := a.db.Model(&models.Membership{}).Where("user_id = ?", ana.ID).Count(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	if memberships != 0 {
+		t.Errorf("%d memberships left for the deleted user, want none", memberships)
+	}
+}
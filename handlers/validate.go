@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// validate checks request structs against their `validate` tags. Errors
+// name fields by their JSON names.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterValidation("notblank", validators.NotBlank)
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("projectkey", func(fl validator.FieldLevel) bool {
+		return projectKeyPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return supportedLocale(fl.Field().String())
+	})
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+var (
+	slugPattern       = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	projectKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+)
+
+// fieldError explains why one request field was rejected. Reason is in
+// English; format and args let writeValidationErrors translate it.
+type fieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	format string
+	args   []interface{}
+}
+
+func newFieldError(field, format string, args ...interface{}) fieldError {
+	return fieldError{Field: field, Reason: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// validationReason explains a failed validator tag for the given field.
+func validationReason(field string, fe validator.FieldError) fieldError {
+	switch fe.Tag() {
+	case "required":
+		return newFieldError(field, "is required")
+	case "email":
+		return newFieldError(field, "must be a valid email address")
+	case "url":
+		return newFieldError(field, "must be a valid URL")
+	case "min":
+		if fe.Kind() == reflect.String {
+			return newFieldError(field, "must be at least %s characters", fe.Param())
+		}
+		return newFieldError(field, "must be at least %s", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return newFieldError(field, "must be at most %s characters", fe.Param())
+		}
+		return newFieldError(field, "must be at most %s", fe.Param())
+	case "oneof":
+		return newFieldError(field, "must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "datetime":
+		return newFieldError(field, "must be an RFC 3339 time")
+	case "notblank":
+		return newFieldError(field, "must not be blank")
+	case "slug":
+		return newFieldError(field, "must be lowercase letters, digits and single dashes")
+	case "projectkey":
+		return newFieldError(field, "must be 2 to 10 uppercase letters and digits, starting with a letter")
+	case "locale":
+		return newFieldError(field, "must be one of: %s", strings.Join(supportedLocales(), ", "))
+	}
+	return newFieldError(field, "is invalid (%s)", fe.Tag())
+}
+
+// validateRequest validates v and, if it fails, writes a 422 response
+// listing each invalid field. It reports whether v was valid.
+func validateRequest(w http.ResponseWriter, v interface{}) bool {
+	if err := validateInput(v); err != nil {
+		writeServiceError(w, err, "Invalid request")
+		return false
+	}
+	return true
+}
+
+// validateInput validates v for the service layer, returning a
+// serviceError listing each invalid field.
+func validateInput(v interface{}) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return newServiceError(http.StatusBadRequest, err.Error())
+	}
+	fields := make([]fieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		fields = append(fields, validationReason(fe.Field(), fe))
+	}
+	return &serviceError{status: http.StatusUnprocessableEntity, message: "Validation failed", fields: fields}
+}
+
+// validateValue checks a single value for field against validator rules,
+// returning why it failed or nil if it passed.
+func validateValue(field string, value interface{}, rules string) *fieldError {
+	if rules == "" {
+		return nil
+	}
+	err := validate.Var(value, rules)
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) && len(invalid) > 0 {
+		fe := validationReason(field, invalid[0])
+		return &fe
+	}
+	if err != nil {
+		fe := newFieldError(field, "%s", err.Error())
+		return &fe
+	}
+	return nil
+}
+
+// writeValidationErrors sends a 422 response listing the invalid fields,
+// with their reasons in the response language.
+func writeValidationErrors(w http.ResponseWriter, fields []fieldError) {
+	writeErrorDetails(w, http.StatusUnprocessableEntity, "Validation failed", map[string]interface{}{"fields": localizeFields(w, fields)})
+}
+
+// localizeFields translates each field's reason for the response being
+// written.
+func localizeFields(w http.ResponseWriter, fields []fieldError) []fieldError {
+	localized := make([]fieldError, len(fields))
+	for i, f := range fields {
+		localized[i] = f
+		if f.format != "" {
+			localized[i].Reason = localizef(w, f.format, f.args...)
+		}
+	}
+	return localized
+}
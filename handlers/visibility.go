@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"form/models"
+
+	"gorm.io/gorm/schema"
+)
+
+// Audiences that field visibility rules can name besides user roles.
+const (
+	roleAnonymous = "anonymous"
+	roleUser      = "user"
+	// roleWebhook is the audience for outgoing webhook payloads.
+	roleWebhook = "webhook"
+)
+
+// visibilityResources names the models field visibility rules apply to. The
+// names match the export datasets so one rule covers both.
+var visibilityResources = map[reflect.Type]string{
+	reflect.TypeOf(models.Issue{}):   "issues",
+	reflect.TypeOf(models.Contact{}): "contacts",
+	reflect.TypeOf(models.Comment{}): "comments",
+}
+
+// viewerRole is the audience a user's responses are shaped for.
+func viewerRole(u *models.User) string {
+	switch {
+	case u == nil:
+		return roleAnonymous
+	case u.Role == "":
+		return roleUser
+	default:
+		return u.Role
+	}
+}
+
+// fieldVisible reports whether role may see a resource's column. Columns
+// without a rule are visible to everyone.
+func fieldVisible(rules map[string][]string, role, resource, column string) bool {
+	roles, ok := rules[resource+"."+column]
+	return !ok || containsString(roles, role)
+}
+
+// visibleTo returns a copy of v with every field role may not see zeroed,
+// looking through pointers, slices, maps and interfaces for the models in
+// visibilityResources.
+func (a *App) visibleTo(role string, v interface{}) interface{} {
+	rules := a.currentSettings().FieldVisibility
+	if len(rules) == 0 || v == nil {
+		return v
+	}
+	return redactValue(reflect.ValueOf(v), rules, role, "").Interface()
+}
+
+func redactValue(v reflect.Value, rules map[string][]string, role, resource string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(redactValue(v.Elem(), rules, role, ""))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(redactValue(v.Elem(), rules, role, ""))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i), rules, role, ""))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactValue(iter.Value(), rules, role, ""))
+		}
+		return out
+	case reflect.Struct:
+		if name, ok := visibilityResources[v.Type()]; ok {
+			resource = name
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !out.Field(i).CanSet() {
+				continue
+			}
+			// Embedded structs such as gorm.Model belong to the outer resource
+			inner := ""
+			if field.Anonymous {
+				inner = resource
+			}
+			if resource != "" && !field.Anonymous && !fieldVisible(rules, role, resource, columnName(field.Name)) {
+				out.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+			out.Field(i).Set(redactValue(v.Field(i), rules, role, inner))
+		}
+		return out
+	}
+	return v
+}
+
+// encodeJSON writes v as the JSON response body, shaped for the requesting
+// user's role. Handlers set headers and status as before.
+func (a *App) encodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	json.NewEncoder(w).Encode(a.visibleTo(viewerRole(contextUser(r.Context())), v))
+}
+
+// hiddenColumns returns the dataset columns role may not see in exports.
+func (a *App) hiddenColumns(role, dataset string, columns []string) []string {
+	rules := a.currentSettings().FieldVisibility
+	var hidden []string
+	for _, col := range columns {
+		if !fieldVisible(rules, role, dataset, col) {
+			hidden = append(hidden, col)
+		}
+	}
+	return hidden
+}
+
+// validVisibilityRule reports whether key names a resource column in
+// "resource.column" form.
+func validVisibilityRule(key string) bool {
+	resource, column, _ := strings.Cut(key, ".")
+	for t, name := range visibilityResources {
+		if name == resource && hasColumn(t, column) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasColumn(t reflect.Type, column string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && hasColumn(f.Type, column) {
+			return true
+		}
+		if !f.Anonymous && columnName(f.Name) == column {
+			return true
+		}
+	}
+	return false
+}
+
+// columnName is the column GORM maps a struct field to.
+func columnName(field string) string {
+	return schema.NamingStrategy{}.ColumnName("", field)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// webhookEvent is the envelope every delivery is wrapped in.
+type webhookEvent struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Test      bool        `json:"test,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookSamples returns example data for each event type, used when
+// test-firing a webhook.
+var webhookSamples = map[string]func() interface{}{
+	"issue.created": func() interface{} { return sampleIssue(stateOpen) },
+	"issue.stale_warning": func() interface{} {
+		issue := sampleIssue(stateWaitingOnReporter)
+		issue.StaleWarnedAt = &issue.UpdatedAt
+		return issue
+	},
+	"issue.auto_closed": func() interface{} { return sampleIssue(stateClosed) },
+}
+
+func sampleIssue(state string) models.Issue {
+	issue := models.Issue{
+		Title:      "Sample issue",
+		Details:    "This is a test delivery; no issue was changed.",
+		Priority:   2,
+		ReportedBy: "reporter",
+		ReportedAt: time.Now(),
+		State:      state,
+		Status:     state == stateClosed,
+	}
+	issue.ID = 1
+	issue.CreatedAt = issue.ReportedAt
+	issue.UpdatedAt = issue.ReportedAt
+	if state == stateWaitingOnReporter {
+		issue.WaitingSince = &issue.ReportedAt
+	}
+	return issue
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// signWebhook returns the signature header value for a payload. Receivers
+// recompute HMAC-SHA256 over "<timestamp>.<body>" with their secret.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRequest builds the signed HTTP request for one delivery.
+func webhookRequest(hook models.Webhook, evt webhookEvent) (*http.Request, []byte, error) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "form-webhooks/1")
+	req.Header.Set("X-Form-Event", evt.Event)
+	req.Header.Set("X-Form-Delivery", evt.ID)
+	req.Header.Set("X-Form-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Form-Signature", signWebhook(hook.Secret, ts, body))
+	return req, body, nil
+}
+
+// jobDeliverWebhook is the job kind for one webhook delivery.
+const jobDeliverWebhook = "webhook.deliver"
+
+func init() {
+	registerJobHandler(jobDeliverWebhook, (*App).deliverWebhookJob)
+}
+
+// webhookDelivery is the payload of a delivery job. Every attempt resends
+// the same event, so receivers can use its ID to drop duplicates.
+type webhookDelivery struct {
+	WebhookID uint         `json:"webhookId"`
+	Event     webhookEvent `json:"event"`
+}
+
+// fireWebhooks queues a delivery of an event to every active webhook
+// subscribed to it. Failed deliveries are retried by the job queue.
+func (a *App) fireWebhooks(ctx context.Context, event string, data interface{}) {
+	var hooks []models.Webhook
+	if err := a.dbCtx(ctx).Where("active = ?", true).Find(&hooks).Error; err != nil {
+		log.Println("Error loading webhooks:", err)
+		return
+	}
+
+	evt := webhookEvent{ID: newDeliveryID(), Event: event, CreatedAt: time.Now(), Data: a.visibleTo(roleWebhook, data)}
+	for _, hook := range hooks {
+		if !hook.Subscribes(event) {
+			continue
+		}
+		if _, err := enqueueJob(a.dbCtx(ctx), jobDeliverWebhook, webhookDelivery{WebhookID: hook.ID, Event: evt}, time.Now()); err != nil {
+			log.Printf("Error queueing webhook %d delivery: %s", hook.ID, err)
+		}
+	}
+}
+
+func (a *App) deliverWebhookJob(ctx context.Context, job *models.Job) error {
+	var d webhookDelivery
+	if err := json.Unmarshal([]byte(job.Payload), &d); err != nil {
+		return err
+	}
+	var hook models.Webhook
+	err := a.dbCtx(ctx).First(&hook, d.WebhookID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !hook.Active) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	req, _, err := webhookRequest(hook, d.Event)
+	if err != nil {
+		return err
+	}
+	resp, err := a.webhookClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %d rejected delivery: %s", hook.ID, resp.Status)
+	}
+	return nil
+}
+
+func (a *App) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	var body struct {
+		URL    string   `json:"url"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if u, err := url.Parse(body.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http(s) URL")
+		return
+	}
+	for _, e := range body.Events {
+		if _, ok := webhookSamples[e]; !ok {
+			writeErrorf(w, http.StatusBadRequest, "Unknown event type %s", e)
+			return
+		}
+	}
+
+	// Generate a secret unless the integrator supplied one
+	if body.Secret == "" {
+		b := make([]byte, 24)
+		rand.Read(b)
+		body.Secret = hex.EncodeToString(b)
+	}
+
+	hook := models.Webhook{URL: body.URL, Secret: body.Secret, Events: strings.Join(body.Events, ","), Active: true, CreatedBy: user.Username}
+	if err := a.dbCtx(r.Context()).Create(&hook).Error; err != nil {
+		log.Println("Error creating webhook:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	// The secret is only ever returned here
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	a.encodeJSON(w, r, map[string]interface{}{"webhook": hook, "secret": hook.Secret})
+}
+
+func (a *App) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	var hooks []models.Webhook
+	if err := a.dbCtx(r.Context()).Order("id").Find(&hooks).Error; err != nil {
+		log.Println("Error loading webhooks:", err)
+		writeError(w, http.StatusInternalServerError, "Error loading webhooks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, hooks)
+}
+
+func (a *App) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	result := a.dbCtx(r.Context()).Where("id = ?", mux.Vars(r)["id"]).Delete(&models.Webhook{})
+	if result.Error != nil {
+		log.Println("Error deleting webhook:", result.Error)
+		writeError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// testWebhookHandler sends a signed sample payload for the chosen event to a
+// webhook, or with dryRun returns the request it would have sent.
+func (a *App) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var hook models.Webhook
+	if err := a.dbCtx(r.Context()).First(&hook, mux.Vars(r)["id"]).Error; err != nil {
+		writeError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	var body struct {
+		Event  string `json:"event"`
+		DryRun bool   `json:"dryRun"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	sample, ok := webhookSamples[body.Event]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Unknown event type")
+		return
+	}
+
+	evt := webhookEvent{ID: newDeliveryID(), Event: body.Event, CreatedAt: time.Now(), Test: true, Data: a.visibleTo(roleWebhook, sample())}
+	req, payload, err := webhookRequest(hook, evt)
+	if err != nil {
+		log.Println("Error building webhook request:", err)
+		writeError(w, http.StatusInternalServerError, "Failed to build payload")
+		return
+	}
+
+	result := map[string]interface{}{
+		"url":     hook.URL,
+		"headers": req.Header,
+		"payload": json.RawMessage(payload),
+	}
+
+	if !body.DryRun {
+		start := time.Now()
+		resp, err := a.webhookClient.Do(req)
+		result["durationMs"] = time.Since(start).Milliseconds()
+		if err != nil {
+			result["error"] = err.Error()
+		} else {
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+			result["status"] = resp.StatusCode
+			result["response"] = string(respBody)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	a.encodeJSON(w, r, result)
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AuditEntry records an administrative action for later review.
+type AuditEntry struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	Actor          string    `json:"actor"`
+	Action         string    `gorm:"index" json:"action"`
+	SubjectType    string    `json:"subjectType"`
+	SubjectID      uint      `json:"subjectId"`
+	Details        string    `gorm:"type:text" json:"details"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UndeliverableAddress is an address a provider reported as bouncing or
+// complaining. Nothing more is sent to it until an admin lifts the
+// suppression.
+type UndeliverableAddress struct {
+	Email     string    `gorm:"primaryKey" json:"email"`
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// Comment is a reply posted on an issue.
+type Comment struct {
+	gorm.Model
+	IssueID uint   `gorm:"index" json:"issueId"`
+	Author  string `json:"author"`
+	Body    string `gorm:"type:text" json:"body"`
+	// Mentions are the users the body mentions, for linking.
+	Mentions []string `gorm:"-" json:"mentions"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ContactLink is an extra address or profile a contact gained by merging.
+type ContactLink struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	ContactID      uint      `gorm:"index" json:"contactId"`
+	Kind           string    `json:"kind"`
+	URL            string    `gorm:"type:text" json:"url"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
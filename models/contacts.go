@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Contact is a row imported from the uploaded CSV forms.
+type Contact struct {
+	ID              uint   `json:"id"`
+	OrganizationID  uint   `gorm:"index" json:"organizationId"`
+	Email           string `json:"email"`
+	FullName        string `json:"fullName"`
+	Timestamp       string `json:"timestamp"`
+	TwitterProfile  string `json:"twitterProfile"`
+	LinkedinProfile string `json:"linkedinProfile"`
+
+	// Suppressed contacts are left out of exports and integrations.
+	Suppressed        bool       `gorm:"not null;default:false" json:"suppressed"`
+	SuppressedAt      *time.Time `json:"suppressedAt,omitempty"`
+	SuppressionReason string     `json:"suppressionReason,omitempty"`
+	SuppressionNote   string     `json:"suppressionNote,omitempty"`
+
+	// LastActiveAt is when an import last brought the contact in or saw it
+	// again. Retention archives contacts inactive for too long.
+	LastActiveAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"lastActiveAt"`
+}
+
+// TableName keeps contacts in the emails table the CSV importer writes to.
+func (Contact) TableName() string {
+	return "emails"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// CorrectionRequest is a contact's or user's request to change the data
+// stored about them, awaiting admin review.
+type CorrectionRequest struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	SubjectType    string     `gorm:"index:idx_correction_subject" json:"subjectType"`
+	SubjectID      uint       `gorm:"index:idx_correction_subject" json:"subjectId"`
+	Changes        string     `gorm:"type:text" json:"changes"`
+	Previous       string     `gorm:"type:text" json:"previous,omitempty"`
+	Reason         string     `gorm:"type:text" json:"reason"`
+	Status         string     `gorm:"index" json:"status"`
+	ReviewedBy     string     `json:"reviewedBy,omitempty"`
+	ReviewNote     string     `json:"reviewNote,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
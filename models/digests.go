@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// DigestSubscription sends one recipient a daily or weekly summary of their
+// organization by email or to a Slack incoming webhook.
+type DigestSubscription struct {
+	ID             uint `json:"id"`
+	OrganizationID uint `gorm:"index" json:"organizationId"`
+	// Owner is the user who manages the subscription.
+	Owner     string `json:"owner"`
+	Channel   string `json:"channel"`
+	Target    string `gorm:"type:text" json:"target"`
+	Frequency string `json:"frequency"`
+	// NextRunAt is when the next digest is due; LastSentAt is when the
+	// previous one was, and where the next one's period starts.
+	NextRunAt  time.Time  `gorm:"index" json:"nextRunAt"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// EmailMessage is one outgoing email. All mail is sent from the job queue,
+// which applies the provider's rate limit and skips undeliverable
+// addresses.
+type EmailMessage struct {
+	ID                uint       `json:"id"`
+	To                string     `json:"to"`
+	Subject           string     `json:"subject"`
+	Body              string     `gorm:"type:text" json:"body"`
+	Provider          string     `json:"provider"`
+	Status            string     `json:"status"`
+	ProviderMessageID string     `json:"providerMessageId,omitempty"`
+	Error             string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy         string     `json:"createdBy"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	SentAt            *time.Time `json:"sentAt,omitempty"`
+}
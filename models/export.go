@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ExportJob records who exported which dataset and what was redacted.
+type ExportJob struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	Dataset        string     `json:"dataset"`
+	Profile        string     `json:"profile"`
+	RedactedFields string     `json:"redactedFields"`
+	RequestedBy    string     `json:"requestedBy"`
+	Rows           int        `json:"rows"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	CompletedAt    *time.Time `json:"completedAt"`
+}
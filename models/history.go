@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// IssueEvent is one entry in an issue's history.
+type IssueEvent struct {
+	ID        uint      `json:"id"`
+	IssueID   uint      `gorm:"index" json:"issueId"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	FromValue string    `json:"from,omitempty"`
+	ToValue   string    `json:"to,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
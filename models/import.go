@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ImportJob records one run of the contact import pipeline.
+type ImportJob struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	Source         string     `json:"source"`
+	SourceID       *uint      `json:"sourceId,omitempty"`
+	Status         string     `json:"status"`
+	Total          int        `json:"total"`
+	Inserted       int        `json:"inserted"`
+	Skipped        int        `json:"skipped"`
+	Invalid        int        `json:"invalid"`
+	Error          string     `json:"error,omitempty"`
+	RequestedBy    string     `json:"requestedBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	StartedAt      *time.Time `json:"startedAt"`
+	FinishedAt     *time.Time `json:"finishedAt"`
+}
+
+// ImportSource is a saved connector configuration that can be run on demand
+// or polled on an interval.
+type ImportSource struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	Name           string     `json:"name"`
+	Connector      string     `json:"connector"`
+	Config         string     `gorm:"type:text" json:"config"`
+	Mapping        string     `gorm:"type:text" json:"mapping"`
+	PollMinutes    int        `json:"pollMinutes"`
+	LastRunAt      *time.Time `json:"lastRunAt"`
+	CreatedBy      string     `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// InboundNonce records a delivery already accepted from an inbound
+// webhook source, so a captured request cannot be replayed.
+type InboundNonce struct {
+	Source     string    `gorm:"primaryKey" json:"source"`
+	Nonce      string    `gorm:"primaryKey" json:"nonce"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Job is a unit of background work in the database-backed queue. Workers on
+// any instance claim jobs with SELECT ... FOR UPDATE SKIP LOCKED, so each
+// job runs once at a time. SQLite has a single writer, so there the claim
+// needs no row lock.
+type Job struct {
+	ID          uint       `json:"id"`
+	Kind        string     `json:"kind"`
+	Payload     string     `gorm:"type:text" json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	RunAt       time.Time  `json:"runAt"`
+	LockedAt    *time.Time `json:"lockedAt,omitempty"`
+	LastError   string     `gorm:"type:text" json:"lastError,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Label is a tag that can be attached to issues. Names are unique
+// regardless of case.
+type Label struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	Name           string    `json:"name"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// IssueLabel attaches a label to an issue.
+type IssueLabel struct {
+	IssueID uint `gorm:"primaryKey;autoIncrement:false"`
+	LabelID uint `gorm:"primaryKey;autoIncrement:false"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Mention records that an issue's details, or one of its comments, mention
+// a user with @username.
+type Mention struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	IssueID        uint      `gorm:"index" json:"issueId"`
+	CommentID      *uint     `gorm:"index" json:"commentId,omitempty"`
+	Username       string    `json:"username"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// All lists the models checked against the database schema for
+// drift. Add new models here along with their migration.
+var All = []interface{}{
+	&User{}, &Issue{}, &BugReport{}, &Setting{}, &Contact{}, &ExportJob{},
+	&Webhook{}, &ImportJob{}, &ImportSource{}, &IssueEvent{}, &Comment{},
+	&AuditEntry{}, &CorrectionRequest{}, &Label{}, &IssueLabel{}, &Release{},
+	&Job{}, &EmailMessage{}, &UndeliverableAddress{}, &InboundNonce{},
+	&Organization{}, &Membership{}, &Project{}, &ArchivedRecord{},
+	&DigestSubscription{}, &ContactLink{}, &Notification{},
+	&Mention{},
+}
+
+type User struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username" validate:"required,min=3,max=64"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Role     string `json:"role"`
+
+	DisplayName string `gorm:"not null;default:''" json:"displayName" validate:"max=255"`
+	Email       string `gorm:"not null;default:''" json:"email" validate:"omitempty,email,max=255"`
+	// Language is the locale emails and notifications are written in;
+	// empty follows the organization's default.
+	Language string `gorm:"not null;default:''" json:"language" validate:"omitempty,locale"`
+
+	// DisabledAt is set while the account may not log in.
+	DisabledAt *time.Time `json:"-"`
+	// PasswordResetRequired blocks login until a reset link is used.
+	PasswordResetRequired bool `gorm:"not null;default:false" json:"-"`
+	// PasswordChangedAt revokes tokens issued before it.
+	PasswordChangedAt *time.Time `json:"-"`
+	// MembershipRole is the user's role in the organization they are acting
+	// in, set when it is resolved. Operators count as its admins.
+	MembershipRole string `gorm:"-" json:"-"`
+}
+
+type Issue struct {
+	gorm.Model
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	Title          string    `json:"title" validate:"required,notblank,max=255"`
+	Details        string    `json:"details" validate:"max=255"`
+	Priority       int       `json:"priority" validate:"min=0"`
+	Status         bool      `json:"status"`
+	Type           bool      `json:"type"`
+	ImageURL       string    `json:"imageURL"`
+	ReportedBy     string    `json:"reportedBy"`
+	ReportedAt     time.Time `json:"reportedAt"`
+
+	// State refines Status: open, waiting_on_reporter or closed.
+	State         string     `gorm:"not null;default:'open'" json:"state"`
+	WaitingSince  *time.Time `json:"waitingSince,omitempty"`
+	StaleWarnedAt *time.Time `json:"staleWarnedAt,omitempty"`
+
+	Assignee  string `gorm:"not null;default:''" json:"assignee"`
+	Component string `gorm:"not null;default:''" json:"component"`
+	// ReopenCount is how many times the issue went from closed back to open.
+	ReopenCount int `gorm:"not null;default:0" json:"reopenCount"`
+	// FixVersionID is the release that shipped the fix.
+	FixVersionID *uint `gorm:"index" json:"fixVersionId,omitempty"`
+	// ProjectID groups the issue; Number counts up within the project and
+	// Key combines the two for display, e.g. FORM-123.
+	ProjectID *uint  `gorm:"index" json:"projectId,omitempty"`
+	Number    int    `gorm:"not null;default:0" json:"number,omitempty"`
+	Key       string `gorm:"not null;default:''" json:"key,omitempty"`
+	// Version goes up with every write clients can see, so an update can
+	// require the one the client last saw. Only the reminder bookkeeping
+	// below, which is not part of the issue's JSON, changes without it.
+	Version int `gorm:"not null;default:1" json:"version"`
+	// DueAt is when the issue should be resolved by. The assignee is
+	// reminded before and after it; the reminder times stop a second send.
+	DueAt                 *time.Time `gorm:"index" json:"dueAt,omitempty"`
+	DueReminderSentAt     *time.Time `json:"-"`
+	OverdueReminderSentAt *time.Time `json:"-"`
+	// Mentions are the users the details mention, for linking.
+	Mentions []string `gorm:"-" json:"mentions"`
+}
+
+type BugReport struct {
+	gorm.Model
+	Title      string    `json:"title"`
+	Details    string    `json:"details"`
+	Priority   int       `json:"priority"`
+	Status     bool      `json:"status"`
+	Type       bool      `json:"type"`
+	ImageURL   string    `json:"imageURL"`
+	ReportedBy string    `json:"reportedBy"`
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+// DefaultOrganizationID is the organization the migration moved existing
+// data into. Anonymous requests and inbound integrations land there unless
+// they name another.
+const DefaultOrganizationID = 1
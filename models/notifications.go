@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Notification tells a user about something that happened to them in the
+// app: an issue assigned to them, a mention, a change to an issue they
+// reported or are assigned, or an import they started finishing.
+type Notification struct {
+	ID             uint   `json:"id"`
+	OrganizationID uint   `gorm:"index" json:"organizationId"`
+	Recipient      string `gorm:"index:idx_notifications_recipient" json:"recipient"`
+	Kind           string `json:"kind"`
+	Actor          string `json:"actor"`
+	IssueID        *uint  `gorm:"index" json:"issueId,omitempty"`
+	CommentID      *uint  `json:"commentId,omitempty"`
+	ImportJobID    *uint  `json:"importJobId,omitempty"`
+	// Title is the issue title when it was sent; Detail the new state of
+	// the issue or import.
+	Title     string     `json:"title,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+	ReadAt    *time.Time `gorm:"index:idx_notifications_recipient" json:"readAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// Message describes the notification in the recipient's language.
+	Message string `gorm:"-" json:"message"`
+}
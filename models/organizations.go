@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Organization is a tenant. Issues, contacts and everything hanging off them
+// belong to exactly one, and queries only ever see the current
+// organization's rows.
+type Organization struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name" validate:"required,notblank,max=255"`
+	Slug string `gorm:"uniqueIndex" json:"slug" validate:"required,max=64,slug"`
+	// DefaultLanguage is the locale for members who have not chosen one;
+	// empty means English.
+	DefaultLanguage string    `gorm:"not null;default:''" json:"defaultLanguage" validate:"omitempty,locale"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// Membership gives a user a role in an organization.
+type Membership struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"uniqueIndex:idx_memberships_org_user" json:"organizationId"`
+	UserID         uint      `gorm:"uniqueIndex:idx_memberships_org_user" json:"userId"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
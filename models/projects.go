@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Project groups issues and numbers them. Its key prefixes those numbers,
+// as in FORM-123, so it cannot change once the project exists.
+type Project struct {
+	ID             uint   `json:"id"`
+	OrganizationID uint   `gorm:"uniqueIndex:idx_projects_key" json:"organizationId"`
+	Key            string `gorm:"uniqueIndex:idx_projects_key" json:"key" validate:"required,projectkey"`
+	Name           string `json:"name" validate:"required,notblank,max=255"`
+	Description    string `gorm:"not null;default:''" json:"description" validate:"max=2000"`
+	// AutoCloseAfterDays and AutoCloseWarningDays override the settings of
+	// the same name for the project's issues; nil follows the setting.
+	AutoCloseAfterDays   *int `json:"autoCloseAfterDays" validate:"omitnil,min=0,max=3650"`
+	AutoCloseWarningDays *int `json:"autoCloseWarningDays" validate:"omitnil,min=0,max=3650"`
+	// IssueCount is the last issue number handed out.
+	IssueCount int       `gorm:"not null;default:0" json:"issueCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
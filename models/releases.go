@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Release is a deployed version that resolved issues can be attached to as
+// their fix version.
+type Release struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	Version        string     `json:"version" validate:"required,notblank,max=255"`
+	Name           string     `json:"name" validate:"max=255"`
+	ReleasedAt     *time.Time `json:"releasedAt,omitempty"`
+	CreatedBy      string     `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ArchivedRecord is a record the retention sweep moved out of the live
+// tables. Data holds it as JSON along with whatever hung off it.
+type ArchivedRecord struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	Kind           string    `gorm:"uniqueIndex:idx_archived_records_record" json:"kind"`
+	RecordID       uint      `gorm:"uniqueIndex:idx_archived_records_record" json:"recordId"`
+	Data           string    `gorm:"type:jsonb" json:"-"`
+	ArchivedAt     time.Time `json:"archivedAt"`
+}
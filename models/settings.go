@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Setting is a persisted override of one Settings field. Value holds the
+// JSON encoding of the field.
+type Setting struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedBy string    `json:"updatedBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an integrator's endpoint that receives signed event payloads.
+type Webhook struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"`
+	Events         string    `json:"events"`
+	Active         bool      `json:"active"`
+	CreatedBy      string    `json:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+// An empty event list subscribes to everything.
+func (h Webhook) Subscribes(event string) bool {
+	if h.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(h.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"form/config"
+	"form/handlers"
+	"form/storage"
+
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// Server is the assembled HTTP service. Everything it serves reaches the
+// config, database and upload storage through the App it was built with.
+type Server struct {
+	app     *handlers.App
+	handler http.Handler
+}
+
+// NewServer wires the service to c, database and store, loads the admin
+// settings and builds the routes and API docs. The schema must already be
+// migrated. Start runs the background work that goes with it.
+func NewServer(c config.Config, database *gorm.DB, store storage.Storage) (*Server, error) {
+	a, err := handlers.New(c, database, store)
+	if err != nil {
+		return nil, err
+	}
+	h, err := a.Handler()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{app: a, handler: h}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Start sets up email and the admin account, then runs the background work
+// until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	return s.app.Start(ctx)
+}
+
+// Drain marks the server as shutting down, so readiness checks fail while
+// in-flight requests finish.
+func (s *Server) Drain() {
+	s.app.Drain()
+}
+
+// Wait blocks until the background work Start ran has returned, once its
+// context is cancelled.
+func (s *Server) Wait() {
+	s.app.Wait()
+}
+
+// GRPCServer builds the gRPC API on the same service layer. ServeGRPC
+// serves it.
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.app.GRPCServer()
+}
+
+// ServeGRPC listens on the configured gRPC port until srv is stopped.
+func (s *Server) ServeGRPC(srv *grpc.Server) error {
+	return s.app.ServeGRPC(srv)
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"form/config"
+	"form/storage"
+)
+
+// newTestServer runs the service on a new SQLite database, with its
+// background work, for as long as the test.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	c := config.Config{
+		DatabaseDriver:         config.DriverSQLite,
+		DatabaseURL:            filepath.Join(t.TempDir(), "form.db"),
+		PublicURL:              "http://form.test",
+		SigningKey:             []byte("test signing key"),
+		TokenTTL:               time.Hour,
+		EmailProvider:          "log",
+		JobWorkers:             1,
+		JobPollInterval:        time.Hour,
+		SettingsReloadInterval: time.Hour,
+		ImportPollInterval:     time.Hour,
+		AutoCloseInterval:      time.Hour,
+		RetentionInterval:      time.Hour,
+		DigestInterval:         time.Hour,
+		DueReminderInterval:    time.Hour,
+	}
+	database, err := storage.Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.CreateSQLiteSchema(database); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(c, database, storage.DiskStorage(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(func() {
+		srv.Close()
+		cancel()
+		s.Wait()
+		sqlDB, _ := database.DB()
+		sqlDB.Close()
+	})
+	return srv
+}
+
+// client makes requests as one user, optionally in one organization.
+type client struct {
+	t     *testing.T
+	srv   *httptest.Server
+	token string
+	org   string
+}
+
+// do sends body as JSON and decodes the JSON response into out, if given,
+// returning the status code.
+func (c client) do(method, path string, body, out interface{}) int {
+	c.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, c.srv.URL+path, &buf)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.org != "" {
+		req.Header.Set(config.OrganizationHeader, c.org)
+	}
+	resp, err := c.srv.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: decoding response: %s", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login returns a client acting as username.
+func login(t *testing.T, srv *httptest.Server, username, password string) client {
+	t.Helper()
+	var body struct {
+		Token string `json:"token"`
+	}
+	anon := client{t: t, srv: srv}
+	creds := map[string]string{"username": username, "password": password}
+	if code := anon.do("POST", "/login", creds, &body); code != http.StatusOK {
+		t.Fatalf("login as %s: status %d", username, code)
+	}
+	return client{t: t, srv: srv, token: body.Token}
+}
+
+// register creates a member account and logs in as it.
+func register(t *testing.T, srv *httptest.Server, username string) client {
+	t.Helper()
+	anon := client{t: t, srv: srv}
+	creds := map[string]string{"username": username, "password": "password1"}
+	if code := anon.do("POST", "/register", creds, nil); code != http.StatusCreated {
+		t.Fatalf("register %s: status %d", username, code)
+	}
+	return login(t, srv, username, "password1")
+}
+
+type issue struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Priority int    `json:"priority"`
+}
+
+// report creates an issue as c and returns its ID.
+func (c client) report(title string, reportedBy string) uint {
+	c.t.Helper()
+	var body issue
+	code := c.do("POST", "/report-issue", map[string]string{"title": title, "details": "steps", "reportedBy": reportedBy}, &body)
+	if code != http.StatusOK {
+		c.t.Fatalf("report issue: status %d", code)
+	}
+	return body.ID
+}
+
+func TestAuth(t *testing.T) {
+	srv := newTestServer(t)
+	anon := client{t: t, srv: srv}
+
+	register(t, srv, "ana")
+	creds := map[string]string{"username": "ana", "password": "password1"}
+	if code := anon.do("POST", "/register", creds, nil); code != http.StatusConflict {
+		t.Errorf("register taken username: status %d, want %d", code, http.StatusConflict)
+	}
+	short := map[string]string{"username": "bo", "password": "x"}
+	if code := anon.do("POST", "/register", short, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("register invalid account: status %d, want %d", code, http.StatusUnprocessableEntity)
+	}
+
+	wrong := map[string]string{"username": "ana", "password": "password2"}
+	if code := anon.do("POST", "/login", wrong, nil); code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := anon.do("GET", "/issues", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("list issues anonymously: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	forged := client{t: t, srv: srv, token: "not-a-token"}
+	if code := forged.do("GET", "/issues", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("list issues with a bad token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	ana := login(t, srv, "ana", "password1")
+	if code := ana.do("GET", "/issues", nil, nil); code != http.StatusOK {
+		t.Errorf("list issues with a token: status %d, want %d", code, http.StatusOK)
+	}
+	if code := ana.do("GET", "/admin/members", nil, nil); code != http.StatusForbidden {
+		t.Errorf("member calling an admin route: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestIssueCRUD(t *testing.T) {
+	srv := newTestServer(t)
+	ana := register(t, srv, "ana")
+
+	if code := ana.do("POST", "/report-issue", map[string]string{"details": "no title"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("report issue without a title: status %d, want %d", code, http.StatusUnprocessableEntity)
+	}
+	id := ana.report("Login button broken", "ana")
+
+	var got issue
+	path := fmt.Sprintf("/issues/%d", id)
+	if code := ana.do("GET", path, nil, &got); code != http.StatusOK {
+		t.Fatalf("get issue: status %d", code)
+	}
+	if got.ID != id || got.Title != "Login button broken" {
+		t.Errorf("get issue = %+v", got)
+	}
+
+	update := map[string]interface{}{"title": "Login button does nothing", "priority": 2}
+	if code := ana.do("PATCH", path, update, &got); code != http.StatusOK {
+		t.Fatalf("update issue: status %d", code)
+	}
+	if got.Title != "Login button does nothing" || got.Priority != 2 {
+		t.Errorf("updated issue = %+v", got)
+	}
+	if code := ana.do("PATCH", path, map[string]string{"title": " "}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("update issue with a blank title: status %d, want %d", code, http.StatusUnprocessableEntity)
+	}
+
+	var list []issue
+	if code := ana.do("GET", "/issues", nil, &list); code != http.StatusOK {
+		t.Fatalf("list issues: status %d", code)
+	}
+	if len(list) != 1 || list[0].ID != id || list[0].Priority != 2 {
+		t.Errorf("list issues = %+v, want the updated issue %d", list, id)
+	}
+
+	if code := ana.do("GET", "/issues/999", nil, nil); code != http.StatusNotFound {
+		t.Errorf("get missing issue: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := ana.do("PATCH", "/issues/999", update, nil); code != http.StatusNotFound {
+		t.Errorf("update missing issue: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	srv := newTestServer(t)
+	admin := login(t, srv, "admin", "adminpass")
+	bob := register(t, srv, "bob")
+	carol := register(t, srv, "carol")
+
+	org := map[string]string{"name": "Acme", "slug": "acme"}
+	if code := admin.do("POST", "/admin/organizations", org, nil); code != http.StatusCreated {
+		t.Fatalf("create organization: status %d", code)
+	}
+	admin.org = "acme"
+	member := map[string]string{"username": "bob", "role": "admin"}
+	if code := admin.do("POST", "/admin/members", member, nil); code != http.StatusOK {
+		t.Fatalf("add member: status %d", code)
+	}
+	admin.org = ""
+
+	defaultIssue := carol.report("Default organization issue", "carol")
+	bob.org = "acme"
+	acmeIssue := bob.report("Acme issue", "bob")
+
+	// Bob administers acme and so sees all of its issues, but none of the
+	// default organization's
+	var list []issue
+	if code := bob.do("GET", "/issues", nil, &list); code != http.StatusOK {
+		t.Fatalf("list acme issues: status %d", code)
+	}
+	if len(list) != 1 || list[0].ID != acmeIssue {
+		t.Errorf("acme issues = %+v, want only %d", list, acmeIssue)
+	}
+	path := fmt.Sprintf("/issues/%d", defaultIssue)
+	if code := bob.do("GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("get another organization's issue: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := bob.do("PATCH", path, map[string]string{"title": "Taken over"}, nil); code != http.StatusNotFound {
+		t.Errorf("update another organization's issue: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	// Carol is not a member of acme
+	path = fmt.Sprintf("/issues/%d", acmeIssue)
+	if code := carol.do("GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("get acme issue from the default organization: status %d, want %d", code, http.StatusNotFound)
+	}
+	carol.org = "acme"
+	if code := carol.do("GET", "/issues", nil, nil); code != http.StatusForbidden {
+		t.Errorf("non-member acting in acme: status %d, want %d", code, http.StatusForbidden)
+	}
+	carol.org = "nowhere"
+	if code := carol.do("GET", "/issues", nil, nil); code != http.StatusNotFound {
+		t.Errorf("unknown organization: status %d, want %d", code, http.StatusNotFound)
+	}
+	anon := client{t: t, srv: srv, org: "acme"}
+	if code := anon.do("GET", path, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("get acme issue anonymously: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestIssueReadAccess(t *testing.T) {
+	srv := newTestServer(t)
+	admin := login(t, srv, "admin", "adminpass")
+	ana, ben := register(t, srv, "ana"), register(t, srv, "ben")
+
+	org := map[string]string{"name": "Acme", "slug": "acme"}
+	if code := admin.do("POST", "/admin/organizations", org, nil); code != http.StatusCreated {
+		t.Fatalf("create organization: status %d", code)
+	}
+	admin.org = "acme"
+	var project struct {
+		ID uint `json:"id"`
+	}
+	if code := admin.do("POST", "/admin/projects", map[string]string{"key": "ACME", "name": "Acme"}, &project); code != http.StatusCreated {
+		t.Fatalf("create project: status %d", code)
+	}
+	var created issue
+	body := map[string]interface{}{"title": "Acme issue", "projectId": project.ID}
+	if code := admin.do("POST", "/report-issue", body, &created); code != http.StatusOK {
+		t.Fatalf("report acme issue: status %d", code)
+	}
+	acmePaths := []string{fmt.Sprintf("/issues/%d", created.ID), "/issues/ACME-1"}
+
+	for _, path := range acmePaths {
+		if code := admin.do("GET", path, nil, nil); code != http.StatusOK {
+			t.Errorf("admin GET %s: status %d, want %d", path, code, http.StatusOK)
+		}
+		// Naming the organization is not enough without an account
+		anon := client{t: t, srv: srv, org: "acme"}
+		if code := anon.do("GET", path, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("anonymous GET %s: status %d, want %d", path, code, http.StatusUnauthorized)
+		}
+		if code := ana.do("GET", path, nil, nil); code != http.StatusNotFound {
+			t.Errorf("other organization GET %s: status %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+
+	// Members only see the issues they reported or are assigned
+	path := fmt.Sprintf("/issues/%d", ana.report("Ana's issue", "ana"))
+	if code := ana.do("GET", path, nil, nil); code != http.StatusOK {
+		t.Errorf("reporter GET %s: status %d, want %d", path, code, http.StatusOK)
+	}
+	if code := ben.do("GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("other member GET %s: status %d, want %d", path, code, http.StatusNotFound)
+	}
+}
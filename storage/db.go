@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"form/config"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+)
+
+// Connect opens the database c names, retrying with exponential backoff
+// until it answers or c.DBConnectTimeout passes, so the service survives
+// Postgres starting a little after it. The pool is sized from c.
+func Connect(ctx context.Context, c config.Config) (*gorm.DB, error) {
+	deadline := time.Now().Add(c.DBConnectTimeout)
+	backoff := connectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := Open(c)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("Database not ready (attempt %d), retrying in %s: %s", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
+		}
+	}
+}
+
+// Open opens a pool on the driver c selects and checks the database
+// answers. Constraint violations come back as gorm.ErrDuplicatedKey and
+// gorm.ErrForeignKeyViolated whichever driver it is. Only failed
+// statements are logged.
+func Open(c config.Config) (*gorm.DB, error) {
+	gormCfg := &gorm.Config{
+		Logger:         logger.New(log.Default(), logger.Config{LogLevel: logger.Error, IgnoreRecordNotFoundError: true}),
+		TranslateError: true,
+	}
+	var conn *gorm.DB
+	var err error
+	switch c.DatabaseDriver {
+	case config.DriverPostgres:
+		conn, err = openPostgres(c, gormCfg)
+	case config.DriverSQLite:
+		conn, err = gorm.Open(sqlite.Open(sqliteDSN(c.DatabaseURL)), gormCfg)
+	default:
+		return nil, fmt.Errorf("unknown DATABASE_DRIVER %q", c.DatabaseDriver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(c.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(c.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(c.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(c.DBConnMaxIdleTime)
+	return conn, nil
+}
+
+// openPostgres opens a pool on the pgx driver. Every connection gets
+// c.DBStatementTimeout as its statement_timeout.
+func openPostgres(c config.Config, gormCfg *gorm.Config) (*gorm.DB, error) {
+	pgxCfg, err := pgx.ParseConfig(c.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if c.DBStatementTimeout > 0 {
+		pgxCfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(c.DBStatementTimeout.Milliseconds(), 10)
+	}
+	pool := stdlib.OpenDB(*pgxCfg)
+	conn, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), gormCfg)
+	if err != nil {
+		// The pool is ours until gorm takes it; close it so retries do not
+		// leak one each
+		pool.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// sqliteDSN adds the connection settings the service relies on to a SQLite
+// file path. Transactions take the write lock when they begin, standing in
+// for the row locks Postgres takes, and writers queue behind one another
+// for up to the busy timeout instead of failing at once.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+}
+
+// UsingSQLite reports whether q runs on SQLite rather than Postgres, for
+// the few statements the two spell differently.
+func UsingSQLite(q *gorm.DB) bool {
+	return q.Dialector.Name() == config.DriverSQLite
+}
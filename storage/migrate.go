@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	"form/config"
+	"form/models"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Schema changes are numbered SQL files in migrations/, applied in order and
+// recorded in the schema_migrations table. Add a new pair of
+// NNNNNN_name.up.sql / .down.sql files for every model change.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLogger passes golang-migrate's progress messages to the standard
+// logger.
+type migrationLogger struct{}
+
+func (migrationLogger) Printf(format string, v ...interface{}) { log.Printf("migrate: "+format, v...) }
+
+func (migrationLogger) Verbose() bool { return false }
+
+// newMigrator opens its own connection to the database at databaseURL so
+// closing it does not close the shared pool.
+func newMigrator(databaseURL string) (*migrate.Migrate, error) {
+	src, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	target, err := migrationURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, target)
+	if err != nil {
+		return nil, err
+	}
+	m.Log = migrationLogger{}
+	return m, nil
+}
+
+// migrationURL points a postgres:// URL at golang-migrate's pgx driver,
+// which registers itself as pgx5://.
+func migrationURL(databaseURL string) (string, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return "", errors.New("DATABASE_URL must be a postgres:// URL")
+	}
+	u.Scheme = "pgx5"
+	return u.String(), nil
+}
+
+// MigrateUp applies all pending migrations to the database at
+// databaseURL. Concurrent instances wait on the driver's advisory lock, so
+// only one applies each migration.
+func MigrateUp(databaseURL string) error {
+	m, err := newMigrator(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// RunMigrateCommand implements "form migrate up|down [n]|version|force V|goto V".
+func RunMigrateCommand(c config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: form migrate up|down [n]|version|force VERSION|goto VERSION")
+	}
+	if c.DatabaseDriver == config.DriverSQLite {
+		return errors.New("migrations are for Postgres; SQLite databases are created from the models on start")
+	}
+
+	m, err := newMigrator(c.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		// Roll back one migration unless told otherwise
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		err = m.Steps(-n)
+	case "version":
+		v, dirty, err := m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("no migrations applied")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d (dirty: %t)\n", v, dirty)
+		return nil
+	case "force", "goto":
+		if len(args) < 2 {
+			return fmt.Errorf("%s needs a version", args[0])
+		}
+		v, perr := strconv.ParseUint(args[1], 10, 32)
+		if perr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "force" {
+			err = m.Force(int(v))
+		} else {
+			err = m.Migrate(uint(v))
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("migrate: no change")
+		return nil
+	}
+	return err
+}
+
+// LatestMigration returns the highest migration version embedded in the
+// binary.
+func LatestMigration() (uint, error) {
+	src, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	v, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(v)
+		if err != nil {
+			return v, nil
+		}
+		v = next
+	}
+}
+
+// sqliteIndexes are the unique indexes the migrations create that the
+// models cannot describe.
+var sqliteIndexes = []string{
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_name ON labels (organization_id, lower(name))",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_releases_version ON releases (organization_id, version)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_issues_project_number ON issues (project_id, number)",
+}
+
+// CreateSQLiteSchema brings a SQLite database in line with the models and
+// seeds the default organization, as the migrations do for Postgres. The
+// migrations themselves are written for Postgres.
+func CreateSQLiteSchema(q *gorm.DB) error {
+	if err := q.AutoMigrate(models.All...); err != nil {
+		return err
+	}
+	for _, index := range sqliteIndexes {
+		if err := q.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	org := models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"}
+	return q.Clauses(clause.OnConflict{DoNothing: true}).Create(&org).Error
+}
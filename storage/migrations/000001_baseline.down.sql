@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS correction_requests;
+DROP TABLE IF EXISTS audit_entries;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS issue_events;
+DROP TABLE IF EXISTS import_sources;
+DROP TABLE IF EXISTS import_jobs;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS export_jobs;
+DROP TABLE IF EXISTS emails;
+DROP TABLE IF EXISTS settings;
+DROP TABLE IF EXISTS bug_reports;
+DROP TABLE IF EXISTS issues;
+DROP TABLE IF EXISTS users;
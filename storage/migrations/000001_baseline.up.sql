@@ -0,0 +1,163 @@
+-- Baseline matching the schema AutoMigrate produced. Every statement is
+-- idempotent so databases created by AutoMigrate can adopt migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id serial PRIMARY KEY,
+    username varchar(255),
+    password varchar(255),
+    role varchar(255)
+);
+
+CREATE TABLE IF NOT EXISTS issues (
+    id serial PRIMARY KEY,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    title varchar(255),
+    details varchar(255),
+    priority integer,
+    status boolean,
+    type boolean,
+    image_url varchar(255),
+    reported_by varchar(255),
+    reported_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_issues_deleted_at ON issues (deleted_at);
+
+CREATE TABLE IF NOT EXISTS bug_reports (
+    id serial PRIMARY KEY,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    title varchar(255),
+    details varchar(255),
+    priority integer,
+    status boolean,
+    type boolean,
+    image_url varchar(255),
+    reported_by varchar(255),
+    reported_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_bug_reports_deleted_at ON bug_reports (deleted_at);
+
+CREATE TABLE IF NOT EXISTS settings (
+    key varchar(255) NOT NULL PRIMARY KEY,
+    value text,
+    updated_by varchar(255),
+    updated_at timestamp with time zone
+);
+
+-- The emails table predates the models, so add any columns it is missing.
+CREATE TABLE IF NOT EXISTS emails (
+    id serial PRIMARY KEY
+);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS id serial;
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS email varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS full_name varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS timestamp varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS twitter_profile varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS linkedin_profile varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS suppressed boolean NOT NULL DEFAULT false;
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS suppressed_at timestamp with time zone;
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS suppression_reason varchar(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS suppression_note varchar(255);
+
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id serial PRIMARY KEY,
+    dataset varchar(255),
+    profile varchar(255),
+    redacted_fields varchar(255),
+    requested_by varchar(255),
+    rows integer,
+    error varchar(255),
+    created_at timestamp with time zone,
+    completed_at timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id serial PRIMARY KEY,
+    url varchar(255),
+    secret varchar(255),
+    events varchar(255),
+    active boolean,
+    created_by varchar(255),
+    created_at timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id serial PRIMARY KEY,
+    source varchar(255),
+    source_id integer,
+    status varchar(255),
+    total integer,
+    inserted integer,
+    skipped integer,
+    invalid integer,
+    error varchar(255),
+    requested_by varchar(255),
+    created_at timestamp with time zone,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS import_sources (
+    id serial PRIMARY KEY,
+    name varchar(255),
+    connector varchar(255),
+    config text,
+    mapping text,
+    poll_minutes integer,
+    last_run_at timestamp with time zone,
+    created_by varchar(255),
+    created_at timestamp with time zone
+);
+
+CREATE TABLE IF NOT EXISTS issue_events (
+    id serial PRIMARY KEY,
+    issue_id integer,
+    type varchar(255),
+    actor varchar(255),
+    from_value varchar(255),
+    to_value varchar(255),
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_issue_events_issue_id ON issue_events (issue_id);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id serial PRIMARY KEY,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    issue_id integer,
+    author varchar(255),
+    body text
+);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_issue_id ON comments (issue_id);
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id serial PRIMARY KEY,
+    actor varchar(255),
+    action varchar(255),
+    subject_type varchar(255),
+    subject_id integer,
+    details text,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_action ON audit_entries (action);
+
+CREATE TABLE IF NOT EXISTS correction_requests (
+    id serial PRIMARY KEY,
+    subject_type varchar(255),
+    subject_id integer,
+    changes text,
+    previous text,
+    reason text,
+    status varchar(255),
+    reviewed_by varchar(255),
+    review_note varchar(255),
+    reviewed_at timestamp with time zone,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_correction_subject ON correction_requests (subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_correction_requests_status ON correction_requests (status);
@@ -0,0 +1,13 @@
+CREATE TABLE labels (
+    id serial PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    created_at timestamp with time zone
+);
+CREATE UNIQUE INDEX idx_labels_name ON labels (lower(name));
+
+CREATE TABLE issue_labels (
+    issue_id integer NOT NULL,
+    label_id integer NOT NULL REFERENCES labels (id) ON DELETE CASCADE,
+    PRIMARY KEY (issue_id, label_id)
+);
+CREATE INDEX idx_issue_labels_label_id ON issue_labels (label_id);
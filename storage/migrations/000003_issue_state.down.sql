@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_issues_state;
+ALTER TABLE issues DROP COLUMN IF EXISTS stale_warned_at;
+ALTER TABLE issues DROP COLUMN IF EXISTS waiting_since;
+ALTER TABLE issues DROP COLUMN IF EXISTS state;
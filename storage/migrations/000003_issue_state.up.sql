@@ -0,0 +1,5 @@
+ALTER TABLE issues ADD COLUMN state varchar(255) NOT NULL DEFAULT 'open';
+ALTER TABLE issues ADD COLUMN waiting_since timestamp with time zone;
+ALTER TABLE issues ADD COLUMN stale_warned_at timestamp with time zone;
+UPDATE issues SET state = 'closed' WHERE status;
+CREATE INDEX idx_issues_state ON issues (state);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_issue_events_type_created_at;
+DROP INDEX IF EXISTS idx_issues_component;
+DROP INDEX IF EXISTS idx_issues_assignee;
+ALTER TABLE issues DROP COLUMN IF EXISTS reopen_count;
+ALTER TABLE issues DROP COLUMN IF EXISTS component;
+ALTER TABLE issues DROP COLUMN IF EXISTS assignee;
@@ -0,0 +1,6 @@
+ALTER TABLE issues ADD COLUMN assignee varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE issues ADD COLUMN component varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE issues ADD COLUMN reopen_count integer NOT NULL DEFAULT 0;
+CREATE INDEX idx_issues_assignee ON issues (assignee);
+CREATE INDEX idx_issues_component ON issues (component);
+CREATE INDEX idx_issue_events_type_created_at ON issue_events (type, created_at);
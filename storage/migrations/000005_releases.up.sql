@@ -0,0 +1,12 @@
+CREATE TABLE releases (
+    id serial PRIMARY KEY,
+    version varchar(255) NOT NULL,
+    name varchar(255),
+    released_at timestamp with time zone,
+    created_by varchar(255),
+    created_at timestamp with time zone
+);
+CREATE UNIQUE INDEX idx_releases_version ON releases (version);
+
+ALTER TABLE issues ADD COLUMN fix_version_id integer REFERENCES releases (id) ON DELETE SET NULL;
+CREATE INDEX idx_issues_fix_version_id ON issues (fix_version_id);
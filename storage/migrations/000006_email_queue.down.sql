@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS undeliverable_addresses;
+DROP TABLE IF EXISTS email_messages;
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,37 @@
+CREATE TABLE jobs (
+    id serial PRIMARY KEY,
+    kind varchar(255) NOT NULL,
+    payload text,
+    status varchar(255) NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    max_attempts integer NOT NULL DEFAULT 5,
+    run_at timestamp with time zone NOT NULL,
+    locked_at timestamp with time zone,
+    last_error text,
+    created_at timestamp with time zone,
+    finished_at timestamp with time zone
+);
+CREATE INDEX idx_jobs_due ON jobs (status, kind, run_at);
+
+CREATE TABLE email_messages (
+    id serial PRIMARY KEY,
+    "to" varchar(255) NOT NULL,
+    subject varchar(255),
+    body text,
+    provider varchar(255),
+    status varchar(255) NOT NULL,
+    provider_message_id varchar(255),
+    error text,
+    created_by varchar(255),
+    created_at timestamp with time zone,
+    sent_at timestamp with time zone
+);
+CREATE INDEX idx_email_messages_to ON email_messages (lower("to"));
+CREATE INDEX idx_email_messages_provider_message_id ON email_messages (provider_message_id);
+
+CREATE TABLE undeliverable_addresses (
+    email varchar(255) PRIMARY KEY,
+    reason varchar(255),
+    note varchar(255),
+    created_at timestamp with time zone
+);
@@ -0,0 +1,7 @@
+CREATE TABLE inbound_nonces (
+    source varchar(255) NOT NULL,
+    nonce varchar(255) NOT NULL,
+    received_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (source, nonce)
+);
+CREATE INDEX idx_inbound_nonces_received_at ON inbound_nonces (received_at);
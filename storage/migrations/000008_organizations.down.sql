@@ -0,0 +1,18 @@
+DROP INDEX idx_labels_name;
+CREATE UNIQUE INDEX idx_labels_name ON labels (lower(name));
+DROP INDEX idx_releases_version;
+CREATE UNIQUE INDEX idx_releases_version ON releases (version);
+
+ALTER TABLE issues DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE emails DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE labels DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE releases DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE import_sources DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE export_jobs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE audit_entries DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE correction_requests DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,69 @@
+CREATE TABLE organizations (
+    id serial PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    slug varchar(64) NOT NULL,
+    created_at timestamp with time zone
+);
+CREATE UNIQUE INDEX uix_organizations_slug ON organizations (slug);
+
+CREATE TABLE memberships (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role varchar(255) NOT NULL,
+    created_at timestamp with time zone
+);
+CREATE UNIQUE INDEX idx_memberships_org_user ON memberships (organization_id, user_id);
+CREATE INDEX idx_memberships_user_id ON memberships (user_id);
+
+-- Everything that existed before organizations belongs to the default one
+INSERT INTO organizations (id, name, slug, created_at) VALUES (1, 'Default', 'default', now());
+SELECT setval('organizations_id_seq', (SELECT max(id) FROM organizations));
+INSERT INTO memberships (organization_id, user_id, role, created_at)
+SELECT 1, id, CASE WHEN role = 'admin' THEN 'admin' ELSE 'member' END, now() FROM users;
+
+ALTER TABLE issues ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE issues ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_issues_organization_id ON issues (organization_id);
+
+ALTER TABLE emails ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE emails ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_emails_organization_id ON emails (organization_id);
+
+ALTER TABLE labels ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE labels ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_labels_organization_id ON labels (organization_id);
+
+ALTER TABLE releases ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE releases ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_releases_organization_id ON releases (organization_id);
+
+ALTER TABLE webhooks ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE webhooks ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_webhooks_organization_id ON webhooks (organization_id);
+
+ALTER TABLE import_jobs ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE import_jobs ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_import_jobs_organization_id ON import_jobs (organization_id);
+
+ALTER TABLE import_sources ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE import_sources ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_import_sources_organization_id ON import_sources (organization_id);
+
+ALTER TABLE export_jobs ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE export_jobs ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_export_jobs_organization_id ON export_jobs (organization_id);
+
+ALTER TABLE audit_entries ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE audit_entries ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_audit_entries_organization_id ON audit_entries (organization_id);
+
+ALTER TABLE correction_requests ADD COLUMN organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE correction_requests ALTER COLUMN organization_id DROP DEFAULT;
+CREATE INDEX idx_correction_requests_organization_id ON correction_requests (organization_id);
+
+-- Names only need to be unique within an organization
+DROP INDEX idx_labels_name;
+CREATE UNIQUE INDEX idx_labels_name ON labels (organization_id, lower(name));
+DROP INDEX idx_releases_version;
+CREATE UNIQUE INDEX idx_releases_version ON releases (organization_id, version);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_issues_key;
+DROP INDEX IF EXISTS idx_issues_project_number;
+DROP INDEX IF EXISTS idx_issues_project_id;
+ALTER TABLE issues DROP COLUMN IF EXISTS key;
+ALTER TABLE issues DROP COLUMN IF EXISTS number;
+ALTER TABLE issues DROP COLUMN IF EXISTS project_id;
+
+DROP TABLE IF EXISTS projects;
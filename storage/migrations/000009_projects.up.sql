@@ -0,0 +1,20 @@
+CREATE TABLE projects (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    key varchar(10) NOT NULL,
+    name varchar(255) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    issue_count integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone
+);
+CREATE UNIQUE INDEX idx_projects_key ON projects (organization_id, key);
+
+-- key is the project key and number, e.g. FORM-123. Project keys never
+-- change, so it is stored rather than joined in.
+ALTER TABLE issues ADD COLUMN project_id integer REFERENCES projects;
+ALTER TABLE issues ADD COLUMN number integer NOT NULL DEFAULT 0;
+ALTER TABLE issues ADD COLUMN key varchar(21) NOT NULL DEFAULT '';
+CREATE INDEX idx_issues_project_id ON issues (project_id);
+CREATE UNIQUE INDEX idx_issues_project_number ON issues (project_id, number);
+CREATE INDEX idx_issues_key ON issues (organization_id, key) WHERE key <> '';
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN disabled_at timestamp with time zone;
+ALTER TABLE users ADD COLUMN password_reset_required boolean NOT NULL DEFAULT false;
+-- Tokens issued before this are no longer accepted.
+ALTER TABLE users ADD COLUMN password_changed_at timestamp with time zone;
@@ -0,0 +1,2 @@
+ALTER TABLE emails DROP COLUMN IF EXISTS last_active_at;
+DROP TABLE IF EXISTS archived_records;
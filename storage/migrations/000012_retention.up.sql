@@ -0,0 +1,15 @@
+-- Records the retention sweep archived, as JSON with whatever hung off them.
+CREATE TABLE archived_records (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    kind varchar(32) NOT NULL,
+    record_id integer NOT NULL,
+    data jsonb NOT NULL,
+    archived_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_archived_records_organization_id ON archived_records (organization_id);
+CREATE UNIQUE INDEX idx_archived_records_record ON archived_records (kind, record_id);
+
+-- Contacts count as active when they are imported or seen again by an import.
+ALTER TABLE emails ADD COLUMN last_active_at timestamp with time zone NOT NULL DEFAULT now();
+CREATE INDEX idx_emails_last_active_at ON emails (last_active_at);
@@ -0,0 +1,3 @@
+-- version counts writes to an issue so updates can require the one the
+-- client last saw.
+ALTER TABLE issues ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- Scheduled digest reports, one row per recipient and schedule.
+CREATE TABLE digest_subscriptions (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    owner varchar(255) NOT NULL,
+    channel varchar(16) NOT NULL,
+    target text NOT NULL,
+    frequency varchar(16) NOT NULL,
+    next_run_at timestamp with time zone NOT NULL,
+    last_sent_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_digest_subscriptions_organization_id ON digest_subscriptions (organization_id);
+CREATE INDEX idx_digest_subscriptions_next_run_at ON digest_subscriptions (next_run_at);
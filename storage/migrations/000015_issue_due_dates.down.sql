@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_issues_due_at;
+ALTER TABLE issues DROP COLUMN IF EXISTS overdue_reminder_sent_at;
+ALTER TABLE issues DROP COLUMN IF EXISTS due_reminder_sent_at;
+ALTER TABLE issues DROP COLUMN IF EXISTS due_at;
@@ -0,0 +1,6 @@
+-- due_at is when an issue should be resolved by; the reminder columns record
+-- when its assignee was reminded before and after it.
+ALTER TABLE issues ADD COLUMN due_at timestamp with time zone;
+ALTER TABLE issues ADD COLUMN due_reminder_sent_at timestamp with time zone;
+ALTER TABLE issues ADD COLUMN overdue_reminder_sent_at timestamp with time zone;
+CREATE INDEX idx_issues_due_at ON issues (due_at);
@@ -0,0 +1,12 @@
+-- Addresses and profiles contacts gained by merging duplicates.
+CREATE TABLE contact_links (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    contact_id integer NOT NULL,
+    kind varchar(16) NOT NULL,
+    url text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_contact_links_organization_id ON contact_links (organization_id);
+CREATE INDEX idx_contact_links_contact_id ON contact_links (contact_id);
+CREATE INDEX idx_contact_links_url ON contact_links (lower(url)) WHERE kind = 'email';
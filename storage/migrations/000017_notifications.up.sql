@@ -0,0 +1,18 @@
+-- In-app notifications shown to users in the web client.
+CREATE TABLE notifications (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    recipient varchar(255) NOT NULL,
+    kind varchar(32) NOT NULL,
+    actor varchar(255) NOT NULL DEFAULT '',
+    issue_id integer,
+    comment_id integer,
+    import_job_id integer,
+    title text NOT NULL DEFAULT '',
+    detail text NOT NULL DEFAULT '',
+    read_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_notifications_organization_id ON notifications (organization_id);
+CREATE INDEX idx_notifications_recipient ON notifications (recipient, read_at);
+CREATE INDEX idx_notifications_issue_id ON notifications (issue_id);
@@ -0,0 +1,12 @@
+-- Users mentioned with @username in issue details and comments.
+CREATE TABLE mentions (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations,
+    issue_id integer NOT NULL,
+    comment_id integer,
+    username varchar(255) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_mentions_organization_id ON mentions (organization_id);
+CREATE INDEX idx_mentions_issue_id ON mentions (issue_id);
+CREATE INDEX idx_mentions_comment_id ON mentions (comment_id);
@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS auto_close_warning_days;
+ALTER TABLE projects DROP COLUMN IF EXISTS auto_close_after_days;
@@ -0,0 +1,3 @@
+-- Per-project auto-close policy; NULL follows the auto_close_* settings.
+ALTER TABLE projects ADD COLUMN auto_close_after_days integer;
+ALTER TABLE projects ADD COLUMN auto_close_warning_days integer;
@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS default_language;
+ALTER TABLE users DROP COLUMN IF EXISTS language;
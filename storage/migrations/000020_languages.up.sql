@@ -0,0 +1,4 @@
+-- Preferred languages for emails and notifications; '' falls back to the
+-- organization default, then English.
+ALTER TABLE users ADD COLUMN language varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE organizations ADD COLUMN default_language varchar(255) NOT NULL DEFAULT '';
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage keeps uploaded files. Names are slash-separated paths relative to
+// the store, such as "3/4f2a.png".
+type Storage interface {
+	Put(ctx context.Context, name string, content []byte) error
+	// Open returns the file and when it was last written.
+	Open(ctx context.Context, name string) (io.ReadSeekCloser, time.Time, error)
+	// Check verifies the store accepts writes, for the readiness probe.
+	Check(ctx context.Context) error
+}
+
+var ErrStorageName = errors.New("invalid storage name")
+
+// DiskStorage keeps files under a local directory.
+type DiskStorage string
+
+// path maps a storage name to a file under the directory, refusing names
+// that would leave it.
+func (d DiskStorage) path(name string) (string, error) {
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || clean != name || strings.HasPrefix(path.Base(clean), ".") {
+		return "", ErrStorageName
+	}
+	return filepath.Join(string(d), filepath.FromSlash(clean)), nil
+}
+
+func (d DiskStorage) Put(_ context.Context, name string, content []byte) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, content, 0o644)
+}
+
+func (d DiskStorage) Open(_ context.Context, name string) (io.ReadSeekCloser, time.Time, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		err = ErrStorageName
+	}
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	return f, info.ModTime(), nil
+}
+
+func (d DiskStorage) Check(context.Context) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}